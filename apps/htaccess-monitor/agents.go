@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// UserAgent is a named entry of the user-agent catalog
+type UserAgent struct {
+	Name      string   `yaml:"name"`
+	Icon      string   `yaml:"icon"`
+	UserAgent string   `yaml:"user_agent"` // empty sends Go's default one
+	Bot       bool     `yaml:"bot"`
+	IP        string   `yaml:"ip"`      // an address the crawler really crawls from
+	Domains   []string `yaml:"domains"` // reverse DNS domains that verify the crawler
+}
+
+// googleDomains verify Google's crawlers by reverse DNS
+var googleDomains = []string{"googlebot.com", "google.com", "googleusercontent.com"}
+
+// userAgentCatalog lists the built-in agents that tests refer to by name
+var userAgentCatalog = []UserAgent{
+	{Name: "browser", Icon: "🌐"},
+	{Name: "googlebot", Icon: "🤖", Bot: true, IP: "66.249.66.1", Domains: googleDomains, UserAgent: googleBotUA},
+	{Name: "googlebot-smartphone", Icon: "🤖", Bot: true, IP: "66.249.66.1", Domains: googleDomains, UserAgent: "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.6478.126 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+	{Name: "googlebot-image", Icon: "🖼️", Bot: true, IP: "66.249.66.1", Domains: googleDomains, UserAgent: "Googlebot-Image/1.0"},
+	{Name: "adsbot-google", Icon: "📢", Bot: true, IP: "66.249.66.1", Domains: googleDomains, UserAgent: "AdsBot-Google (+http://www.google.com/adsbot.html)"},
+	{Name: "bingbot", Icon: "🔍", Bot: true, IP: "157.55.39.1", Domains: []string{"search.msn.com"}, UserAgent: "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)"},
+	{Name: "duckduckbot", Icon: "🦆", Bot: true, IP: "20.191.45.212", UserAgent: "DuckDuckBot/1.1; (+http://duckduckgo.com/duckduckbot.html)"},
+	{Name: "yandexbot", Icon: "🔍", Bot: true, IP: "5.255.253.1", Domains: []string{"yandex.ru", "yandex.net", "yandex.com"}, UserAgent: "Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)"},
+	{Name: "applebot", Icon: "🍎", Bot: true, IP: "17.58.98.1", Domains: []string{"applebot.apple.com"}, UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.1.1 Safari/605.1.15 (Applebot/0.1; +http://www.apple.com/go/applebot)"},
+	{Name: "facebookexternalhit", Icon: "📘", Bot: true, IP: "31.13.103.1", UserAgent: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"},
+	{Name: "chrome", Icon: "🌐", UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"},
+	{Name: "chrome-mobile", Icon: "📱", UserAgent: "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36"},
+	{Name: "firefox", Icon: "🦊", UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:127.0) Gecko/20100101 Firefox/127.0"},
+	{Name: "safari", Icon: "🧭", UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15"},
+	{Name: "safari-mobile", Icon: "📱", UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"},
+	{Name: "edge", Icon: "🌐", UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0"},
+}
+
+// userAgent returns the catalog entry with the given name; entries of the
+// config's user_agents override built-in ones
+func (c *Config) userAgent(name string) (UserAgent, bool) {
+	for _, catalog := range [][]UserAgent{c.UserAgents, userAgentCatalog} {
+		for _, agent := range catalog {
+			if strings.EqualFold(agent.Name, name) {
+				return agent, true
+			}
+		}
+	}
+	return UserAgent{}, false
+}
+
+// userAgents returns the whole catalog, config entries replacing built-in
+// ones of the same name
+func (c *Config) userAgents() []UserAgent {
+	agents := append([]UserAgent(nil), c.UserAgents...)
+	for _, agent := range userAgentCatalog {
+		if !containsUserAgent(c.UserAgents, agent.Name) {
+			agents = append(agents, agent)
+		}
+	}
+	return agents
+}
+
+// containsUserAgent reports whether agents has an entry with the name
+func containsUserAgent(agents []UserAgent, name string) bool {
+	for _, agent := range agents {
+		if strings.EqualFold(agent.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateUserAgents checks the user_agents entries and resolves the
+// catalog references of the matrix agents
+func (c *Config) validateUserAgents() error {
+	for i, agent := range c.UserAgents {
+		if agent.Name == "" || strings.ContainsAny(agent.Name, " ,") {
+			return fmt.Errorf("user_agents[%d]: name %q must be a single word", i, agent.Name)
+		}
+		if containsUserAgent(c.UserAgents[:i], agent.Name) {
+			return fmt.Errorf("user_agents[%d]: duplicate name %q", i, agent.Name)
+		}
+		if agent.IP != "" && net.ParseIP(agent.IP) == nil {
+			return fmt.Errorf("user_agents[%d]: ip %q is not an IP address", i, agent.IP)
+		}
+	}
+	for i, agent := range c.Agents {
+		if agent.Agent == "" {
+			continue
+		}
+		entry, ok := c.userAgent(agent.Agent)
+		if !ok {
+			return fmt.Errorf("agents[%d]: unknown agent %q", i, agent.Agent)
+		}
+		c.Agents[i] = entry.matrixAgent(agent)
+	}
+	return nil
+}
+
+// matrixAgent fills the empty fields of a matrix agent from the entry
+func (u UserAgent) matrixAgent(agent AgentConfig) AgentConfig {
+	if agent.Name == "" {
+		agent.Name = u.Name
+	}
+	if agent.Icon == "" {
+		agent.Icon = u.Icon
+	}
+	if agent.UserAgent == "" {
+		agent.UserAgent = u.UserAgent
+	}
+	return agent
+}
+
+// lookupUserAgent returns the catalog entry a test refers to by name
+func lookupUserAgent(name string) (UserAgent, bool) {
+	return monitorConfig.userAgent(name)
+}
+
+// userAgentIcon returns the icon of a catalog agent, 🔍 when unknown
+func userAgentIcon(name string) string {
+	if agent, ok := lookupUserAgent(name); ok && agent.Icon != "" {
+		return agent.Icon
+	}
+	return "🔍"
+}
+
+// parseAgentList turns -agents into matrix agents, one table each
+func parseAgentList(cfg *Config, list string) ([]AgentConfig, error) {
+	var agents []AgentConfig
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		entry, ok := cfg.userAgent(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown agent %q (see the agents command)", name)
+		}
+		agents = append(agents, entry.matrixAgent(AgentConfig{}))
+	}
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("-agents needs at least one agent")
+	}
+	return agents, nil
+}
+
+// writeUserAgents lists the catalog
+func writeUserAgents(w io.Writer, agents []UserAgent) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tUSER-AGENT")
+	for _, agent := range agents {
+		kind, ua := "browser", agent.UserAgent
+		if agent.Bot {
+			kind = "bot"
+		}
+		if ua == "" {
+			ua = "(Go default)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", agent.Name, kind, ua)
+	}
+	return tw.Flush()
+}
+
+// runAgentsCommand prints the user-agent catalog
+func runAgentsCommand(args []string) int {
+	fs := flag.NewFlagSet("agents", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor agents\n\n")
+		fmt.Fprintf(fs.Output(), "Lists the user agents that the Agent column, agents[].agent and -agents refer to.\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if err := writeUserAgents(os.Stdout, monitorConfig.userAgents()); err != nil {
+		return exitError
+	}
+	return exitPass
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestUserAgentCatalog tests that every entry has a unique single-word name
+// and that only the browser entry sends Go's default User-Agent
+func TestUserAgentCatalog(t *testing.T) {
+	seen := map[string]bool{}
+	for _, agent := range userAgentCatalog {
+		if agent.Name != strings.ToLower(agent.Name) || strings.ContainsAny(agent.Name, " ,") || seen[agent.Name] {
+			t.Errorf("invalid or duplicate name %q", agent.Name)
+		}
+		seen[agent.Name] = true
+		if agent.Icon == "" || (agent.UserAgent == "") != (agent.Name == "browser") {
+			t.Errorf("incomplete entry %+v", agent)
+		}
+	}
+}
+
+// TestLookupUserAgent tests case-insensitive lookups and config overrides
+func TestLookupUserAgent(t *testing.T) {
+	cfg, err := parseConfig([]byte("user_agents:\n  - {name: bingbot, user_agent: my-bing}\n  - {name: petalbot, bot: true, user_agent: PetalBot}\n"))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"GoogleBot", googleBotUA, true},
+		{"Browser", "", true},
+		{"bingbot", "my-bing", true},
+		{"PetalBot", "PetalBot", true},
+		{"nope", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := cfg.userAgent(tt.name)
+		if got.UserAgent != tt.want || ok != tt.wantOK {
+			t.Errorf("userAgent(%q) = %q, %v, want %q, %v", tt.name, got.UserAgent, ok, tt.want, tt.wantOK)
+		}
+	}
+	if got := len(cfg.userAgents()); got != len(userAgentCatalog)+1 {
+		t.Errorf("userAgents() has %d entries, want the catalog plus petalbot", got)
+	}
+}
+
+// TestConfigAgentReference tests matrix agents that reference the catalog
+func TestConfigAgentReference(t *testing.T) {
+	cfg, err := parseConfig([]byte("agents:\n  - {agent: bingbot}\n  - {name: Mobile, icon: 📲, agent: chrome-mobile}\n"))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	bing, _ := cfg.userAgent("bingbot")
+	if got := cfg.Agents[0]; got.Name != "bingbot" || got.Icon != bing.Icon || got.UserAgent != bing.UserAgent {
+		t.Errorf("agents[0] = %+v", got)
+	}
+	if got := cfg.Agents[1]; got.Name != "Mobile" || got.Icon != "📲" || !strings.Contains(got.UserAgent, "Mobile Safari") {
+		t.Errorf("agents[1] = %+v", got)
+	}
+
+	for _, invalid := range []string{
+		"agents: [{agent: nope}]\n",
+		"user_agents: [{user_agent: x}]\n",
+		"user_agents: [{name: my bot, user_agent: x}]\n",
+		"user_agents: [{name: a, user_agent: x}, {name: A, user_agent: y}]\n",
+	} {
+		if _, err := parseConfig([]byte(invalid)); err == nil {
+			t.Errorf("parseConfig(%q) expected error", invalid)
+		}
+	}
+}
+
+// TestParseAgentList tests the -agents flag
+func TestParseAgentList(t *testing.T) {
+	agents, err := parseAgentList(defaultConfig(), "googlebot, Bingbot,chrome-mobile")
+	if err != nil {
+		t.Fatalf("parseAgentList() error = %v", err)
+	}
+	if len(agents) != 3 || agents[1].Name != "bingbot" || agents[1].Icon == "" {
+		t.Errorf("agents = %+v", agents)
+	}
+	for _, invalid := range []string{"", " , ", "googlebot,nope"} {
+		if _, err := parseAgentList(defaultConfig(), invalid); err == nil {
+			t.Errorf("parseAgentList(%q) expected error", invalid)
+		}
+	}
+}
+
+// TestLinkTestAgents tests that links.testing agents send the catalog
+// User-Agent and that unknown agents are rejected
+func TestLinkTestAgents(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.UserAgent())
+	}))
+	defer server.Close()
+	setConcurrency(t, 1, 0)
+
+	path := writeTestFile(t, "links.testing", "Agent,Country,URL,Expected status,Expected result\n"+
+		"bingbot,US,"+server.URL+"/,200,No redirect\n"+
+		"Chrome-Mobile,US,"+server.URL+"/,200,No redirect\n")
+	tests, err := parseLinkTestFile(path)
+	if err != nil {
+		t.Fatalf("parseLinkTestFile() error = %v", err)
+	}
+	runLinkTests(tests)
+	if len(got) != 2 || !strings.Contains(got[0], "bingbot/2.0") || !strings.Contains(got[1], "Android") {
+		t.Errorf("User-Agents sent = %q", got)
+	}
+	if userAgentIcon("Chrome-Mobile") != "📱" || userAgentIcon("nope") != "🔍" {
+		t.Error("userAgentIcon() does not use the catalog")
+	}
+
+	path = writeTestFile(t, "links.testing", "Agent,Country,URL,Expected status,Expected result\nMobile,US,http://example.com/,200,No redirect\n")
+	if _, err := parseLinkTestFile(path); err == nil || !strings.Contains(err.Error(), `unknown agent "Mobile"`) {
+		t.Errorf("parseLinkTestFile() error = %v, want unknown agent", err)
+	}
+}
+
+// TestWriteUserAgents tests the agents listing
+func TestWriteUserAgents(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeUserAgents(&out, defaultConfig().userAgents()); err != nil {
+		t.Fatalf("writeUserAgents() error = %v", err)
+	}
+	for _, want := range []string{"NAME", "browser    ", "(Go default)", "bingbot", "bot      Mozilla/5.0 (compatible; bingbot/2.0"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
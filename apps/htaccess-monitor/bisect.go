@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitFile reads a file at revisions of the git repository it lives in
+type gitFile struct {
+	dir  string // directory of the file, where git runs
+	name string // file name within dir
+}
+
+// newGitFile locates a file for git
+func newGitFile(path string) (gitFile, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return gitFile{}, err
+	}
+	return gitFile{dir: filepath.Dir(abs), name: filepath.Base(abs)}, nil
+}
+
+// git runs a git command next to the file and returns its output
+func (f gitFile) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = f.dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// resolve returns the commit hash a revision names
+func (f gitFile) resolve(rev string) (string, error) {
+	out, err := f.git("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// changes lists the commits after good up to bad that change the file,
+// oldest first
+func (f gitFile) changes(good, bad string) ([]string, error) {
+	out, err := f.git("rev-list", "--reverse", good+".."+bad, "--", f.name)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// show returns the file at a revision; a revision without the file has no
+// rules, as a server without the .htaccess would
+func (f gitFile) show(rev string) ([]byte, error) {
+	if _, err := f.git("cat-file", "-e", rev+":./"+f.name); err != nil {
+		return nil, nil
+	}
+	out, err := f.git("show", rev+":./"+f.name)
+	return []byte(out), err
+}
+
+// describe returns a one-line summary of a commit
+func (f gitFile) describe(rev string) string {
+	out, err := f.git("log", "-1", "--format=%h %s (%an, %ad)", "--date=short", rev)
+	if err != nil {
+		return shortHash(rev)
+	}
+	return strings.TrimSpace(out)
+}
+
+// diff returns the changes of the file between two revisions
+func (f gitFile) diff(from, to string) (string, error) {
+	return f.git("diff", from, to, "--", f.name)
+}
+
+// evaluateRevision runs a link test against .htaccess content with the
+// in-process engine
+func evaluateRevision(content []byte, test LinkTest) (LinkTestResult, error) {
+	tmp, err := os.CreateTemp("", "htaccess-bisect-*")
+	if err != nil {
+		return LinkTestResult{}, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return LinkTestResult{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return LinkTestResult{}, err
+	}
+
+	oldTransport := backendTransport
+	defer func() { backendTransport = oldTransport }()
+	backendTransport = newRewriteBackend(tmp.Name(), offlineDocRoot)
+	return runLinkTest(context.Background(), test), nil
+}
+
+// bisectResult is the outcome of a bisection
+type bisectResult struct {
+	First  string // first commit the test fails at
+	Before string // last commit it passes at
+	Result LinkTestResult
+	Steps  int // revisions evaluated
+}
+
+// bisectTest finds the first commit between good and bad whose version of
+// the file makes the test fail, printing each evaluated revision
+func bisectTest(w io.Writer, file gitFile, test LinkTest, good, bad string) (bisectResult, error) {
+	var result bisectResult
+	evaluate := func(rev string) (LinkTestResult, error) {
+		content, err := file.show(rev)
+		if err != nil {
+			return LinkTestResult{}, err
+		}
+		r, err := evaluateRevision(content, test)
+		if err != nil {
+			return r, err
+		}
+		result.Steps++
+		icon := "✅"
+		if !r.Success {
+			icon = "❌"
+		}
+		fmt.Fprintf(w, "   %s %s -> %d %s\n", icon, file.describe(rev), r.Status, describeChain(r.Result, r.Chain, r.ChainIssue))
+		return r, nil
+	}
+
+	if r, err := evaluate(good); err != nil {
+		return result, err
+	} else if !r.Success {
+		return result, fmt.Errorf("the test already fails at %s: %s", shortHash(good), r.Reason)
+	}
+	last, err := evaluate(bad)
+	if err != nil {
+		return result, err
+	}
+	if last.Success {
+		return result, fmt.Errorf("the test passes at %s, nothing to bisect", shortHash(bad))
+	}
+	commits, err := file.changes(good, bad)
+	if err != nil {
+		return result, err
+	}
+	if len(commits) == 0 {
+		return result, fmt.Errorf("no commit between %s and %s changes %s", shortHash(good), shortHash(bad), file.name)
+	}
+
+	// The file at the last commit is the one at bad, which fails
+	lo, hi := -1, len(commits)-1
+	result.Result = last
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		r, err := evaluate(commits[mid])
+		if err != nil {
+			return result, err
+		}
+		if r.Success {
+			lo = mid
+		} else {
+			hi, result.Result = mid, r
+		}
+	}
+	result.First, result.Before = commits[hi], good
+	if lo >= 0 {
+		result.Before = commits[lo]
+	}
+	return result, nil
+}
+
+// selectBisectTest picks the test case to bisect: the one at line, the one
+// named name, or else the first one failing against the working file
+func selectBisectTest(tests []LinkTest, line int, name string) (LinkTest, error) {
+	for _, test := range tests {
+		switch {
+		case line > 0 && test.Line == line,
+			name != "" && strings.EqualFold(test.Name, name):
+			return test, nil
+		case line == 0 && name == "":
+			content, err := os.ReadFile(htaccessPath)
+			if err != nil {
+				return LinkTest{}, err
+			}
+			if r, err := evaluateRevision(content, test); err != nil {
+				return LinkTest{}, err
+			} else if !r.Success {
+				return test, nil
+			}
+		}
+	}
+	switch {
+	case line > 0:
+		return LinkTest{}, fmt.Errorf("no test case at line %d", line)
+	case name != "":
+		return LinkTest{}, fmt.Errorf("no test case named %q", name)
+	}
+	return LinkTest{}, fmt.Errorf("every test case passes against %s", htaccessPath)
+}
+
+// runBisectCommand finds the commit that broke a link test case
+func runBisectCommand(args []string) int {
+	fs := flag.NewFlagSet("bisect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor bisect -test links.testing [flags] <good> [<bad>]\n\n")
+		fmt.Fprintf(fs.Output(), "Evaluates the .htaccess (-htaccess) at the commits between good and bad\n(default HEAD) that changed it, read with git show and run in-process, and\nreports the first one that makes the test case fail with its diff.\n\n")
+		fs.PrintDefaults()
+	}
+	var testFile = fs.String("test", "", "Test file holding the case")
+	var line = fs.Int("line", 0, "Bisect the test case on this line of the test file")
+	var name = fs.String("name", "", "Bisect the test case with this name")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	good, bad := fs.Arg(0), fs.Arg(1)
+	if before, after, ok := strings.Cut(good, ".."); ok && bad == "" {
+		good, bad = before, after
+	}
+	if bad == "" {
+		bad = "HEAD"
+	}
+	if *testFile == "" || good == "" || fs.NArg() > 2 {
+		fs.Usage()
+		return exitError
+	}
+
+	tests, err := parseLinkTestFile(*testFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading test file: %v\n", err)
+		return exitError
+	}
+	test, err := selectBisectTest(tests, *line, *name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	file, err := newGitFile(htaccessPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	if good, err = file.resolve(good); err == nil {
+		bad, err = file.resolve(bad)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+
+	label := fmt.Sprintf("%s:%d %s", *testFile, test.Line, reportCaseName(test))
+	if test.Agent != "" {
+		label += " (" + test.Agent + ")"
+	}
+	fmt.Printf("🔎 Bisecting %s between %s and %s\n", label, shortHash(good), shortHash(bad))
+	result, err := bisectTest(os.Stdout, file, test, good, bad)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("\n🎯 First failing commit after %d evaluations: %s\n", result.Steps, file.describe(result.First))
+	fmt.Printf("   %s\n\n", result.Result.Reason)
+	diff, err := file.diff(result.Before, result.First)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	fmt.Print(diff)
+	return exitPass
+}
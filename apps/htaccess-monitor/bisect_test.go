@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bisectRules returns a .htaccess redirecting German visitors to target
+func bisectRules(target, comment string) string {
+	return "RewriteEngine On\n" + comment + "RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^DE$\nRewriteRule ^(.*)$ " + target + "$1 [R=302,L]\n"
+}
+
+// newBisectRepo creates a git repository whose .htaccess gets each version
+// in its own commit, with an unrelated commit after the first; it returns
+// the file and the commits in order
+func newBisectRepo(t *testing.T, versions ...string) (gitFile, []string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+
+	var commits []string
+	for i, version := range versions {
+		mustWrite(t, filepath.Join(dir, ".htaccess"), version)
+		git("add", ".htaccess")
+		git("commit", "-q", "-m", fmt.Sprintf("version %d", i+1))
+		commits = append(commits, git("rev-parse", "HEAD"))
+		if i == 0 {
+			mustWrite(t, filepath.Join(dir, "README"), "docs\n")
+			git("add", "README")
+			git("commit", "-q", "-m", "docs")
+		}
+	}
+	return gitFile{dir: dir, name: ".htaccess"}, commits
+}
+
+// bisectCase is a link test that the /de/ redirect passes
+var bisectCase = LinkTest{Line: 2, Country: "de", URL: "http://localhost:8080/", ExpectedStatus: 302, ExpectedResult: "/de/"}
+
+// TestBisectTest tests finding the commit that broke a test case
+func TestBisectTest(t *testing.T) {
+	file, commits := newBisectRepo(t,
+		bisectRules("/de/", ""),
+		bisectRules("/de/", "# geo redirects\n"),
+		bisectRules("/de/", "# geo redirects\n# DE\n"),
+		bisectRules("/at/", "# geo redirects\n# DE\n"),
+		bisectRules("/at/", "# geo redirects\n# DE, moved\n"),
+	)
+
+	var out bytes.Buffer
+	result, err := bisectTest(&out, file, bisectCase, commits[0], commits[4])
+	if err != nil {
+		t.Fatalf("bisectTest() error = %v\n%s", err, out.String())
+	}
+	if result.First != commits[3] || result.Before != commits[2] {
+		t.Errorf("first failing = %s after %s, want %s after %s", shortHash(result.First), shortHash(result.Before), shortHash(commits[3]), shortHash(commits[2]))
+	}
+	if result.Result.Success || !strings.Contains(result.Result.Result, "/at/") {
+		t.Errorf("result at the first failing commit = %+v", result.Result)
+	}
+	if !strings.Contains(out.String(), "❌ "+shortHash(commits[3])[:7]+" version 4 (Test, ") {
+		t.Errorf("evaluated revisions not listed:\n%s", out.String())
+	}
+
+	diff, err := file.diff(result.Before, result.First)
+	if err != nil || !strings.Contains(diff, "-RewriteRule ^(.*)$ /de/$1 [R=302,L]\n+RewriteRule ^(.*)$ /at/$1 [R=302,L]") {
+		t.Errorf("diff() = %q, %v", diff, err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		good, bad string
+		want      string
+	}{
+		{"fails at good", commits[3], commits[4], "already fails"},
+		{"passes at bad", commits[0], commits[2], "nothing to bisect"},
+	} {
+		if _, err := bisectTest(&bytes.Buffer{}, file, bisectCase, tt.good, tt.bad); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestGitFileShow tests reading revisions, including ones without the file
+func TestGitFileShow(t *testing.T) {
+	file, commits := newBisectRepo(t, bisectRules("/de/", ""))
+	content, err := file.show(commits[0])
+	if err != nil || string(content) != bisectRules("/de/", "") {
+		t.Errorf("show() = %q, %v", content, err)
+	}
+	missing := gitFile{dir: file.dir, name: "other.htaccess"}
+	if content, err := missing.show(commits[0]); err != nil || content != nil {
+		t.Errorf("show() of a missing file = %q, %v, want no content", content, err)
+	}
+	if _, err := file.resolve("no-such-branch"); err == nil {
+		t.Error("resolve() of an unknown revision expected error")
+	}
+}
+
+// TestSelectBisectTest tests picking the case by line, by name and by
+// failing against the working file
+func TestSelectBisectTest(t *testing.T) {
+	oldPath := htaccessPath
+	defer func() { htaccessPath = oldPath }()
+	htaccessPath = writeTestFile(t, ".htaccess", bisectRules("/at/", ""))
+
+	passing := LinkTest{Line: 1, Name: "France", Country: "fr", URL: "http://localhost:8080/", ExpectedStatus: 200, ExpectedResult: "No redirect"}
+	tests := []LinkTest{passing, bisectCase}
+	if test, err := selectBisectTest(tests, 0, ""); err != nil || test.Line != 2 {
+		t.Errorf("selectBisectTest() = line %d, %v, want the failing line 2", test.Line, err)
+	}
+	if test, err := selectBisectTest(tests, 0, "france"); err != nil || test.Line != 1 {
+		t.Errorf("selectBisectTest(name) = line %d, %v", test.Line, err)
+	}
+	if _, err := selectBisectTest(tests, 7, ""); err == nil {
+		t.Error("selectBisectTest() of a missing line expected error")
+	}
+	if _, err := selectBisectTest([]LinkTest{passing}, 0, ""); err == nil {
+		t.Error("selectBisectTest() without a failing case expected error")
+	}
+}
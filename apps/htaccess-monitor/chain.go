@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultFollowLimit is used for tests that assert on the redirect chain
+// while -follow is not set
+const defaultFollowLimit = 10
+
+// followLimit is the maximum number of redirects followed after the first
+// response; 0 keeps the historical first-hop-only behaviour
+var followLimit int
+
+// Chain issues reported in HTTPResult.ChainIssue
+const (
+	chainIssueLoop    = "redirect loop"
+	chainIssueTooLong = "too many redirects"
+)
+
+// RedirectHop represents one response in a redirect chain
+type RedirectHop struct {
+	URL      string
+	Status   int
+	Location string
+	Header   http.Header
+}
+
+// newRedirectHop records a response, resolving its Location against the request URL
+func newRedirectHop(requestURL string, resp *http.Response) RedirectHop {
+	hop := RedirectHop{URL: requestURL, Status: resp.StatusCode, Header: resp.Header.Clone()}
+	if location := resp.Header.Get("Location"); location != "" {
+		hop.Location = location
+		if base, err := url.Parse(requestURL); err == nil {
+			if ref, err := url.Parse(location); err == nil {
+				hop.Location = base.ResolveReference(ref).String()
+			}
+		}
+	}
+	return hop
+}
+
+// isRedirectHop reports whether a hop points to another URL
+func isRedirectHop(hop RedirectHop) bool {
+	return hop.Status >= 300 && hop.Status < 400 && hop.Location != ""
+}
+
+// followChain follows Location headers starting after the first response and
+// returns every hop together with a loop or length issue, if any
+func followChain(ctx context.Context, client *http.Client, first RedirectHop, headers map[string]string, limit int) ([]RedirectHop, string) {
+	chain := []RedirectHop{first}
+	seen := map[string]bool{first.URL: true}
+
+	for current := first; isRedirectHop(current); {
+		if seen[current.Location] {
+			return chain, chainIssueLoop
+		}
+		if len(chain) > limit {
+			return chain, chainIssueTooLong
+		}
+		seen[current.Location] = true
+
+		req, err := newTestRequestContext(ctx, "GET", current.Location, headers)
+		if err != nil {
+			return chain, "Request failed"
+		}
+		resp, failure := doTestRequest(client, req)
+		if resp == nil {
+			chain = append(chain, RedirectHop{URL: current.Location})
+			return chain, failure
+		}
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("Error closing response body: %v", err)
+		}
+
+		current = newRedirectHop(current.Location, resp)
+		chain = append(chain, current)
+	}
+	return chain, ""
+}
+
+// formatChain renders a chain compactly, e.g. "302 → 301 → 200"
+func formatChain(chain []RedirectHop, issue string) string {
+	parts := make([]string, 0, len(chain))
+	for _, hop := range chain {
+		if hop.Status == 0 {
+			parts = append(parts, "ERR")
+			continue
+		}
+		parts = append(parts, strconv.Itoa(hop.Status))
+	}
+	text := strings.Join(parts, " → ")
+	switch issue {
+	case "":
+	case chainIssueLoop:
+		text += " ↺ loop"
+	default:
+		text += " (" + issue + ")"
+	}
+	return text
+}
+
+// chainFinalURL returns the URL of the last response in the chain
+func chainFinalURL(chain []RedirectHop) string {
+	if len(chain) == 0 {
+		return ""
+	}
+	return chain[len(chain)-1].URL
+}
+
+// describeChain returns the chain and final URL for result columns, or the
+// plain result when no chain was recorded
+func describeChain(result string, chain []RedirectHop, issue string) string {
+	if len(chain) <= 1 && issue == "" {
+		return result
+	}
+	return fmt.Sprintf("%s %s", formatChain(chain, issue), chainFinalURL(chain))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newChainServer returns a server with a small redirect graph
+func newChainServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			w.Header().Set("Location", "/middle")
+			w.WriteHeader(302)
+		case "/middle":
+			w.Header().Set("Location", "/final")
+			w.Header().Set("X-Hop", "middle")
+			w.WriteHeader(301)
+		case "/final":
+			w.WriteHeader(200)
+		case "/broken":
+			w.Header().Set("Location", "/missing")
+			w.WriteHeader(302)
+		case "/loop-a":
+			w.Header().Set("Location", "/loop-b")
+			w.WriteHeader(302)
+		case "/loop-b":
+			w.Header().Set("Location", "/loop-a")
+			w.WriteHeader(302)
+		default:
+			if strings.HasPrefix(r.URL.Path, "/grow") {
+				w.Header().Set("Location", r.URL.Path+"/uk")
+				w.WriteHeader(301)
+				return
+			}
+			w.WriteHeader(404)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestTestURLFollow tests redirect chain following
+func TestTestURLFollow(t *testing.T) {
+	server := newChainServer(t)
+
+	tests := []struct {
+		name  string
+		path  string
+		limit int
+		chain string
+		issue string
+		final string
+	}{
+		{"first hop only", "/start", 0, "", "", ""},
+		{"full chain", "/start", 10, "302 → 301 → 200", "", "/final"},
+		{"lands on 404", "/broken", 10, "302 → 404", "", "/missing"},
+		{"loop", "/loop-a", 10, "302 → 302 ↺ loop", chainIssueLoop, "/loop-b"},
+		{"too long", "/grow", 3, "301 → 301 → 301 → 301 (too many redirects)", chainIssueTooLong, "/grow/uk/uk/uk"},
+		{"no redirect", "/final", 10, "200", "", "/final"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := testURLFollow(server.URL+tt.path, "US", "", tt.limit)
+
+			if tt.limit == 0 {
+				if result.Chain != nil || result.Result != "/middle" {
+					t.Errorf("testURLFollow() chain = %v, result = %s, want first hop only", result.Chain, result.Result)
+				}
+				return
+			}
+			if got := formatChain(result.Chain, result.ChainIssue); got != tt.chain {
+				t.Errorf("formatChain() = %q, want %q", got, tt.chain)
+			}
+			if result.ChainIssue != tt.issue {
+				t.Errorf("ChainIssue = %q, want %q", result.ChainIssue, tt.issue)
+			}
+			if got := chainFinalURL(result.Chain); got != server.URL+tt.final {
+				t.Errorf("chainFinalURL() = %s, want %s", got, server.URL+tt.final)
+			}
+		})
+	}
+
+	// Each hop keeps the headers set by the server
+	result := testURLFollow(server.URL+"/start", "US", "", 10)
+	if result.Chain[1].Header.Get("X-Hop") != "middle" {
+		t.Errorf("hop headers = %v, want X-Hop: middle", result.Chain[1].Header)
+	}
+}
+
+// TestRunLinkTestsChain tests final destination and hop count assertions
+func TestRunLinkTestsChain(t *testing.T) {
+	server := newChainServer(t)
+
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.csv")
+	csvContent := `Agent,Country,URL,ExpectedStatus,ExpectedResult,ExpectedFinal,ExpectedHops
+Browser,US,` + server.URL + `/start,302,/middle,/final,3
+Browser,US,` + server.URL + `/start,302,/middle,/other,
+Browser,US,` + server.URL + `/start,302,/middle,,2
+Browser,US,` + server.URL + `/loop-a,302,/loop-b,,
+Browser,US,` + server.URL + `/final,200,No redirect,,`
+	if err := os.WriteFile(testFile, []byte(csvContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests, err := parseLinkTestFile(testFile)
+	if err != nil {
+		t.Fatalf("parseLinkTestFile() error = %v", err)
+	}
+	if tests[0].ExpectedFinal != "/final" || tests[0].ExpectedHops != 3 {
+		t.Errorf("chain columns = %q %d, want /final 3", tests[0].ExpectedFinal, tests[0].ExpectedHops)
+	}
+
+	// Chains are followed for tests with chain expectations, and for all tests with -follow
+	oldLimit := followLimit
+	defer func() { followLimit = oldLimit }()
+
+	for _, limit := range []int{0, 5} {
+		followLimit = limit
+		results := runLinkTests(tests)
+		want := []bool{true, false, false, limit == 0, true}
+		for i, result := range results {
+			if result.Success != want[i] {
+				t.Errorf("follow=%d test %d success = %v, want %v (chain %s)",
+					limit, i, result.Success, want[i], formatChain(result.Chain, result.ChainIssue))
+			}
+		}
+	}
+}
+
+// TestParseLinkTestFileInvalidHops tests hop count validation
+func TestParseLinkTestFileInvalidHops(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.csv")
+	csvContent := "Agent,Country,URL,ExpectedStatus,ExpectedResult,ExpectedFinal,ExpectedHops\nBrowser,US,http://example.com,200,No redirect,,many"
+	if err := os.WriteFile(testFile, []byte(csvContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := parseLinkTestFile(testFile); err == nil || !strings.Contains(err.Error(), "test.csv:2:") {
+		t.Errorf("parseLinkTestFile() error = %v, want line 2 error", err)
+	}
+}
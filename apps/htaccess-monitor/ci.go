@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Exit codes of CI mode
+const (
+	exitPass  = 0
+	exitFail  = 1
+	exitError = 2 // the suite could not run, or a request could not be sent
+)
+
+// ciOptions controls a CI mode run
+type ciOptions struct {
+	failFast    bool
+	maxFailures int // stop after this many failing tests, 0 = no limit
+}
+
+// stopAfter returns the number of failures that ends the run, 0 for none
+func (o ciOptions) stopAfter() int {
+	if o.failFast {
+		return 1
+	}
+	return o.maxFailures
+}
+
+// stdoutIsTerminal reports whether stdout is an interactive terminal
+func stdoutIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// runCI runs the link tests once with plain output and returns the exit code
+func runCI(w io.Writer, testFile string, opts ciOptions) int {
+	tests, err := parseLinkTestFile(testFile)
+	if err != nil {
+		fmt.Fprintf(w, "ERROR reading test file: %v\n", err)
+		return exitError
+	}
+	if len(tests) == 0 {
+		fmt.Fprintf(w, "ERROR no tests found in %s\n", testFile)
+		return exitError
+	}
+
+	fmt.Fprintf(w, "Running %d link tests from %s against %s\n", len(tests), testFile, backendName())
+	started := time.Now()
+	results := runLinkTestsCI(w, testFile, tests, opts)
+	code := ciSummary(w, results, len(tests))
+	recordLinkRun(testFile, tests, results, started)
+	if err := writeReports(testFile, tests, results, started); err != nil {
+		fmt.Fprintf(w, "ERROR %v\n", err)
+		return exitError
+	}
+	return code
+}
+
+// runLinkTestsCI runs the tests through the worker pool, printing one line
+// per result in file order, and stops once the failure limit is reached
+func runLinkTestsCI(w io.Writer, testFile string, tests []LinkTest, opts ciOptions) []LinkTestResult {
+	ctx, cancel := runContext()
+	defer cancel()
+
+	var results []LinkTestResult
+	failures := 0
+	runOrdered(ctx, len(tests), func(ctx context.Context, i int) LinkTestResult {
+		return runLinkTest(ctx, tests[i])
+	}, func(i int, result LinkTestResult) bool {
+		results = append(results, result)
+		fmt.Fprintln(w, formatCIResult(testFile, i+1, len(tests), result))
+
+		if !result.Success {
+			failures++
+			if limit := opts.stopAfter(); limit > 0 && failures >= limit {
+				return false
+			}
+		}
+		return true
+	})
+	return results
+}
+
+// ciResultLabel returns PASS, FAIL or ERROR for a result
+func ciResultLabel(result LinkTestResult) string {
+	switch {
+	case result.Status == 0:
+		return "ERROR"
+	case result.Success:
+		return "PASS"
+	default:
+		return "FAIL"
+	}
+}
+
+// formatCIResult renders one result as plain text
+func formatCIResult(testFile string, index, total int, result LinkTestResult) string {
+	test := result.Test
+	location := fmt.Sprintf("#%d/%d", index, total)
+	if test.Line > 0 {
+		location = fmt.Sprintf("%s:%d", testFile, test.Line)
+	}
+
+	var fields []string
+	for _, field := range []string{ciResultLabel(result), location, test.Name, test.Agent, test.Country, linkTestMethod(test), linkTestURL(test)} {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	line := fmt.Sprintf("%s -> %d %s (expected %d %s)",
+		strings.Join(fields, " "),
+		result.Status, describeChain(result.Result, result.Chain, result.ChainIssue),
+		test.ExpectedStatus, test.ExpectedResult)
+	if !result.Success && result.Reason != "" {
+		line += "\n    " + result.Reason
+	}
+	return line
+}
+
+// ciSummary prints the totals and returns the exit code
+func ciSummary(w io.Writer, results []LinkTestResult, total int) int {
+	passed, failed, errored := 0, 0, 0
+	for _, result := range results {
+		switch ciResultLabel(result) {
+		case "PASS":
+			passed++
+		case "FAIL":
+			failed++
+		default:
+			errored++
+		}
+	}
+
+	summary := fmt.Sprintf("%d passed, %d failed, %d errors", passed, failed, errored)
+	if skipped := total - len(results); skipped > 0 {
+		summary += fmt.Sprintf(", %d not run (stopped early)", skipped)
+	}
+	fmt.Fprintln(w, summary)
+
+	switch {
+	case errored > 0:
+		return exitError
+	case failed > 0:
+		return exitFail
+	default:
+		return exitPass
+	}
+}
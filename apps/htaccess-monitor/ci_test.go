@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newCIServer returns a server that redirects /redirect to /uk/ and answers
+// every other path with 200
+func newCIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			w.Header().Set("Location", "/uk/")
+			w.WriteHeader(302)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestRunCI tests CI mode output and exit codes
+func TestRunCI(t *testing.T) {
+	server := newCIServer(t)
+	pass := fmt.Sprintf("Browser,US,%s/,200,No redirect\n", server.URL)
+	fail := fmt.Sprintf("Browser,DE,%s/redirect,302,/de/\n", server.URL)
+	unreachable := "Browser,US,http://127.0.0.1:1/,200,No redirect\n"
+
+	tests := []struct {
+		name     string
+		rows     string
+		opts     ciOptions
+		wantCode int
+		want     []string
+	}{
+		{"all pass", pass + pass, ciOptions{}, exitPass, []string{"PASS ", "2 passed, 0 failed, 0 errors"}},
+		{"failure", pass + fail + pass, ciOptions{}, exitFail, []string{"FAIL ", `contains: Location "/uk/" does not contain "/de/"`, "2 passed, 1 failed, 0 errors"}},
+		{"connection error", pass + unreachable + fail, ciOptions{}, exitError, []string{"ERROR ", "1 passed, 1 failed, 1 errors"}},
+		{"fail fast", fail + pass + fail, ciOptions{failFast: true}, exitFail, []string{"0 passed, 1 failed, 0 errors, 2 not run (stopped early)"}},
+		{"max failures", fail + pass + fail + fail, ciOptions{maxFailures: 2}, exitFail, []string{"1 passed, 2 failed, 0 errors, 1 not run (stopped early)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, "links.testing", "Agent,Country,URL,Expected status,Expected result\n"+tt.rows)
+
+			var out bytes.Buffer
+			if code := runCI(&out, path, tt.opts); code != tt.wantCode {
+				t.Errorf("runCI() = %d, want %d\n%s", code, tt.wantCode, out.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("runCI() output missing %q:\n%s", want, out.String())
+				}
+			}
+			if strings.Contains(out.String(), "\x1b") {
+				t.Errorf("runCI() output contains escape sequences:\n%q", out.String())
+			}
+			if !strings.Contains(out.String(), path+":2 ") {
+				t.Errorf("runCI() output does not reference test file lines:\n%s", out.String())
+			}
+		})
+	}
+}
+
+// TestRunCIInvalidFile tests that unreadable or empty test files exit with 2
+func TestRunCIInvalidFile(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing file", "/nonexistent/links.testing"},
+		{"no tests", writeTestFile(t, "links.testing", "Agent,Country,URL,Expected status,Expected result\n")},
+		{"invalid row", writeTestFile(t, "links.testing", "Browser,US,http://example.com/,abc,No redirect\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := runCI(&out, tt.path, ciOptions{}); code != exitError {
+				t.Errorf("runCI() = %d, want %d\n%s", code, exitError, out.String())
+			}
+			if !strings.HasPrefix(out.String(), "ERROR ") {
+				t.Errorf("runCI() output = %q, want an ERROR line", out.String())
+			}
+		})
+	}
+}
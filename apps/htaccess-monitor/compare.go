@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// compareInput is a request evaluated against both rule sets
+type compareInput struct {
+	Key     string // e.g. "👤 Regular Users · DE · /test-content"
+	URL     string
+	Headers map[string]string
+}
+
+// compareChange is an input whose response differs between the rule sets
+type compareChange struct {
+	Input        compareInput
+	Old, New     string // outcomes, e.g. "302 /de/"
+	Acknowledged bool   // matched by the allowlist
+}
+
+// String describes the change, e.g. "👤 Regular Users · DE · /: 302 /de/ → 200"
+func (c compareChange) String() string {
+	return fmt.Sprintf("%s: %s → %s", c.Input.Key, c.Old, c.New)
+}
+
+// loadRuleSource parses a .htaccess file, or one at a git revision given as
+// rev:path when no such file exists
+func loadRuleSource(source string) (*RuleSet, error) {
+	_, statErr := os.Stat(source)
+	rev, path, ok := strings.Cut(source, ":")
+	if statErr == nil || !ok || rev == "" || path == "" {
+		return parseHtaccessFile(source)
+	}
+	file, err := newGitFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.resolve(rev); err != nil {
+		return nil, err
+	}
+	content, err := file.show(rev)
+	if err != nil {
+		return nil, err
+	}
+	rs := parseHtaccess(bytes.NewReader(content))
+	rs.Path = source
+	return rs, nil
+}
+
+// comparePaths returns the pages of the config followed by the paths of the
+// test cases, each once
+func comparePaths(cfg *Config, tests []LinkTest) []string {
+	var paths []string
+	seen := map[string]bool{}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, page := range cfg.Pages {
+		add(page.Path)
+	}
+	for _, test := range tests {
+		add(requestPath(linkTestURL(test)))
+	}
+	return paths
+}
+
+// compareInputs lays out every path requested by every agent from every
+// country, followed by the special cases
+func compareInputs(cfg *Config, paths []string) []compareInput {
+	var inputs []compareInput
+	for _, path := range paths {
+		for _, agent := range cfg.Agents {
+			title := strings.TrimSpace(agent.Icon + " " + agent.Name)
+			for _, country := range cfg.Countries {
+				inputs = append(inputs, compareInput{
+					Key:     historyKey(title, country.Code, path),
+					URL:     cfg.pageURL(path),
+					Headers: testHeaders(country.Code, agent.UserAgent),
+				})
+			}
+		}
+	}
+	for _, testCase := range cfg.SpecialCases {
+		inputs = append(inputs, compareInput{
+			Key:     historyKey("Special Cases", "", testCase.Name),
+			URL:     cfg.pageURL(testCase.Path),
+			Headers: testCase.Headers,
+		})
+	}
+	return inputs
+}
+
+// evaluateInput describes the response of a rule set to an input
+func evaluateInput(rs *RuleSet, input compareInput) string {
+	req, err := newTestRequest(input.URL, input.Headers)
+	if err != nil {
+		return "Request failed"
+	}
+	docRoot := offlineDocRoot
+	if docRoot == nil {
+		docRoot = dockerDocRoot
+	}
+	result := rs.Evaluate(newRewriteRequest(req), docRoot)
+	header := http.Header{}
+	if result.Location != "" {
+		header.Set("Location", result.Location)
+	}
+	return responseOutcome(input.URL, result.Status, "", header)
+}
+
+// compareRuleSets evaluates every input against both rule sets and returns
+// the ones whose status or Location differs, in input order
+func compareRuleSets(before, after *RuleSet, inputs []compareInput) []compareChange {
+	var changes []compareChange
+	runOrdered(context.Background(), len(inputs), func(_ context.Context, i int) compareChange {
+		return compareChange{Input: inputs[i], Old: evaluateInput(before, inputs[i]), New: evaluateInput(after, inputs[i])}
+	}, func(_ int, change compareChange) bool {
+		if change.Old != change.New {
+			changes = append(changes, change)
+		}
+		return true
+	})
+	return changes
+}
+
+// allowEntry is a line of an allowlist: a pattern matching a change line or
+// only its input, where * stands for any text
+type allowEntry struct {
+	Line    int
+	Pattern string
+	re      *regexp.Regexp
+	used    bool
+}
+
+// parseAllowlist reads allowlist entries, one per line; blank lines and
+// lines starting with # are ignored
+func parseAllowlist(r io.Reader) ([]*allowEntry, error) {
+	var entries []*allowEntry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		entries = append(entries, &allowEntry{
+			Line:    n,
+			Pattern: line,
+			re:      regexp.MustCompile("^" + strings.Join(parts, ".*") + "$"),
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// acknowledge marks the changes matched by an allowlist entry, either as a
+// whole line or by their input
+func acknowledge(changes []compareChange, entries []*allowEntry) {
+	for i := range changes {
+		for _, entry := range entries {
+			if entry.re.MatchString(changes[i].String()) || entry.re.MatchString(changes[i].Input.Key) {
+				changes[i].Acknowledged, entry.used = true, true
+			}
+		}
+	}
+}
+
+// writeCompare prints the changes and returns how many are unacknowledged
+func writeCompare(w io.Writer, changes []compareChange, entries []*allowEntry, allowFile string) int {
+	unacknowledged := 0
+	for _, change := range changes {
+		icon := "❗"
+		if change.Acknowledged {
+			icon = "✓"
+		} else {
+			unacknowledged++
+		}
+		fmt.Fprintf(w, "   %s %s\n", icon, change)
+	}
+	for _, entry := range entries {
+		if !entry.used {
+			fmt.Fprintf(w, "⚠️  %s:%d matches no change: %s\n", allowFile, entry.Line, entry.Pattern)
+		}
+	}
+	return unacknowledged
+}
+
+// runCompareCommand prints the behavioral difference between two versions
+// of the .htaccess over the whole test matrix
+func runCompareCommand(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor compare [flags] <old> <new>\n\n")
+		fmt.Fprintf(fs.Output(), "Evaluates both .htaccess versions in-process for every page and test file path,\nagent and country of the matrix and prints the inputs whose status or Location\nchanged. A version is a file or, for a pre-commit hook, rev:path (HEAD:.htaccess).\n\n")
+		fs.PrintDefaults()
+	}
+	var testFile = fs.String("test", "", "Also compare the paths of this test file")
+	var allowFile = fs.String("allow", "", "Allowlist of expected changes: change lines or inputs, * matches any text")
+	var fail = fs.Bool("fail", false, "Exit 1 when a change is not acknowledged in the allowlist")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+
+	var ruleSets [2]*RuleSet
+	for i, source := range fs.Args() {
+		rs, err := loadRuleSource(source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error reading %s: %v\n", source, err)
+			return exitError
+		}
+		ruleSets[i] = rs
+	}
+	var tests []LinkTest
+	if *testFile != "" {
+		var err error
+		if tests, err = parseLinkTestFile(*testFile); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error reading test file: %v\n", err)
+			return exitError
+		}
+	}
+	var entries []*allowEntry
+	if *allowFile != "" {
+		f, err := os.Open(*allowFile)
+		if err == nil {
+			entries, err = parseAllowlist(f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error reading allowlist: %v\n", err)
+			return exitError
+		}
+	}
+
+	inputs := compareInputs(monitorConfig, comparePaths(monitorConfig, tests))
+	changes := compareRuleSets(ruleSets[0], ruleSets[1], inputs)
+	acknowledge(changes, entries)
+
+	fmt.Printf("🔀 %s → %s: %d of %d inputs changed\n", fs.Arg(0), fs.Arg(1), len(changes), len(inputs))
+	unacknowledged := writeCompare(os.Stdout, changes, entries, *allowFile)
+	if len(changes) == 0 {
+		fmt.Println("✅ Both versions answer every input alike")
+		return exitPass
+	}
+	if *allowFile != "" {
+		fmt.Printf("%d acknowledged, %d not in %s\n", len(changes)-unacknowledged, unacknowledged, *allowFile)
+	}
+	if *fail && unacknowledged > 0 {
+		fmt.Printf("❌ %d change(s) not acknowledged\n", unacknowledged)
+		return exitFail
+	}
+	return exitPass
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// compareConfig is a small matrix: two pages, one agent, three countries
+func compareConfig() *Config {
+	cfg := defaultConfig()
+	cfg.Pages = []PageConfig{{Name: "Home", Path: "/"}, {Name: "Test", Path: "/test-content"}}
+	cfg.Agents = cfg.Agents[:1]
+	cfg.Countries = []Country{{Code: "de"}, {Code: "at"}, {Code: "us"}}
+	cfg.SpecialCases = []SpecialCaseConfig{{Name: "WordPress Admin", Path: "/wp-admin/", Headers: map[string]string{"X-Test-Country": "DE"}}}
+	return cfg
+}
+
+// TestComparePaths tests that config pages come first and paths are unique
+func TestComparePaths(t *testing.T) {
+	tests := []LinkTest{
+		{URL: "http://localhost:8080/test-content"},
+		{URL: "http://localhost:8080/wp-json/wp/v2/posts", Query: map[string]string{"page": "2"}},
+		{URL: "http://localhost:8080/wp-json/wp/v2/posts?page=2"},
+	}
+	got := strings.Join(comparePaths(compareConfig(), tests), " ")
+	if want := "/ /test-content /wp-json/wp/v2/posts?page=2"; got != want {
+		t.Errorf("comparePaths() = %s, want %s", got, want)
+	}
+}
+
+// TestCompareRuleSets tests the behavioral diff of two versions; the test
+// document root has no /test-content, so it is a 404 without a redirect
+func TestCompareRuleSets(t *testing.T) {
+	before := parseHtaccess(strings.NewReader(`RewriteEngine On
+RewriteRule ^wp-admin/ - [L]
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^(DE|AT)$
+RewriteRule ^(.*)$ /%1/$1 [R=302,L]
+`))
+	after := parseHtaccess(strings.NewReader(`RewriteEngine On
+RewriteCond %{REQUEST_URI} !^/test-content
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^(DE|AT)$
+RewriteRule ^(.*)$ /%1/$1 [R=301,L]
+`))
+	cfg := compareConfig()
+	inputs := compareInputs(cfg, comparePaths(cfg, nil))
+	if len(inputs) != 7 {
+		t.Fatalf("compareInputs() = %d inputs, want 7", len(inputs))
+	}
+
+	var got []string
+	for _, change := range compareRuleSets(before, after, inputs) {
+		got = append(got, change.String())
+	}
+	want := []string{
+		"👤 Regular Users · DE · /: 302 /DE/ → 301 /DE/",
+		"👤 Regular Users · AT · /: 302 /AT/ → 301 /AT/",
+		"👤 Regular Users · DE · /test-content: 302 /DE/test-content → 404",
+		"👤 Regular Users · AT · /test-content: 302 /AT/test-content → 404",
+		"Special Cases · WordPress Admin: 200 → 301 /DE/wp-admin/",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("compareRuleSets() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestAllowlist tests acknowledging changes by line or by input and
+// reporting entries that match nothing
+func TestAllowlist(t *testing.T) {
+	entries, err := parseAllowlist(strings.NewReader(`# moved to 301
+* · /: 302 * → 301 *
+
+Special Cases · WordPress Admin
+* · FR · *
+`))
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("parseAllowlist() = %d entries, %v", len(entries), err)
+	}
+	changes := []compareChange{
+		{Input: compareInput{Key: "👤 Regular Users · DE · /"}, Old: "302 /de/", New: "301 /de/"},
+		{Input: compareInput{Key: "👤 Regular Users · DE · /test-content"}, Old: "302 /de/test-content", New: "200"},
+		{Input: compareInput{Key: "Special Cases · WordPress Admin"}, Old: "200", New: "301 /de/wp-admin/"},
+	}
+	acknowledge(changes, entries)
+	if !changes[0].Acknowledged || changes[1].Acknowledged || !changes[2].Acknowledged {
+		t.Errorf("acknowledged = %v %v %v, want true false true", changes[0].Acknowledged, changes[1].Acknowledged, changes[2].Acknowledged)
+	}
+
+	var out bytes.Buffer
+	if n := writeCompare(&out, changes, entries, "allow.txt"); n != 1 {
+		t.Errorf("writeCompare() = %d unacknowledged, want 1", n)
+	}
+	for _, want := range []string{
+		"   ✓ 👤 Regular Users · DE · /: 302 /de/ → 301 /de/\n",
+		"   ❗ 👤 Regular Users · DE · /test-content: 302 /de/test-content → 200\n",
+		"⚠️  allow.txt:5 matches no change: * · FR · *\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestLoadRuleSource tests reading a version from a file and from git
+func TestLoadRuleSource(t *testing.T) {
+	file, commits := newBisectRepo(t, bisectRules("/de/", ""), bisectRules("/at/", ""))
+	path := filepath.Join(file.dir, file.name)
+
+	current, err := loadRuleSource(path)
+	if err != nil || len(current.Rules) != 1 || !strings.Contains(current.Rules[0].Substitution, "/at/") {
+		t.Fatalf("loadRuleSource(file) = %+v, %v", current, err)
+	}
+	old, err := loadRuleSource(commits[0] + ":" + path)
+	if err != nil || len(old.Rules) != 1 || !strings.Contains(old.Rules[0].Substitution, "/de/") {
+		t.Errorf("loadRuleSource(rev:path) = %+v, %v", old, err)
+	}
+	if _, err := loadRuleSource("no-such-rev:" + path); err == nil {
+		t.Error("loadRuleSource() of an unknown revision expected error")
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is loaded automatically when it exists in the working directory
+const defaultConfigFile = ".htmonitor.yaml"
+
+// googleBotUA is the User-Agent sent for the default Google Bot agent
+const googleBotUA = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+
+// Config describes the monitor's test matrix: every page is requested by
+// every agent from every country, followed by the special cases
+type Config struct {
+	BaseURL      string              `yaml:"base_url"`
+	Pages        []PageConfig        `yaml:"pages"`
+	Agents       []AgentConfig       `yaml:"agents"`
+	Countries    []Country           `yaml:"countries"`
+	SpecialCases []SpecialCaseConfig `yaml:"special_cases"`
+	Expectations []ExpectationConfig `yaml:"expectations"`
+	Aliases      map[string]string   `yaml:"aliases"`     // ISO code → URL prefix, e.g. gb: uk
+	UserAgents   []UserAgent         `yaml:"user_agents"` // added to, or overriding, the built-in catalog
+}
+
+// PageConfig is a page of the test matrix
+type PageConfig struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+}
+
+// AgentConfig is a named User-Agent of the test matrix; an empty
+// UserAgent sends Go's default one. Agent names a catalog entry that fills
+// the fields left empty
+type AgentConfig struct {
+	Name      string `yaml:"name"`
+	Icon      string `yaml:"icon"`
+	UserAgent string `yaml:"user_agent"`
+	Agent     string `yaml:"agent"`
+}
+
+// SpecialCaseConfig is a single request with custom headers
+type SpecialCaseConfig struct {
+	Name    string            `yaml:"name"`
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers"`
+	Expect  *Outcome          `yaml:"expect"`
+}
+
+// Outcome is an expected response: a status code and, for redirects, a target
+// path template where {country} is the country code and {path} the page path
+type Outcome struct {
+	Status int    `yaml:"status"`
+	Target string `yaml:"target"`
+	Match  string `yaml:"match"` // redirect target matcher, "contains" when empty
+}
+
+// ExpectationConfig declares the outcome of the matrix cells it selects;
+// empty selectors match every page, agent or country, and later entries
+// override earlier ones
+type ExpectationConfig struct {
+	Page      string   `yaml:"page"` // page name or path
+	Agent     string   `yaml:"agent"`
+	Countries []string `yaml:"countries"`
+	Outcome   `yaml:",inline"`
+}
+
+// monitorConfig is the test matrix used by the monitor
+var monitorConfig = defaultConfig()
+
+// defaultConfig returns the built-in test matrix for the Docker test environment
+func defaultConfig() *Config {
+	return &Config{
+		BaseURL: "http://localhost:8080",
+		Pages: []PageConfig{
+			{Name: "Home Page", Path: "/"},
+			{Name: "Test Content Page", Path: "/test-content"},
+		},
+		Agents: []AgentConfig{
+			{Name: "Regular Users", Icon: "👤"},
+			{Name: "Google Bot", Icon: "🤖", UserAgent: googleBotUA},
+		},
+		Countries: append([]Country(nil), countries...),
+		Aliases:   maps.Clone(defaultCountryAliases),
+		SpecialCases: []SpecialCaseConfig{
+			{Name: "No Country Set", Path: "/", Headers: map[string]string{}},
+			{Name: "Empty Country Code", Path: "/", Headers: map[string]string{"X-Test-Country": ""}},
+			{Name: "WordPress Admin", Path: "/wp-admin/", Headers: map[string]string{"X-Test-Country": "DE"}},
+			{Name: "Robots.txt", Path: "/robots.txt", Headers: map[string]string{"X-Test-Country": "DE"}},
+			{Name: "Sitemap", Path: "/sitemap_index.xml", Headers: map[string]string{"X-Test-Country": "DE"}},
+		},
+	}
+}
+
+// loadConfig reads a YAML test matrix; omitted sections keep their defaults
+func loadConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return cfg, nil
+}
+
+// parseConfig decodes and validates a YAML test matrix
+func parseConfig(data []byte) (*Config, error) {
+	cfg := defaultConfig()
+	specialCases, aliases := cfg.SpecialCases, cfg.Aliases
+	cfg.SpecialCases, cfg.Aliases = nil, nil
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	// An explicit empty list disables the special cases
+	if cfg.SpecialCases == nil {
+		cfg.SpecialCases = specialCases
+	}
+	// An explicit empty map disables the aliases
+	if cfg.Aliases == nil {
+		cfg.Aliases = aliases
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate checks that every entry of the matrix can be requested and displayed
+func (c *Config) validate() error {
+	c.BaseURL = strings.TrimRight(c.BaseURL, "/")
+	if !strings.HasPrefix(c.BaseURL, "http://") && !strings.HasPrefix(c.BaseURL, "https://") {
+		return fmt.Errorf("base_url %q must start with http:// or https://", c.BaseURL)
+	}
+	if len(c.Pages) == 0 || len(c.Agents) == 0 || len(c.Countries) == 0 {
+		return fmt.Errorf("pages, agents and countries must not be empty")
+	}
+	for i, page := range c.Pages {
+		if !strings.HasPrefix(page.Path, "/") {
+			return fmt.Errorf("pages[%d]: path %q must start with /", i, page.Path)
+		}
+		if page.Name == "" {
+			c.Pages[i].Name = page.Path
+		}
+	}
+	if err := c.validateUserAgents(); err != nil {
+		return err
+	}
+	for i, agent := range c.Agents {
+		if agent.Name == "" {
+			return fmt.Errorf("agents[%d]: name is required", i)
+		}
+	}
+	if err := c.validateAliases(); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for i, country := range c.Countries {
+		code := strings.ToLower(country.Code)
+		if len(code) != 2 {
+			return fmt.Errorf("countries[%d]: code %q must have two letters", i, country.Code)
+		}
+		known, ok := c.lookupCountry(code)
+		if !ok {
+			return fmt.Errorf("countries[%d]: unknown country code %q", i, country.Code)
+		}
+		if seen[code] {
+			return fmt.Errorf("countries[%d]: duplicate code %q", i, country.Code)
+		}
+		seen[code] = true
+		c.Countries[i].Code = code
+		if country.Name == "" {
+			c.Countries[i].Name = known.Name
+		}
+		if country.Flag == "" {
+			c.Countries[i].Flag = known.Flag
+		}
+		if country.IP == "" {
+			c.Countries[i].IP = builtinCountryIP(code)
+		} else if net.ParseIP(country.IP) == nil {
+			return fmt.Errorf("countries[%d]: ip %q is not an IP address", i, country.IP)
+		}
+	}
+	for i, special := range c.SpecialCases {
+		if special.Name == "" {
+			return fmt.Errorf("special_cases[%d]: name is required", i)
+		}
+		if !strings.HasPrefix(special.Path, "/") {
+			return fmt.Errorf("special_cases[%d]: path %q must start with /", i, special.Path)
+		}
+		if special.Expect != nil {
+			if err := special.Expect.validate(); err != nil {
+				return fmt.Errorf("special_cases[%d]: expect: %w", i, err)
+			}
+		}
+	}
+	for i, exp := range c.Expectations {
+		if err := exp.validate(); err != nil {
+			return fmt.Errorf("expectations[%d]: %w", i, err)
+		}
+		for j, code := range exp.Countries {
+			if len(code) != 2 {
+				return fmt.Errorf("expectations[%d]: country %q must have two letters", i, code)
+			}
+			if _, ok := c.lookupCountry(code); !ok {
+				return fmt.Errorf("expectations[%d]: unknown country code %q", i, code)
+			}
+			c.Expectations[i].Countries[j] = strings.ToLower(code)
+		}
+	}
+	return nil
+}
+
+// validate checks the expected status code and matcher
+func (o Outcome) validate() error {
+	if o.Status < 100 || o.Status > 599 {
+		return fmt.Errorf("status %d is not an HTTP status code", o.Status)
+	}
+	return validateMatcher(LinkTest{Match: o.Match, ExpectedResult: defaultExpectedResult(o.Status, o.Target)})
+}
+
+// expectation returns the outcome declared for a matrix cell, or nil
+func (c *Config) expectation(page PageConfig, agent AgentConfig, country Country) *Outcome {
+	var outcome *Outcome
+	for i, exp := range c.Expectations {
+		if exp.Page != "" && exp.Page != page.Name && exp.Page != page.Path {
+			continue
+		}
+		if exp.Agent != "" && exp.Agent != agent.Name {
+			continue
+		}
+		if len(exp.Countries) > 0 && !slices.Contains(exp.Countries, country.Code) {
+			continue
+		}
+		outcome = &c.Expectations[i].Outcome
+	}
+	return outcome
+}
+
+// linkTest turns an outcome into a LinkTest so matrix cells are judged like
+// links.testing rows
+func (o Outcome) linkTest(url, agent, country, path string) LinkTest {
+	expected := strings.NewReplacer("{country}", strings.ToLower(country), "{path}", path).Replace(o.Target)
+	return LinkTest{
+		Agent:          agent,
+		Country:        country,
+		URL:            url,
+		ExpectedStatus: o.Status,
+		ExpectedResult: defaultExpectedResult(o.Status, expected),
+		Match:          o.Match,
+	}
+}
+
+// resolveConfig loads the -config file, or .htmonitor.yaml when present
+func resolveConfig(filename string) (*Config, error) {
+	if filename == "" {
+		if _, err := os.Stat(defaultConfigFile); err != nil {
+			return defaultConfig(), nil
+		}
+		filename = defaultConfigFile
+	}
+	return loadConfig(filename)
+}
+
+// pageURL returns the absolute URL of a path on the base URL
+func (c *Config) pageURL(path string) string {
+	return c.BaseURL + path
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseConfig tests YAML decoding and defaults
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+base_url: https://staging.example.com/
+pages:
+  - {name: Shop, path: /shop}
+  - {path: /blog}
+agents:
+  - {name: Bing, user_agent: bingbot/2.0}
+countries:
+  - {code: PL, name: Poland, flag: 🇵🇱}
+  - {code: se}
+`))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+
+	if cfg.BaseURL != "https://staging.example.com" {
+		t.Errorf("BaseURL = %q, want trailing slash trimmed", cfg.BaseURL)
+	}
+	if len(cfg.Pages) != 2 || cfg.Pages[1].Name != "/blog" {
+		t.Errorf("Pages = %+v, want 2 pages with the path as default name", cfg.Pages)
+	}
+	if len(cfg.Agents) != 1 || cfg.Agents[0].UserAgent != "bingbot/2.0" {
+		t.Errorf("Agents = %+v", cfg.Agents)
+	}
+	want := []Country{{Code: "pl", Flag: "🇵🇱", Name: "Poland"}, {Code: "se", Flag: "🇸🇪", Name: "Sweden"}}
+	if !reflect.DeepEqual(cfg.Countries, want) {
+		t.Errorf("Countries = %+v, want %+v", cfg.Countries, want)
+	}
+	if len(cfg.SpecialCases) != len(defaultConfig().SpecialCases) {
+		t.Errorf("omitted special_cases should keep the defaults, got %d", len(cfg.SpecialCases))
+	}
+
+	cfg, err = parseConfig([]byte("special_cases: []\n"))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	if len(cfg.SpecialCases) != 0 || len(cfg.Countries) != len(countries) {
+		t.Errorf("special_cases: [] = %d cases, %d countries; want 0 and the defaults", len(cfg.SpecialCases), len(cfg.Countries))
+	}
+}
+
+// TestParseConfigInvalid tests config validation
+func TestParseConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errText string
+	}{
+		{"unknown field", "base_ulr: http://x", "base_ulr"},
+		{"bad base url", "base_url: localhost:8080", "base_url"},
+		{"relative page", "pages: [{path: shop}]", "pages[0]"},
+		{"no agents", "agents: []", "must not be empty"},
+		{"unnamed agent", "agents: [{user_agent: x}]", "agents[0]"},
+		{"bad country", "countries: [{code: usa}]", "countries[0]"},
+		{"duplicate country", "countries: [{code: de}, {code: DE}]", "countries[1]"},
+		{"unknown country", "countries: [{code: xx}]", "countries[0]: unknown"},
+		{"unknown expectation country", "expectations: [{countries: [zz], status: 200}]", "expectations[0]: unknown"},
+		{"alias of unknown code", "aliases: {xx: uk}", "aliases"},
+		{"duplicate alias prefix", "aliases: {gb: uk, ua: uk}", "aliases"},
+		{"alias prefix of another country", "aliases: {at: de}", "aliases: prefix \"de\" of at is the code of Germany"},
+		{"swapped alias prefixes", "aliases: {at: de, de: at}", "is the code of"},
+		{"bad country ip", "countries: [{code: de, ip: 300.1.1.1}]", "countries[0]: ip"},
+		{"unnamed special case", "special_cases: [{path: /}]", "special_cases[0]"},
+		{"bad special status", "special_cases: [{name: x, path: /, expect: {status: 0}}]", "special_cases[0]: expect"},
+		{"bad expectation status", "expectations: [{status: 1000}]", "expectations[0]"},
+		{"bad expectation country", "expectations: [{countries: [deu], status: 200}]", "expectations[0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfig([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("parseConfig() error = %v, want mention of %q", err, tt.errText)
+			}
+		})
+	}
+}
+
+// TestResolveConfig tests loading the bundled .htmonitor.yaml
+func TestResolveConfig(t *testing.T) {
+	cfg, err := resolveConfig(defaultConfigFile)
+	if err != nil {
+		t.Fatalf("resolveConfig() error = %v", err)
+	}
+	// Apart from the expectations it describes the built-in matrix
+	if len(cfg.Expectations) == 0 {
+		t.Errorf("%s should declare expectations", defaultConfigFile)
+	}
+	cfg.Expectations = nil
+	for i := range cfg.SpecialCases {
+		cfg.SpecialCases[i].Expect = nil
+	}
+	if !reflect.DeepEqual(cfg, defaultConfig()) {
+		t.Errorf("%s should describe the built-in matrix, got %+v", defaultConfigFile, cfg)
+	}
+
+	if _, err := resolveConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("resolveConfig() expected error for an explicit missing file")
+	}
+}
+
+// TestBundledExpectations tests that the bundled .htmonitor.yaml declares
+// what the repository's .htaccess does, so the monitor starts all green
+func TestBundledExpectations(t *testing.T) {
+	cfg, err := resolveConfig(defaultConfigFile)
+	if err != nil {
+		t.Fatalf("resolveConfig() error = %v", err)
+	}
+	oldConfig, oldTransport := monitorConfig, backendTransport
+	defer func() { monitorConfig, backendTransport = oldConfig, oldTransport }()
+	monitorConfig = cfg
+	backendTransport = newRewriteBackend("../../.htaccess", nil)
+
+	for _, group := range runMatrix(cfg).Groups {
+		for _, result := range group.Results {
+			if result.Expect != nil && !result.Pass {
+				t.Errorf("%s %s %s: %s", group.Title, result.Country.Code, requestPath(result.URL), result.Reason)
+			}
+		}
+	}
+}
+
+// TestRunMatrix tests that the grid follows the configured matrix
+func TestRunMatrix(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "bot" {
+			w.WriteHeader(403)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		BaseURL:   server.URL,
+		Pages:     []PageConfig{{Name: "Home", Path: "/"}, {Name: "Shop", Path: "/shop"}},
+		Agents:    []AgentConfig{{Name: "Browser"}, {Name: "Bot", UserAgent: "bot"}, {Name: "Other"}},
+		Countries: []Country{{Code: "de", Name: "Germany"}, {Code: "fr", Name: "France"}},
+		SpecialCases: []SpecialCaseConfig{
+			{Name: "Header", Path: "/x", Headers: map[string]string{"X-Test-Country": "IT"}},
+		},
+	}
+
+	ts := runMatrix(cfg)
+	if len(ts.Groups) != 7 {
+		t.Fatalf("runMatrix() returned %d groups, want 2 pages x 3 agents + special cases", len(ts.Groups))
+	}
+	shop := ts.Groups[4]
+	if shop.Section != "Shop: "+server.URL+"/shop" || shop.Title != "Bot" {
+		t.Errorf("group 4 = %q / %q, want Shop section, Bot table", shop.Section, shop.Title)
+	}
+	if len(shop.Results) != 2 || shop.Results[1].Status != 403 || shop.Results[1].Country.Code != "fr" {
+		t.Errorf("group 4 results = %+v", shop.Results)
+	}
+	if special := ts.Groups[6]; !special.Special || special.Results[0].Country.Name != "Header" {
+		t.Errorf("last group = %+v, want special cases", special)
+	}
+
+	tables := createTables(ts, 200)
+	if len(tables) != len(ts.Groups) {
+		t.Errorf("createTables() returned %d tables, want %d", len(tables), len(ts.Groups))
+	}
+	if got := gridColumns(ts, 300); got != 3 {
+		t.Errorf("gridColumns(300) = %d, want 3", got)
+	}
+	if got := gridColumns(ts, 100); got != 2 {
+		t.Errorf("gridColumns(100) = %d, want 2", got)
+	}
+
+	m := model{testSuite: ts, tables: tables, ready: true, width: 200, focus: 4}
+	if result, ok := m.selectedResult(); !ok || result.Country.Code != "de" {
+		t.Errorf("selectedResult() = %+v, %v", result, ok)
+	}
+	if view := m.View(); !strings.Contains(view, "Shop: "+server.URL+"/shop") {
+		t.Error("View() should render a header for each configured page")
+	}
+}
+
+// TestConfigExpectation tests expectation selection and target templates
+func TestConfigExpectation(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+expectations:
+  - {status: 302, target: "/{country}{path}"}
+  - {countries: [US], status: 200}
+  - {page: Shop, agent: Google Bot, status: 403}
+`))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	home, shop := PageConfig{Name: "Home", Path: "/"}, PageConfig{Name: "Shop", Path: "/shop"}
+	browser, bot := AgentConfig{Name: "Regular Users"}, AgentConfig{Name: "Google Bot"}
+	de, us := Country{Code: "de"}, Country{Code: "us"}
+
+	tests := []struct {
+		name     string
+		page     PageConfig
+		agent    AgentConfig
+		country  Country
+		status   int
+		expected string
+	}{
+		{"default", shop, browser, de, 302, "/de/shop"},
+		{"country override", home, browser, us, 200, "No redirect"},
+		{"page and agent override", shop, bot, us, 403, "No redirect"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := cfg.expectation(tt.page, tt.agent, tt.country)
+			if outcome == nil {
+				t.Fatal("expectation() = nil")
+			}
+			test := outcome.linkTest("http://x"+tt.page.Path, tt.agent.Name, tt.country.Code, tt.page.Path)
+			if test.ExpectedStatus != tt.status || test.ExpectedResult != tt.expected {
+				t.Errorf("linkTest() = %d %q, want %d %q", test.ExpectedStatus, test.ExpectedResult, tt.status, tt.expected)
+			}
+		})
+	}
+
+	if outcome := defaultConfig().expectation(home, browser, de); outcome != nil {
+		t.Errorf("expectation() without expectations = %+v, want nil", outcome)
+	}
+}
+
+// TestRunMatrixExpectations tests PASS/FAIL judgement and failure navigation
+func TestRunMatrixExpectations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Test-Country") {
+		case "DE":
+			w.Header().Set("Location", "http://"+r.Host+"/de"+r.URL.Path)
+			w.WriteHeader(302)
+		case "FR":
+			w.Header().Set("Location", "http://"+r.Host+"/uk"+r.URL.Path)
+			w.WriteHeader(302)
+		default:
+			w.WriteHeader(200)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		BaseURL:   server.URL,
+		Pages:     []PageConfig{{Name: "Home", Path: "/"}, {Name: "Shop", Path: "/shop"}},
+		Agents:    []AgentConfig{{Name: "Browser"}},
+		Countries: []Country{{Code: "us", Name: "United States"}, {Code: "de", Name: "Germany"}, {Code: "fr", Name: "France"}},
+		SpecialCases: []SpecialCaseConfig{
+			{Name: "Forbidden", Path: "/", Expect: &Outcome{Status: 403}},
+		},
+		Expectations: []ExpectationConfig{
+			{Outcome: Outcome{Status: 302, Target: "/{country}{path}"}},
+			{Countries: []string{"us"}, Outcome: Outcome{Status: 200}},
+		},
+	}
+
+	ts := runMatrix(cfg)
+	var got []string
+	for _, group := range ts.Groups {
+		for _, result := range group.Results {
+			got = append(got, checkText(result))
+		}
+	}
+	want := []string{"✅ PASS", "✅ PASS", "❌ FAIL", "✅ PASS", "✅ PASS", "❌ FAIL", "❌ FAIL"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checks = %v, want %v", got, want)
+	}
+	if ts.Failures() != 3 {
+		t.Errorf("Failures() = %d, want 3", ts.Failures())
+	}
+
+	m := model{testSuite: ts, ready: true, width: 160}
+	m.setTables(createTables(ts, 160))
+	if view := m.View(); !strings.Contains(view, "3 failing") || !strings.Contains(view, "Check") {
+		t.Error("View() should show the failure counter and the Check column")
+	}
+
+	// n walks the failing cells in grid order and wraps around, N goes back
+	steps := []struct {
+		step       int
+		focus, row int
+	}{
+		{1, 0, 2}, {1, 1, 2}, {1, 2, 0}, {1, 0, 2}, {-1, 2, 0},
+	}
+	for i, s := range steps {
+		m.jumpToFailure(s.step)
+		if m.focus != s.focus || m.tables[m.focus].Cursor() != s.row {
+			t.Errorf("jump %d: at table %d row %d, want table %d row %d", i, m.focus, m.tables[m.focus].Cursor(), s.focus, s.row)
+		}
+	}
+}
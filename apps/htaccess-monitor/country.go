@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultCountryAliases maps ISO codes to the URL prefix the site uses for
+// them; the test matrix and links.testing refer to countries by prefix
+var defaultCountryAliases = map[string]string{"gb": "uk"}
+
+// isoCountries maps every ISO 3166-1 alpha-2 code to its English short name
+var isoCountries = map[string]string{
+	"AD": "Andorra", "AE": "United Arab Emirates", "AF": "Afghanistan", "AG": "Antigua and Barbuda",
+	"AI": "Anguilla", "AL": "Albania", "AM": "Armenia", "AO": "Angola",
+	"AQ": "Antarctica", "AR": "Argentina", "AS": "American Samoa", "AT": "Austria",
+	"AU": "Australia", "AW": "Aruba", "AX": "Åland Islands", "AZ": "Azerbaijan",
+	"BA": "Bosnia and Herzegovina", "BB": "Barbados", "BD": "Bangladesh", "BE": "Belgium",
+	"BF": "Burkina Faso", "BG": "Bulgaria", "BH": "Bahrain", "BI": "Burundi",
+	"BJ": "Benin", "BL": "Saint Barthélemy", "BM": "Bermuda", "BN": "Brunei",
+	"BO": "Bolivia", "BQ": "Caribbean Netherlands", "BR": "Brazil", "BS": "Bahamas",
+	"BT": "Bhutan", "BV": "Bouvet Island", "BW": "Botswana", "BY": "Belarus",
+	"BZ": "Belize", "CA": "Canada", "CC": "Cocos (Keeling) Islands", "CD": "DR Congo",
+	"CF": "Central African Republic", "CG": "Congo", "CH": "Switzerland", "CI": "Côte d'Ivoire",
+	"CK": "Cook Islands", "CL": "Chile", "CM": "Cameroon", "CN": "China",
+	"CO": "Colombia", "CR": "Costa Rica", "CU": "Cuba", "CV": "Cape Verde",
+	"CW": "Curaçao", "CX": "Christmas Island", "CY": "Cyprus", "CZ": "Czechia",
+	"DE": "Germany", "DJ": "Djibouti", "DK": "Denmark", "DM": "Dominica",
+	"DO": "Dominican Republic", "DZ": "Algeria", "EC": "Ecuador", "EE": "Estonia",
+	"EG": "Egypt", "EH": "Western Sahara", "ER": "Eritrea", "ES": "Spain",
+	"ET": "Ethiopia", "FI": "Finland", "FJ": "Fiji", "FK": "Falkland Islands",
+	"FM": "Micronesia", "FO": "Faroe Islands", "FR": "France", "GA": "Gabon",
+	"GB": "United Kingdom", "GD": "Grenada", "GE": "Georgia", "GF": "French Guiana",
+	"GG": "Guernsey", "GH": "Ghana", "GI": "Gibraltar", "GL": "Greenland",
+	"GM": "Gambia", "GN": "Guinea", "GP": "Guadeloupe", "GQ": "Equatorial Guinea",
+	"GR": "Greece", "GS": "South Georgia and the South Sandwich Islands", "GT": "Guatemala", "GU": "Guam",
+	"GW": "Guinea-Bissau", "GY": "Guyana", "HK": "Hong Kong", "HM": "Heard Island and McDonald Islands",
+	"HN": "Honduras", "HR": "Croatia", "HT": "Haiti", "HU": "Hungary",
+	"ID": "Indonesia", "IE": "Ireland", "IL": "Israel", "IM": "Isle of Man",
+	"IN": "India", "IO": "British Indian Ocean Territory", "IQ": "Iraq", "IR": "Iran",
+	"IS": "Iceland", "IT": "Italy", "JE": "Jersey", "JM": "Jamaica",
+	"JO": "Jordan", "JP": "Japan", "KE": "Kenya", "KG": "Kyrgyzstan",
+	"KH": "Cambodia", "KI": "Kiribati", "KM": "Comoros", "KN": "Saint Kitts and Nevis",
+	"KP": "North Korea", "KR": "South Korea", "KW": "Kuwait", "KY": "Cayman Islands",
+	"KZ": "Kazakhstan", "LA": "Laos", "LB": "Lebanon", "LC": "Saint Lucia",
+	"LI": "Liechtenstein", "LK": "Sri Lanka", "LR": "Liberia", "LS": "Lesotho",
+	"LT": "Lithuania", "LU": "Luxembourg", "LV": "Latvia", "LY": "Libya",
+	"MA": "Morocco", "MC": "Monaco", "MD": "Moldova", "ME": "Montenegro",
+	"MF": "Saint Martin", "MG": "Madagascar", "MH": "Marshall Islands", "MK": "North Macedonia",
+	"ML": "Mali", "MM": "Myanmar", "MN": "Mongolia", "MO": "Macao",
+	"MP": "Northern Mariana Islands", "MQ": "Martinique", "MR": "Mauritania", "MS": "Montserrat",
+	"MT": "Malta", "MU": "Mauritius", "MV": "Maldives", "MW": "Malawi",
+	"MX": "Mexico", "MY": "Malaysia", "MZ": "Mozambique", "NA": "Namibia",
+	"NC": "New Caledonia", "NE": "Niger", "NF": "Norfolk Island", "NG": "Nigeria",
+	"NI": "Nicaragua", "NL": "Netherlands", "NO": "Norway", "NP": "Nepal",
+	"NR": "Nauru", "NU": "Niue", "NZ": "New Zealand", "OM": "Oman",
+	"PA": "Panama", "PE": "Peru", "PF": "French Polynesia", "PG": "Papua New Guinea",
+	"PH": "Philippines", "PK": "Pakistan", "PL": "Poland", "PM": "Saint Pierre and Miquelon",
+	"PN": "Pitcairn Islands", "PR": "Puerto Rico", "PS": "Palestine", "PT": "Portugal",
+	"PW": "Palau", "PY": "Paraguay", "QA": "Qatar", "RE": "Réunion",
+	"RO": "Romania", "RS": "Serbia", "RU": "Russia", "RW": "Rwanda",
+	"SA": "Saudi Arabia", "SB": "Solomon Islands", "SC": "Seychelles", "SD": "Sudan",
+	"SE": "Sweden", "SG": "Singapore", "SH": "Saint Helena, Ascension and Tristan da Cunha", "SI": "Slovenia",
+	"SJ": "Svalbard and Jan Mayen", "SK": "Slovakia", "SL": "Sierra Leone", "SM": "San Marino",
+	"SN": "Senegal", "SO": "Somalia", "SR": "Suriname", "SS": "South Sudan",
+	"ST": "São Tomé and Príncipe", "SV": "El Salvador", "SX": "Sint Maarten", "SY": "Syria",
+	"SZ": "Eswatini", "TC": "Turks and Caicos Islands", "TD": "Chad", "TF": "French Southern Territories",
+	"TG": "Togo", "TH": "Thailand", "TJ": "Tajikistan", "TK": "Tokelau",
+	"TL": "Timor-Leste", "TM": "Turkmenistan", "TN": "Tunisia", "TO": "Tonga",
+	"TR": "Türkiye", "TT": "Trinidad and Tobago", "TV": "Tuvalu", "TW": "Taiwan",
+	"TZ": "Tanzania", "UA": "Ukraine", "UG": "Uganda", "UM": "United States Minor Outlying Islands",
+	"US": "United States", "UY": "Uruguay", "UZ": "Uzbekistan", "VA": "Vatican City",
+	"VC": "Saint Vincent and the Grenadines", "VE": "Venezuela", "VG": "British Virgin Islands", "VI": "U.S. Virgin Islands",
+	"VN": "Vietnam", "VU": "Vanuatu", "WF": "Wallis and Futuna", "WS": "Samoa",
+	"YE": "Yemen", "YT": "Mayotte", "ZA": "South Africa", "ZM": "Zambia",
+	"ZW": "Zimbabwe",
+}
+
+// countryFlag returns the regional indicator flag of an ISO code, e.g. 🇩🇪
+func countryFlag(iso string) string {
+	var flag strings.Builder
+	for _, c := range strings.ToUpper(iso) {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+		flag.WriteRune(0x1F1E6 + c - 'A')
+	}
+	return flag.String()
+}
+
+// isoCode converts a URL prefix such as "uk" into the ISO code GeoIP
+// reports for it ("GB"); other codes are upper-cased
+func (c *Config) isoCode(code string) string {
+	code = strings.ToLower(code)
+	for iso, prefix := range c.Aliases {
+		if prefix == code {
+			return strings.ToUpper(iso)
+		}
+	}
+	return strings.ToUpper(code)
+}
+
+// urlPrefix converts an ISO code such as "GB" into the URL prefix the site
+// uses for it ("uk"); other codes are lower-cased
+func (c *Config) urlPrefix(iso string) string {
+	iso = strings.ToLower(iso)
+	if prefix, ok := c.Aliases[iso]; ok {
+		return prefix
+	}
+	return iso
+}
+
+// lookupCountry returns the registry entry of an ISO code or URL prefix,
+// keeping the code as given
+func (c *Config) lookupCountry(code string) (Country, bool) {
+	iso := c.isoCode(code)
+	name, ok := isoCountries[iso]
+	if !ok {
+		return Country{}, false
+	}
+	return Country{Code: strings.ToLower(code), Flag: countryFlag(iso), Name: name}, true
+}
+
+// validateAliases checks that aliases map ISO codes to two-letter prefixes
+// that are neither another country's code nor another alias, and
+// lower-cases them
+func (c *Config) validateAliases() error {
+	aliases := make(map[string]string, len(c.Aliases))
+	prefixes := map[string]string{}
+	for iso, prefix := range c.Aliases {
+		iso, prefix = strings.ToLower(iso), strings.ToLower(prefix)
+		if _, ok := isoCountries[strings.ToUpper(iso)]; !ok {
+			return fmt.Errorf("aliases: %q is not an ISO 3166 country code", iso)
+		}
+		if len(prefix) != 2 || countryFlag(prefix) == "" {
+			return fmt.Errorf("aliases: prefix %q of %s must have two letters", prefix, iso)
+		}
+		if name, ok := isoCountries[strings.ToUpper(prefix)]; ok && prefix != iso {
+			return fmt.Errorf("aliases: prefix %q of %s is the code of %s", prefix, iso, name)
+		}
+		if other, ok := prefixes[prefix]; ok {
+			return fmt.Errorf("aliases: prefix %q is used for both %s and %s", prefix, other, iso)
+		}
+		prefixes[prefix] = iso
+		aliases[iso] = prefix
+	}
+	c.Aliases = aliases
+	return nil
+}
+
+// isoCountryCode converts a URL prefix code such as "uk" into its GeoIP code
+func isoCountryCode(code string) string {
+	return monitorConfig.isoCode(code)
+}
+
+// lookupCountry returns the registry entry of a code of the test matrix
+func lookupCountry(code string) (Country, bool) {
+	return monitorConfig.lookupCountry(code)
+}
+
+// countryLabel returns a code with its flag, e.g. "🇩🇪 DE", for display
+func countryLabel(code string) string {
+	if code == "" {
+		return ""
+	}
+	if country, ok := lookupCountry(code); ok {
+		return country.Flag + " " + strings.ToUpper(code)
+	}
+	return strings.ToUpper(code)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestISOCountries tests that the registry covers every ISO 3166-1 alpha-2
+// code with a name
+func TestISOCountries(t *testing.T) {
+	if len(isoCountries) != 249 {
+		t.Errorf("registry has %d countries, want 249", len(isoCountries))
+	}
+	for code, name := range isoCountries {
+		if len(code) != 2 || code != strings.ToUpper(code) || name == "" {
+			t.Errorf("invalid entry %q: %q", code, name)
+		}
+	}
+}
+
+// TestCountryFlag tests the regional indicator flags
+func TestCountryFlag(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"DE", "🇩🇪"},
+		{"gb", "🇬🇧"},
+		{"jp", "🇯🇵"},
+		{"1x", ""},
+	}
+	for _, tt := range tests {
+		if got := countryFlag(tt.code); got != tt.want {
+			t.Errorf("countryFlag(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+// TestLookupCountry tests ISO codes, URL prefix aliases and unknown codes
+func TestLookupCountry(t *testing.T) {
+	cfg := defaultConfig()
+	tests := []struct {
+		code    string
+		want    Country
+		wantISO string
+		wantOK  bool
+	}{
+		{"de", Country{Code: "de", Flag: "🇩🇪", Name: "Germany"}, "DE", true},
+		{"UK", Country{Code: "uk", Flag: "🇬🇧", Name: "United Kingdom"}, "GB", true},
+		{"gb", Country{Code: "gb", Flag: "🇬🇧", Name: "United Kingdom"}, "GB", true},
+		{"xx", Country{}, "XX", false},
+	}
+	for _, tt := range tests {
+		got, ok := cfg.lookupCountry(tt.code)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("lookupCountry(%q) = %+v, %v, want %+v, %v", tt.code, got, ok, tt.want, tt.wantOK)
+		}
+		if iso := cfg.isoCode(tt.code); iso != tt.wantISO {
+			t.Errorf("isoCode(%q) = %q, want %q", tt.code, iso, tt.wantISO)
+		}
+	}
+}
+
+// TestConfigAliases tests configured aliases replacing the default ones
+func TestConfigAliases(t *testing.T) {
+	cfg, err := parseConfig([]byte("aliases: {AT: oe}\ncountries: [{code: oe}, {code: gb}]\n"))
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+	if cfg.isoCode("oe") != "AT" || cfg.isoCode("uk") != "UK" {
+		t.Errorf("aliases = %v, want only at → oe", cfg.Aliases)
+	}
+	if cfg.urlPrefix("AT") != "oe" || cfg.urlPrefix("GB") != "gb" {
+		t.Errorf("urlPrefix() = %s, %s, want oe, gb", cfg.urlPrefix("AT"), cfg.urlPrefix("GB"))
+	}
+	if cfg.Countries[0].Name != "Austria" || cfg.Countries[0].Flag != "🇦🇹" {
+		t.Errorf("aliased country = %+v, want Austria", cfg.Countries[0])
+	}
+
+	if _, err := parseConfig([]byte("countries: [{code: uk}]\naliases: {}\n")); err == nil {
+		t.Error("parseConfig() accepted uk without the gb alias")
+	}
+}
+
+// TestLinkTestUnknownCountry tests that links.testing rejects unknown codes
+// and sends aliases as ISO codes
+func TestLinkTestUnknownCountry(t *testing.T) {
+	path := writeTestFile(t, "links.testing", "Agent,Country,URL,Expected status,Expected result\nBrowser,XX,http://example.com/,200,No redirect\n")
+	if _, err := parseLinkTestFile(path); err == nil || !strings.Contains(err.Error(), `unknown country "XX"`) {
+		t.Errorf("parseLinkTestFile() error = %v, want unknown country", err)
+	}
+
+	path = writeTestFile(t, "links.testing", "Agent,Country,URL,Expected status,Expected result\nBrowser,uk,http://example.com/,200,No redirect\n")
+	tests, err := parseLinkTestFile(path)
+	if err != nil {
+		t.Fatalf("parseLinkTestFile() error = %v", err)
+	}
+	if got := linkTestHeaders(tests[0])["X-Test-Country"]; got != "GB" {
+		t.Errorf("X-Test-Country = %q, want GB", got)
+	}
+	if got := countryLabel("uk"); got != "🇬🇧 UK" {
+		t.Errorf("countryLabel(uk) = %q", got)
+	}
+}
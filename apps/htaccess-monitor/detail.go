@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// shellQuote quotes a word for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// curlCommand returns a curl command line that sends the request of a cell
+// again and prints the response headers
+func curlCommand(method, url string, headers map[string]string) string {
+	args := []string{"curl", "-sS", "-D", "-", "-o", "/dev/null"}
+	if method != "" && method != "GET" {
+		args = append(args, "-X", method)
+	}
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		args = append(args, "-H", shellQuote(name+": "+headers[name]))
+	}
+	return strings.Join(append(args, shellQuote(url)), " ")
+}
+
+// indent prefixes each line with two spaces
+func indent(lines []string) []string {
+	indented := make([]string, len(lines))
+	for i, line := range lines {
+		indented[i] = "  " + line
+	}
+	return indented
+}
+
+// detailLines describes the request of a cell and the response it got
+func detailLines(result TestResult) []string {
+	lines := append([]string{"Request", "  GET " + result.URL}, indent(headerLines(result.Headers))...)
+
+	lines = append(lines, "", "Response")
+	switch {
+	case result.Pending:
+		lines = append(lines, "  ⏳ not answered yet")
+	case result.Status == 0:
+		lines = append(lines, fmt.Sprintf("  ❌ %s after %s", result.Result, result.Duration.Round(time.Microsecond)))
+	default:
+		lines = append(lines, fmt.Sprintf("  %s %s in %s", getStatusIcon(result.Status), result.StatusText, result.Duration.Round(time.Microsecond)))
+		lines = append(lines, indent(responseHeaderLines(result.Response))...)
+	}
+	if len(result.Chain) > 1 || result.ChainIssue != "" {
+		lines = append(lines, fmt.Sprintf("  Chain: %s %s", formatChain(result.Chain, result.ChainIssue), chainFinalURL(result.Chain)))
+	}
+	if result.failing() {
+		lines = append(lines, "  ❌ "+result.Reason)
+	}
+
+	return append(lines, "", "curl", "  "+curlCommand("GET", result.URL, result.Headers))
+}
+
+// detailView renders the request and response pane for the selected cell
+func (m model) detailView() string {
+	paneStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("33")).
+		Padding(0, 1).
+		MarginTop(1)
+
+	result, ok := m.selectedResult()
+	if !ok {
+		return paneStyle.Render("📋 No cell selected")
+	}
+	title := "📋 " + result.Country.Name
+	if group := m.shown().Groups[m.focus]; !group.Special {
+		title = fmt.Sprintf("📋 %s %s — %s", result.Country.Flag, result.Country.Name, group.Title)
+	}
+	return paneStyle.Render(title + "\n" + strings.Join(detailLines(result), "\n"))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestCurlCommand tests the reproducing command line and its quoting
+func TestCurlCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		headers map[string]string
+		want    string
+	}{
+		{"plain GET", "GET", "http://localhost:8080/", nil, "curl -sS -D - -o /dev/null 'http://localhost:8080/'"},
+		{"sorted headers", "", "http://localhost:8080/de/", map[string]string{"X-Test-Country": "DE", "User-Agent": "Googlebot/2.1"},
+			"curl -sS -D - -o /dev/null -H 'User-Agent: Googlebot/2.1' -H 'X-Test-Country: DE' 'http://localhost:8080/de/'"},
+		{"quote in a value", "HEAD", "http://localhost:8080/?q=it's", map[string]string{"Cookie": "name='x'"},
+			`curl -sS -D - -o /dev/null -X HEAD -H 'Cookie: name='\''x'\''' 'http://localhost:8080/?q=it'\''s'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := curlCommand(tt.method, tt.url, tt.headers); got != tt.want {
+				t.Errorf("curlCommand() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDetailLines tests the request, response and timing of a cell
+func TestDetailLines(t *testing.T) {
+	result := TestResult{
+		URL:        "http://localhost:8080/test-content",
+		Headers:    map[string]string{"X-Test-Country": "DE", "User-Agent": "Mozilla/5.0"},
+		Status:     302,
+		StatusText: "302 Found",
+		Result:     "/de/test-content",
+		Response:   http.Header{"Location": {"/de/test-content"}, "Set-Cookie": {"a=1", "b=2"}},
+		Duration:   1500 * time.Microsecond,
+	}
+	got := strings.Join(detailLines(result), "\n")
+	for _, want := range []string{
+		"Request\n  GET http://localhost:8080/test-content\n  User-Agent: Mozilla/5.0\n  X-Test-Country: DE\n",
+		"Response\n  🔄 302 Found in 1.5ms\n  Location: /de/test-content\n  Set-Cookie: a=1\n  Set-Cookie: b=2\n",
+		"curl\n  curl -sS -D - -o /dev/null -H 'User-Agent: Mozilla/5.0' -H 'X-Test-Country: DE' 'http://localhost:8080/test-content'",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("detail missing %q:\n%s", want, got)
+		}
+	}
+
+	result.Status, result.Result = 0, "Connection failed"
+	if got := strings.Join(detailLines(result), "\n"); !strings.Contains(got, "❌ Connection failed after 1.5ms") {
+		t.Errorf("failed request detail:\n%s", got)
+	}
+	result.Pending = true
+	if got := strings.Join(detailLines(result), "\n"); !strings.Contains(got, "⏳ not answered yet") {
+		t.Errorf("pending detail:\n%s", got)
+	}
+}
+
+// TestModelDetailPane tests moving the selection across tables and opening
+// the detail pane of the selected cell with enter
+func TestModelDetailPane(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Pages = cfg.Pages[:1]
+	cfg.SpecialCases = nil
+	ts, _ := planMatrix(cfg)
+	m := completeRun(initialModel(), ts)
+	if len(m.tables) < 2 || !m.tables[0].Focused() {
+		t.Fatalf("first of %d tables not focused", len(m.tables))
+	}
+
+	press := func(key tea.KeyMsg) {
+		updated, _ := m.Update(key)
+		m = updated.(model)
+	}
+	press(tea.KeyMsg{Type: tea.KeyTab})
+	press(tea.KeyMsg{Type: tea.KeyDown})
+	if m.focus != 1 || m.tables[0].Focused() || !m.tables[1].Focused() || m.tables[1].Cursor() != 1 {
+		t.Fatalf("focus %d, cursor %d after tab and down", m.focus, m.tables[1].Cursor())
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	selected := ts.Groups[1].Results[1]
+	view := m.View()
+	if !m.detail || !strings.Contains(view, "📋 "+selected.Country.Flag+" "+selected.Country.Name+" — "+ts.Groups[1].Title) ||
+		!strings.Contains(view, "User-Agent: "+selected.Headers["User-Agent"]) {
+		t.Errorf("enter did not show the selected cell:\n%s", view)
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.detail || strings.Contains(m.View(), "📋") {
+		t.Error("esc did not close the detail pane")
+	}
+}
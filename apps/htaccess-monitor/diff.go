@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// changeMarker flags the cells that changed since the previous run; table
+// cells cannot be styled, so the marker is part of the text
+const changeMarker = "✱"
+
+// changeListLimit is how many changed cells the changes panel lists
+const changeListLimit = 8
+
+// cellChange is a cell whose status or redirect target differs between two
+// runs
+type cellChange struct {
+	Group, Index int
+	Label        string // e.g. "DE /test-content"
+	Before       TestResult
+	After        TestResult
+}
+
+// String describes the change, e.g. "DE /test-content: 302 /de/test-content → 200"
+func (c cellChange) String() string {
+	return fmt.Sprintf("%s: %s → %s", c.Label, cellOutcome(c.Before), cellOutcome(c.After))
+}
+
+// cellOutcome is the status of a result and, for a redirect, its target
+// relative to the requested host
+func cellOutcome(result TestResult) string {
+	return responseOutcome(result.URL, result.Status, result.Result, result.Response)
+}
+
+// responseOutcome describes a response by its status and, for a redirect,
+// its target relative to the requested host; without a response it is the
+// result, e.g. "Connection failed"
+func responseOutcome(requestURL string, status int, result string, header http.Header) string {
+	if status == 0 {
+		return result
+	}
+	location := header.Get("Location")
+	if status < 300 || status >= 400 || location == "" {
+		return fmt.Sprint(status)
+	}
+	if target, err := url.Parse(requestURL); err == nil {
+		if u, err := target.Parse(location); err == nil && u.Host == target.Host {
+			location = u.RequestURI()
+		}
+	}
+	return fmt.Sprintf("%d %s", status, location)
+}
+
+// sameCell reports whether two results are the same cell of the matrix
+func sameCell(a, b TestResult) bool {
+	return a.Country.Code == b.Country.Code && a.Country.Name == b.Country.Name && a.URL == b.URL
+}
+
+// previousResult returns the result of the same cell in an earlier run; a
+// cell has none when the layout of its table changed
+func (ts TestSuite) previousResult(group, index int, result TestResult) (TestResult, bool) {
+	if group >= len(ts.Groups) || index >= len(ts.Groups[group].Results) {
+		return TestResult{}, false
+	}
+	before := ts.Groups[group].Results[index]
+	if before.Pending || !sameCell(before, result) {
+		return TestResult{}, false
+	}
+	return before, true
+}
+
+// cellChanged reports whether a result differs from the same cell of an
+// earlier run in status or redirect target
+func cellChanged(before, after TestResult) bool {
+	return !after.Pending && (before.Status != after.Status || before.Result != after.Result)
+}
+
+// changeLabel names a cell in the changes panel: its country and path, with
+// the agent when the page is tested by more than one, or the special case
+func changeLabel(group ResultGroup, result TestResult, agents int) string {
+	if group.Special {
+		return result.Country.Name
+	}
+	path := result.URL
+	if u, err := url.Parse(result.URL); err == nil {
+		path = u.RequestURI()
+	}
+	label := strings.ToUpper(result.Country.Code) + " " + path
+	if agents > 1 {
+		label += " (" + group.Title + ")"
+	}
+	return label
+}
+
+// diffSuites lists the cells of after whose status or redirect target
+// differs from before, in grid order
+func diffSuites(before, after TestSuite) []cellChange {
+	agents := map[string]int{}
+	for _, group := range after.Groups {
+		agents[group.Section]++
+	}
+	var changes []cellChange
+	for i, group := range after.Groups {
+		for j, result := range group.Results {
+			previous, ok := before.previousResult(i, j, result)
+			if !ok || !cellChanged(previous, result) {
+				continue
+			}
+			changes = append(changes, cellChange{
+				Group:  i,
+				Index:  j,
+				Label:  changeLabel(group, result, agents[group.Section]),
+				Before: previous,
+				After:  result,
+			})
+		}
+	}
+	return changes
+}
+
+// markChanges flags the changed cells of a suite for the tables
+func markChanges(ts TestSuite, changes []cellChange) {
+	for _, change := range changes {
+		ts.Groups[change.Group].Results[change.Index].Changed = true
+	}
+}
+
+// changesView renders the panel listing the cells changed by the last run
+func (m model) changesView() string {
+	paneStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("214")).
+		Padding(0, 1).
+		MarginTop(1)
+
+	lines := []string{fmt.Sprintf("🔀 %d cell(s) changed since the run of %s", len(m.changes), m.previous.LastUpdate.Format("15:04:05"))}
+	for i, change := range m.changes {
+		if i == changeListLimit {
+			lines = append(lines, fmt.Sprintf("… %d more", len(m.changes)-changeListLimit))
+			break
+		}
+		lines = append(lines, changeMarker+" "+change.String())
+	}
+	return paneStyle.Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// diffSuite is a one-page matrix with every cell answered 200, except the
+// given redirects by country code
+func diffSuite(t *testing.T, at time.Time, redirects map[string]string) TestSuite {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.Pages = []PageConfig{{Name: "Test", Path: "/test-content"}}
+	cfg.Agents = cfg.Agents[:1]
+	cfg.SpecialCases = nil
+	ts, _ := planMatrix(cfg)
+	for i, result := range ts.Groups[0].Results {
+		result.Pending, result.Status, result.Result = false, 200, "No redirect"
+		if location, ok := redirects[result.Country.Code]; ok {
+			result.Status, result.Result = 302, location
+			result.Response = http.Header{"Location": {location}}
+		}
+		ts.Groups[0].Results[i] = result
+	}
+	ts.LastUpdate = at
+	return ts
+}
+
+// completeRun feeds a whole run to the model
+func completeRun(m model, ts TestSuite) model {
+	run := m.run + 1
+	m.run = run
+	updated, _ := m.Update(testPlanMsg{run: run, suite: ts.clone(), updates: make(chan tea.Msg), cancel: func() {}})
+	updated, cmd := updated.(model).Update(testCompleteMsg{run: run, suite: ts.clone()})
+	if cmd != nil {
+		updated, _ = updated.(model).Update(cmd())
+	}
+	return updated.(model)
+}
+
+// TestDiffSuites tests which cells count as changed and how they are named
+func TestDiffSuites(t *testing.T) {
+	at := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	before := diffSuite(t, at, map[string]string{"de": "http://localhost:8080/de/test-content", "at": "/at/test-content"})
+	after := diffSuite(t, at, map[string]string{"at": "/de/test-content", "fr": "https://example.fr/test-content"})
+
+	var got []string
+	for _, change := range diffSuites(before, after) {
+		got = append(got, change.String())
+	}
+	want := []string{
+		"AT /test-content: 302 /at/test-content → 302 /de/test-content",
+		"FR /test-content: 200 → 302 https://example.fr/test-content",
+		"DE /test-content: 302 /de/test-content → 200",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diffSuites() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if changes := diffSuites(TestSuite{}, after); len(changes) != 0 {
+		t.Errorf("diff without a previous run = %v", changes)
+	}
+	// A cell whose table was laid out differently has nothing to compare to
+	before.Groups[0].Results = before.Groups[0].Results[1:]
+	if changes := diffSuites(before, after); len(changes) != 0 {
+		t.Errorf("diff against another layout = %v", changes)
+	}
+}
+
+// TestModelRunDiff tests the change markers, the changes panel and the
+// toggle between the current and previous runs
+func TestModelRunDiff(t *testing.T) {
+	first := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	m := completeRun(initialModel(), diffSuite(t, first, map[string]string{"de": "/de/test-content"}))
+	if len(m.changes) != 0 || strings.Contains(m.View(), "🔀") {
+		t.Fatalf("first run reported changes: %v", m.changes)
+	}
+
+	m = completeRun(m, diffSuite(t, first.Add(time.Minute), nil))
+	view := m.View()
+	if !strings.Contains(view, "🔀 1 cell(s) changed since the run of 15:04:05") || !strings.Contains(view, "✱ DE /test-content: 302 /de/test-content → 200") {
+		t.Errorf("view has no changes panel:\n%s", view)
+	}
+	var marked []string
+	for _, row := range m.tables[0].Rows() {
+		if strings.HasPrefix(row[0], changeMarker) {
+			marked = append(marked, row[0]+" "+row[1])
+		}
+	}
+	if len(marked) != 1 || marked[0] != "✱ 🇩🇪 Germany ✅ 200" {
+		t.Errorf("marked rows = %v, want only Germany", marked)
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	m = updated.(model)
+	if !strings.Contains(m.View(), "Previous run of 15:04:05") {
+		t.Errorf("'p' did not show the previous run:\n%s", m.View())
+	}
+	for _, row := range m.tables[0].Rows() {
+		if strings.HasPrefix(row[0], changeMarker) && row[1] != "🔄 302" {
+			t.Errorf("previous run shows %v for the changed cell", row)
+		}
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if m = updated.(model); m.showPrev {
+		t.Error("second 'p' did not return to the current run")
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// explainBackend returns the in-process backend used to trace requests,
+// reusing the active one when the monitor already runs offline
+func explainBackend() *rewriteBackend {
+	if b, ok := backendTransport.(*rewriteBackend); ok {
+		return b
+	}
+	return newRewriteBackend(htaccessPath, offlineDocRoot)
+}
+
+// explainRequest evaluates a request against the .htaccess and records a trace
+func explainRequest(req *http.Request) (*RewriteTrace, error) {
+	backend := explainBackend()
+	rules, err := backend.ruleSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load .htaccess: %w", err)
+	}
+	_, trace := rules.EvaluateTrace(newRewriteRequest(req), backend.docRoot)
+	return trace, nil
+}
+
+// explainURL traces a GET request for a URL sent with the given headers
+func explainURL(url string, headers map[string]string) ([]string, error) {
+	return explainRequestURL("GET", url, headers)
+}
+
+// explainRequestURL traces a request with the given method and headers
+func explainRequestURL(method, url string, headers map[string]string) ([]string, error) {
+	req, err := newTestRequestMethod(method, url, headers)
+	if err != nil {
+		return nil, err
+	}
+	trace, err := explainRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return formatTrace(trace, headers), nil
+}
+
+// explainLinkTest traces the request runLinkTests sends for a test case
+func explainLinkTest(test LinkTest) ([]string, error) {
+	return explainRequestURL(linkTestMethod(test), linkTestURL(test), linkTestHeaders(test))
+}
+
+// formatTrace renders a RewriteTrace as plain text lines
+func formatTrace(trace *RewriteTrace, headers map[string]string) []string {
+	var lines []string
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("Request header  %s: %s", name, headers[name]))
+	}
+
+	for i, round := range trace.Rounds {
+		if i == 0 {
+			lines = append(lines, fmt.Sprintf("Pass %d: %s", i+1, round.URI))
+		} else {
+			lines = append(lines, fmt.Sprintf("Pass %d (internal redirect): %s", i+1, round.URI))
+		}
+		for _, step := range round.Rules {
+			lines = append(lines, formatTraceRule(step)...)
+		}
+	}
+
+	env := make([]string, 0, len(trace.Result.Env))
+	for name, value := range trace.Result.Env {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	lines = append(lines, "Environment: "+strings.Join(env, " "))
+
+	if trace.Terminal != nil {
+		rule := trace.Terminal.Rule
+		lines = append(lines, fmt.Sprintf("Stopped by line %d: RewriteRule %s %s %s",
+			rule.Line, rule.Pattern, rule.Substitution, rule.Flags.Raw))
+	} else {
+		lines = append(lines, "Stopped by: no rule ended processing")
+	}
+	if trace.Outcome != "" {
+		lines = append(lines, "Outcome: "+trace.Outcome)
+	}
+
+	result := fmt.Sprintf("Result: %d %s", trace.Result.Status, http.StatusText(trace.Result.Status))
+	if trace.Result.Location != "" {
+		result += " → " + trace.Result.Location
+	}
+	return append(lines, result)
+}
+
+// formatTraceRule renders one rule block with its conditions
+func formatTraceRule(step TraceRule) []string {
+	rule := step.Rule
+	first := rule.Line
+	if len(rule.Conds) > 0 {
+		first = rule.Conds[0].Line
+	}
+	location := fmt.Sprintf("line %d", rule.Line)
+	if first != rule.Line {
+		location = fmt.Sprintf("lines %d-%d", first, rule.Line)
+	}
+
+	header := fmt.Sprintf("  [%s] RewriteRule %s %s %s", location, rule.Pattern, rule.Substitution, rule.Flags.Raw)
+	switch {
+	case step.Skipped != "":
+		return []string{header + " — skipped: " + step.Skipped}
+	case !step.Matched:
+		return []string{header + fmt.Sprintf(" — ✗ pattern does not match %q", step.Subject)}
+	}
+
+	lines := []string{header + fmt.Sprintf(" — ✓ pattern matches %q%s", step.Subject, formatCaptures("$", step.Captures))}
+	for _, cond := range step.Conds {
+		mark := "✗"
+		if cond.Matched {
+			mark = "✓"
+		}
+		flags := ""
+		if cond.Cond.NoCase || cond.Cond.OrNext {
+			var parts []string
+			if cond.Cond.NoCase {
+				parts = append(parts, "NC")
+			}
+			if cond.Cond.OrNext {
+				parts = append(parts, "OR")
+			}
+			flags = " [" + strings.Join(parts, ",") + "]"
+		}
+		lines = append(lines, fmt.Sprintf("      line %d: %s RewriteCond %s %s%s — value %q%s",
+			cond.Cond.Line, mark, cond.Cond.TestString, cond.Cond.Pattern, flags, cond.Value, formatCaptures("%", cond.Captures)))
+	}
+	if !step.Applied {
+		return append(lines, "      conditions not met, rule not applied")
+	}
+	for _, env := range step.EnvSet {
+		lines = append(lines, "      set env "+env)
+	}
+	if step.Action != "" {
+		lines = append(lines, "      → "+step.Action)
+	}
+	return lines
+}
+
+// formatCaptures renders regex captures as backreferences
+func formatCaptures(prefix string, captures []string) string {
+	if len(captures) < 2 {
+		return ""
+	}
+	parts := make([]string, 0, len(captures)-1)
+	for i, capture := range captures[1:] {
+		parts = append(parts, fmt.Sprintf("%s%d=%q", prefix, i+1, capture))
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// runExplain runs the link tests and prints the rule trace of each case
+func runExplain(testFile string) {
+	tests, err := parseLinkTestFile(testFile)
+	if err != nil {
+		fmt.Printf("❌ Error reading test file: %v\n", err)
+		return
+	}
+	if len(tests) == 0 {
+		fmt.Println("⚠️  No tests found in file")
+		return
+	}
+
+	fmt.Printf("🔎 Explaining %d test cases from %s using %s\n", len(tests), testFile, htaccessPath)
+	results := runLinkTests(tests)
+	for i, result := range results {
+		status := "✅ PASS"
+		if !result.Success {
+			status = "❌ FAIL"
+		}
+		test := result.Test
+		fmt.Printf("\n#%d %s %s %s — expected %d %s, got %d %s %s\n",
+			i+1, test.Agent, test.Country, test.URL, test.ExpectedStatus, test.ExpectedResult, result.Status, result.Result, status)
+		if result.Reason != "" {
+			fmt.Printf("   ↳ %s\n", result.Reason)
+		}
+
+		lines, err := explainLinkTest(test)
+		if err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			continue
+		}
+		for _, line := range lines {
+			fmt.Println("   " + line)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestEvaluateTrace tests that traces record rules, conditions and the terminal rule
+func TestEvaluateTrace(t *testing.T) {
+	rs := parseHtaccess(strings.NewReader(testHtaccess))
+
+	result, trace := rs.EvaluateTrace(&RewriteRequest{
+		Method: "GET",
+		Host:   "localhost:8080",
+		Path:   "/test-content",
+		Header: http.Header{},
+		Env:    map[string]string{geoCountryEnv: "DE"},
+	}, dockerDocRoot)
+
+	if result.Status != 302 {
+		t.Fatalf("status = %d, want 302", result.Status)
+	}
+	if len(trace.Rounds) != 1 {
+		t.Fatalf("rounds = %d, want 1", len(trace.Rounds))
+	}
+	if trace.Terminal == nil || trace.Terminal.Rule.Line != 13 {
+		t.Fatalf("terminal rule = %+v, want line 13", trace.Terminal)
+	}
+	if got := trace.Terminal.Captures; len(got) != 2 || got[1] != "test-content" {
+		t.Errorf("rule captures = %q, want $1=test-content", got)
+	}
+	if len(trace.Terminal.Conds) != 2 || !trace.Terminal.Conds[0].Matched || trace.Terminal.Conds[0].Value != "DE" {
+		t.Errorf("conditions = %+v, want first condition to match DE", trace.Terminal.Conds)
+	}
+
+	// Front controller: internal redirect produces a second pass
+	_, trace = rs.EvaluateTrace(&RewriteRequest{
+		Method: "GET",
+		Host:   "localhost:8080",
+		Path:   "/de/some-post",
+		Header: http.Header{},
+		Env:    map[string]string{geoCountryEnv: "DE"},
+	}, dockerDocRoot)
+	if len(trace.Rounds) != 2 || trace.Rounds[1].URI != "/index.php" {
+		t.Errorf("rounds = %+v, want second pass for /index.php", trace.Rounds)
+	}
+	if trace.Terminal == nil || trace.Terminal.Rule.Pattern != `^index\.php$` {
+		t.Errorf("terminal rule = %+v, want ^index\\.php$", trace.Terminal)
+	}
+}
+
+// TestFormatTrace tests the explain output
+func TestFormatTrace(t *testing.T) {
+	rs := parseHtaccess(strings.NewReader(testHtaccess))
+	_, trace := rs.EvaluateTrace(&RewriteRequest{
+		Method: "GET",
+		Host:   "localhost:8080",
+		Path:   "/",
+		Header: http.Header{},
+		Env:    map[string]string{geoCountryEnv: "GB"},
+	}, dockerDocRoot)
+
+	output := strings.Join(formatTrace(trace, map[string]string{"X-Test-Country": "GB"}), "\n")
+	expected := []string{
+		"Request header  X-Test-Country: GB",
+		"Pass 1: /",
+		"[lines 15-18] RewriteRule ^(.*)$ /uk/$1 [R=302,L,QSA]",
+		"line 15: ✓ RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^GB$ [OR]",
+		"→ redirect 302 → http://localhost:8080/uk/",
+		"Environment: GEOIP_COUNTRY_CODE=GB",
+		"Stopped by line 18",
+		"Result: 302 Found → http://localhost:8080/uk/",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("trace output missing %q\n%s", want, output)
+		}
+	}
+}
+
+// TestExplainLinkTest tests tracing a links.testing case
+func TestExplainLinkTest(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, ".htaccess")
+	if err := os.WriteFile(path, []byte(testHtaccess), 0644); err != nil {
+		t.Fatalf("Failed to create .htaccess: %v", err)
+	}
+
+	oldPath := htaccessPath
+	defer func() { htaccessPath = oldPath }()
+	htaccessPath = path
+
+	lines, err := explainLinkTest(LinkTest{Agent: "Browser", Country: "UK", URL: "http://localhost:8080/"})
+	if err != nil {
+		t.Fatalf("explainLinkTest() error = %v", err)
+	}
+	output := strings.Join(lines, "\n")
+	if !strings.Contains(output, "X-Test-Country: GB") {
+		t.Errorf("UK should be sent as GB:\n%s", output)
+	}
+	if !strings.Contains(output, "Stopped by line 18") {
+		t.Errorf("expected the UK rule to stop processing:\n%s", output)
+	}
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp/syntax"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// defaultGeneratedFile is the test file generate writes
+const defaultGeneratedFile = "links.generated.testing"
+
+// generatePlainPath is a page that no rule is expected to single out
+const generatePlainPath = "/sample-page/"
+
+// maxWitnesses limits the strings synthesized from one pattern
+const maxWitnesses = 64
+
+// Goals of a rule block besides failing one of its conditions
+const (
+	goalApplies = -1 // the rule applies
+	goalMisses  = -2 // the rule pattern does not match
+)
+
+// ruleGoal is a way a rule block can be exercised: applying, its pattern
+// not matching, one of its conditions failing, or matching through one
+// branch of its alternation
+type ruleGoal struct {
+	Cond   int    // index of the condition, or goalApplies / goalMisses
+	Branch string // text the condition must match, empty when it must fail
+	Case   int    // generated case showing it, -1 when no input does
+}
+
+// met reports whether a traced evaluation of the rule shows the goal
+func (g ruleGoal) met(step TraceRule) bool {
+	switch g.Cond {
+	case goalApplies:
+		return step.Applied
+	case goalMisses:
+		return step.Skipped == "" && !step.Matched
+	}
+	for _, cond := range step.Conds {
+		if cond.Cond != &step.Rule.Conds[g.Cond] {
+			continue
+		}
+		if g.Branch == "" {
+			return !cond.Matched
+		}
+		value, branch := cond.Value, g.Branch
+		if cond.Cond.NoCase {
+			value, branch = strings.ToLower(value), strings.ToLower(branch)
+		}
+		return cond.Matched && strings.Contains(value, branch)
+	}
+	return false
+}
+
+// ruleCoverage records how a rule block is exercised
+type ruleCoverage struct {
+	Rule     *RewriteRule
+	Goals    []ruleGoal
+	Existing int // existing test cases the rule applies to
+}
+
+// name describes a goal of the block, e.g. "Rule 26: line 23 not met"
+func (c ruleCoverage) name(g ruleGoal) string {
+	switch g.Cond {
+	case goalApplies:
+		return fmt.Sprintf("Rule %d: applies", c.Rule.Line)
+	case goalMisses:
+		return fmt.Sprintf("Rule %d: pattern does not match", c.Rule.Line)
+	}
+	if g.Branch != "" {
+		return fmt.Sprintf("Rule %d: line %d matches %s", c.Rule.Line, c.Rule.Conds[g.Cond].Line, g.Branch)
+	}
+	return fmt.Sprintf("Rule %d: line %d not met", c.Rule.Line, c.Rule.Conds[g.Cond].Line)
+}
+
+// met returns how many goals of the block a generated case shows
+func (c ruleCoverage) met() int {
+	n := 0
+	for _, g := range c.Goals {
+		if g.Case >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// newRuleCoverage lists the goals of every active rule block: applying,
+// missing unless the pattern matches every path, failing each condition,
+// and matching each branch of a condition's alternation such as
+// (wp-admin|wc-admin)
+func newRuleCoverage(rs *RuleSet) []ruleCoverage {
+	var coverage []ruleCoverage
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		if rule.Disabled {
+			continue
+		}
+		c := ruleCoverage{Rule: rule, Goals: []ruleGoal{{Cond: goalApplies, Case: -1}}}
+		if !isCatchAll(rule) {
+			c.Goals = append(c.Goals, ruleGoal{Cond: goalMisses, Case: -1})
+		}
+		for j, cond := range rule.Conds {
+			c.Goals = append(c.Goals, ruleGoal{Cond: j, Case: -1})
+			if cond.Negate || isCondOperator(cond.Pattern) {
+				continue
+			}
+			if branches := regexWitnesses(cond.Pattern); len(branches) > 1 {
+				for _, branch := range branches {
+					if branch != "" {
+						c.Goals = append(c.Goals, ruleGoal{Cond: j, Branch: branch, Case: -1})
+					}
+				}
+			}
+		}
+		coverage = append(coverage, c)
+	}
+	return coverage
+}
+
+// regexWitnesses synthesizes strings matching a pattern: the shortest one
+// first, then one for every further branch of its alternations
+func regexWitnesses(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	var result []string
+	for _, w := range witnesses(re.Simplify()) {
+		if !slices.Contains(result, w) {
+			result = append(result, w)
+		}
+		if len(result) == maxWitnesses {
+			break
+		}
+	}
+	return result
+}
+
+// witnesses returns strings matching a parsed pattern, nil when none does
+func witnesses(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return nil
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		return classWitnesses(re.Rune)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"x"}
+	case syntax.OpCapture, syntax.OpPlus:
+		return witnesses(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		return append([]string{""}, witnesses(re.Sub[0])...)
+	case syntax.OpAlternate:
+		var result []string
+		for _, sub := range re.Sub {
+			result = append(result, witnesses(sub)...)
+		}
+		return result
+	case syntax.OpConcat:
+		parts := make([][]string, len(re.Sub))
+		for i, sub := range re.Sub {
+			if parts[i] = witnesses(sub); len(parts[i]) == 0 {
+				return nil
+			}
+		}
+		join := func(at int, w string) string {
+			var b strings.Builder
+			for i, part := range parts {
+				if i == at {
+					b.WriteString(w)
+				} else {
+					b.WriteString(part[0])
+				}
+			}
+			return b.String()
+		}
+		result := []string{join(-1, "")}
+		for i, part := range parts {
+			for _, w := range part[1:] {
+				result = append(result, join(i, w))
+			}
+		}
+		return result
+	}
+	// Anchors and other empty-width assertions
+	return []string{""}
+}
+
+// classWitnesses returns every rune of a small character class, which is
+// what the parser makes of alternations such as (at|au), or one typical
+// rune of a large one
+func classWitnesses(ranges []rune) []string {
+	size := 0
+	for i := 0; i < len(ranges); i += 2 {
+		size += int(ranges[i+1]-ranges[i]) + 1
+	}
+	if size <= 8 {
+		var result []string
+		for i := 0; i < len(ranges); i += 2 {
+			for r := ranges[i]; r <= ranges[i+1]; r++ {
+				result = append(result, string(r))
+			}
+		}
+		return result
+	}
+	for _, r := range "a0-/._" {
+		for i := 0; i < len(ranges); i += 2 {
+			if ranges[i] <= r && r <= ranges[i+1] {
+				return []string{string(r)}
+			}
+		}
+	}
+	for i := 0; i < len(ranges); i += 2 {
+		if r := ranges[i]; r > ' ' {
+			return []string{string(r)}
+		} else if ranges[i+1] > ' ' {
+			return []string{"!"}
+		}
+	}
+	return nil
+}
+
+// validRequestPath reports whether a synthesized path can be requested as is
+func validRequestPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.ContainsAny(path, "?#% ") &&
+		!strings.ContainsFunc(path, func(r rune) bool { return !unicode.IsPrint(r) })
+}
+
+// generatePaths collects request paths: the root, a plain page, strings
+// matching the REQUEST_URI conditions and rule patterns, also below the
+// plain page when the pattern is not anchored, and the entries of a virtual
+// document root for -f and -d conditions
+func generatePaths(rs *RuleSet, docRoot DocRoot) []string {
+	paths := []string{"/", generatePlainPath}
+	add := func(prefix, pattern string) {
+		pattern = strings.TrimPrefix(pattern, "!")
+		for _, w := range regexWitnesses(pattern) {
+			candidates := []string{w}
+			if !strings.HasPrefix(w, "/") {
+				candidates[0] = prefix + w
+			}
+			if !strings.HasPrefix(pattern, "^") {
+				candidates = append(candidates, generatePlainPath+strings.TrimPrefix(w, "/"))
+			}
+			for _, path := range candidates {
+				if validRequestPath(path) && !slices.Contains(paths, path) {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	for _, rule := range rs.Rules {
+		if rule.Disabled {
+			continue
+		}
+		for _, cond := range rule.Conds {
+			if strings.EqualFold(cond.TestString, "%{REQUEST_URI}") && !isCondOperator(strings.TrimPrefix(cond.Pattern, "!")) {
+				add("/", cond.Pattern)
+			}
+		}
+		add(rs.Dir, rule.Pattern)
+	}
+	if root, ok := docRoot.(virtualDocRoot); ok {
+		var entries []string
+		for dir := range root.dirs {
+			entries = append(entries, strings.TrimSuffix(dir, "/")+"/")
+		}
+		entries = append(entries, slices.Collect(maps.Keys(root.files))...)
+		slices.Sort(entries)
+		for _, entry := range entries {
+			if !slices.Contains(paths, entry) {
+				paths = append(paths, entry)
+			}
+		}
+	}
+	return paths
+}
+
+// generateCountries collects ISO codes: the configured countries, those
+// named by GEOIP_COUNTRY_CODE conditions, and one named nowhere
+func generateCountries(rs *RuleSet, cfg *Config) []string {
+	var codes []string
+	add := func(iso string) {
+		if !slices.Contains(codes, iso) {
+			codes = append(codes, iso)
+		}
+	}
+	for _, country := range cfg.Countries {
+		add(cfg.isoCode(country.Code))
+	}
+	for _, rule := range rs.Rules {
+		for _, cond := range rule.Conds {
+			if rule.Disabled || !strings.Contains(strings.ToUpper(cond.TestString), geoCountryEnv) {
+				continue
+			}
+			for _, field := range strings.FieldsFunc(cond.Pattern, func(r rune) bool { return !unicode.IsLetter(r) }) {
+				if _, ok := isoCountries[strings.ToUpper(field)]; ok && len(field) == 2 {
+					add(strings.ToUpper(field))
+				}
+			}
+		}
+	}
+	for _, iso := range slices.Sorted(maps.Keys(isoCountries)) {
+		if !slices.Contains(codes, iso) {
+			return append(codes, iso)
+		}
+	}
+	return codes
+}
+
+// generateAgents returns the browser and Googlebot, or the whole catalog
+// when a condition tests the User-Agent
+func generateAgents(rs *RuleSet, cfg *Config) []string {
+	for _, rule := range rs.Rules {
+		for _, cond := range rule.Conds {
+			name := strings.ToUpper(cond.TestString)
+			if !rule.Disabled && (strings.Contains(name, "USER_AGENT") || strings.Contains(name, "USER-AGENT")) {
+				var agents []string
+				for _, agent := range cfg.userAgents() {
+					agents = append(agents, agent.Name)
+				}
+				return agents
+			}
+		}
+	}
+	return []string{"browser", "googlebot"}
+}
+
+// traceLinkTest evaluates the request of a test case against a rule set
+func traceLinkTest(rs *RuleSet, docRoot DocRoot, test LinkTest) (RewriteResult, *RewriteTrace, error) {
+	req, err := newTestRequestMethod(linkTestMethod(test), linkTestURL(test), linkTestHeaders(test))
+	if err != nil {
+		return RewriteResult{}, nil, err
+	}
+	result, trace := rs.EvaluateTrace(newRewriteRequest(req), docRoot)
+	return result, trace, nil
+}
+
+// tracedRules calls fn for every rule evaluation of a trace
+func tracedRules(trace *RewriteTrace, fn func(TraceRule)) {
+	for _, round := range trace.Rounds {
+		for _, step := range round.Rules {
+			fn(step)
+		}
+	}
+}
+
+// generateTests tries every path from every country with every agent and
+// keeps the first input showing each goal of each rule block, expecting
+// the response the rules give it
+func generateTests(rs *RuleSet, docRoot DocRoot, cfg *Config) ([]LinkTest, []ruleCoverage) {
+	coverage := newRuleCoverage(rs)
+	index := map[*RewriteRule]int{}
+	for i, c := range coverage {
+		index[c.Rule] = i
+	}
+	countries, agents := generateCountries(rs, cfg), generateAgents(rs, cfg)
+
+	var tests []LinkTest
+	for _, path := range generatePaths(rs, docRoot) {
+		for _, iso := range countries {
+			for _, agent := range agents {
+				test := LinkTest{Agent: agent, Country: strings.ToUpper(cfg.urlPrefix(iso)), URL: cfg.pageURL(path)}
+				result, trace, err := traceLinkTest(rs, docRoot, test)
+				if err != nil {
+					continue
+				}
+				tracedRules(trace, func(step TraceRule) {
+					i, ok := index[step.Rule]
+					if !ok {
+						return
+					}
+					for j := range coverage[i].Goals {
+						goal := &coverage[i].Goals[j]
+						if goal.Case >= 0 || !goal.met(step) {
+							continue
+						}
+						if test.Name == "" {
+							test.Name = coverage[i].name(*goal)
+							tests = append(tests, test)
+						}
+						goal.Case = len(tests) - 1
+					}
+				})
+				if test.Name == "" {
+					continue
+				}
+				expected := &tests[len(tests)-1]
+				expected.ExpectedStatus, expected.ExpectedResult = result.Status, result.Location
+				if result.Location == "" {
+					expected.ExpectedResult = "No redirect"
+				}
+			}
+		}
+	}
+	return tests, coverage
+}
+
+// countExisting counts the existing test cases each rule block applies to
+func countExisting(rs *RuleSet, docRoot DocRoot, tests []LinkTest, coverage []ruleCoverage) {
+	for _, test := range tests {
+		_, trace, err := traceLinkTest(rs, docRoot, test)
+		if err != nil {
+			continue
+		}
+		applied := map[*RewriteRule]bool{}
+		tracedRules(trace, func(step TraceRule) {
+			applied[step.Rule] = applied[step.Rule] || step.Applied
+		})
+		for i := range coverage {
+			if applied[coverage[i].Rule] {
+				coverage[i].Existing++
+			}
+		}
+	}
+}
+
+// writeGeneratedTests writes test cases in the CSV format of links.testing
+func writeGeneratedTests(w io.Writer, tests []LinkTest) error {
+	rows := [][]string{{"Name", "Agent", "Country", "URL", "Expected status", "Expected result"}}
+	for _, test := range tests {
+		rows = append(rows, []string{test.Name, test.Agent, test.Country, test.URL, strconv.Itoa(test.ExpectedStatus), test.ExpectedResult})
+	}
+	for _, row := range rows {
+		for i, field := range row {
+			if strings.ContainsAny(field, ",\"\n") {
+				row[i] = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(row, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCoverage prints how every rule block is exercised, then the blocks
+// no existing test case applies to and the goals no input shows; it
+// returns the number of such blocks. existing is false without a test file.
+func writeCoverage(w io.Writer, coverage []ruleCoverage, existing bool) (int, error) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tRULE\tGOALS\tEXISTING")
+	for _, c := range coverage {
+		count := "—"
+		if existing {
+			count = strconv.Itoa(c.Existing)
+		}
+		rule := strings.TrimSpace(fmt.Sprintf("%s %s %s", c.Rule.Pattern, c.Rule.Substitution, c.Rule.Flags.Raw))
+		fmt.Fprintf(tw, "%d\t%s\t%d/%d\t%s\n", c.Rule.Line, rule, c.met(), len(c.Goals), count)
+	}
+	if err := tw.Flush(); err != nil {
+		return 0, err
+	}
+
+	uncovered := 0
+	for _, c := range coverage {
+		if existing && c.Existing == 0 {
+			uncovered++
+			fmt.Fprintf(w, "⚠️  Rule %d (RewriteRule %s %s): no existing test case applies\n", c.Rule.Line, c.Rule.Pattern, c.Rule.Substitution)
+		}
+	}
+	for _, c := range coverage {
+		for _, g := range c.Goals {
+			if g.Case < 0 {
+				fmt.Fprintf(w, "❔ %s: no generated input shows it\n", c.name(g))
+			}
+		}
+	}
+	return uncovered, nil
+}
+
+// runGenerateCommand writes test cases exercising every rule block of the
+// .htaccess and prints their coverage
+func runGenerateCommand(args []string) int {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor generate [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Synthesizes countries, paths and agents from the conditions and patterns of the\n.htaccess (-htaccess), evaluates them in-process and writes a test file with an\ninput that applies each rule block and one that fails each of its conditions,\nexpecting the current responses. Prints which blocks the test cases exercise.\n\n")
+		fs.PrintDefaults()
+	}
+	var output = fs.String("o", defaultGeneratedFile, "Test file to write")
+	var testFile = fs.String("test", "", "Existing test file whose coverage of the rule blocks is reported")
+	var fail = fs.Bool("fail", false, "Exit 1 when no existing test case applies a rule block")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	backend := newRewriteBackend(htaccessPath, offlineDocRoot)
+	rs, err := backend.ruleSet()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading %s: %v\n", htaccessPath, err)
+		return exitError
+	}
+	if rs.hasErrors() {
+		fmt.Fprintf(os.Stderr, "❌ %s contains invalid rewrite directives, see the lint subcommand\n", htaccessPath)
+		return exitError
+	}
+	var existing []LinkTest
+	if *testFile != "" {
+		if existing, err = parseLinkTestFile(*testFile); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error reading test file: %v\n", err)
+			return exitError
+		}
+	}
+
+	tests, coverage := generateTests(rs, backend.docRoot, monitorConfig)
+	countExisting(rs, backend.docRoot, existing, coverage)
+
+	f, err := os.Create(*output)
+	if err == nil {
+		err = writeGeneratedTests(f, tests)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error writing test file: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("📋 Rule blocks of %s\n", htaccessPath)
+	uncovered, err := writeCoverage(os.Stdout, coverage, *testFile != "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	fmt.Printf("🧪 %d test case(s) for %d rule block(s) written to %s\n", len(tests), len(coverage), *output)
+	if *fail && uncovered > 0 {
+		fmt.Printf("❌ %d rule block(s) not exercised by %s\n", uncovered, *testFile)
+		return exitFail
+	}
+	return exitPass
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// generateRules excludes admin paths, passes US visitors through, sends
+// Austrians to /at/ and forbids bingbot; the last rule is never reached
+const generateRules = `RewriteEngine On
+RewriteCond %{REQUEST_URI} (wp-admin|wc-admin) [NC]
+RewriteRule ^ - [L]
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^US$
+RewriteRule ^ - [L]
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^AT$
+RewriteCond %{REQUEST_URI} !^/(at|de)(/|$) [NC]
+RewriteRule ^(.*)$ /at/$1 [R=302,L]
+RewriteCond %{HTTP_USER_AGENT} bingbot
+RewriteRule ^ - [F]
+RewriteRule ^ - [L]
+RewriteRule ^never$ /x [R=302,L]
+`
+
+// generateConfig is the default matrix reduced to Germany
+func generateConfig() *Config {
+	cfg := defaultConfig()
+	cfg.Countries = []Country{{Code: "de"}}
+	return cfg
+}
+
+// TestRegexWitnesses tests synthesizing strings for every alternative
+func TestRegexWitnesses(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{`^/(au|at|ca)(/|$)`, "/at/ /au/ /ca/ /at"},
+		{`(wp-json|wc-admin)`, "wp-json wc-admin"},
+		{`^/robots\.txt$`, "/robots.txt"},
+		{`^(.*)$`, " x"},
+		{`^[^/]+/?$`, "a a/"},
+		{`[`, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(regexWitnesses(tt.pattern), " "); got != tt.want {
+			t.Errorf("regexWitnesses(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// TestGenerateTests tests the inputs picked for each goal, their expected
+// outcomes, and that the written file passes against the same rules
+func TestGenerateTests(t *testing.T) {
+	path := writeTestFile(t, ".htaccess", generateRules)
+	rs := parseHtaccess(strings.NewReader(generateRules))
+	tests, coverage := generateTests(rs, dockerDocRoot, generateConfig())
+
+	cases := map[string]LinkTest{}
+	for _, test := range tests {
+		cases[test.Name] = test
+	}
+	for _, want := range []LinkTest{
+		{Name: "Rule 8: applies", Agent: "browser", Country: "AT", URL: "http://localhost:8080/", ExpectedStatus: 302, ExpectedResult: "http://localhost:8080/at/"},
+		{Name: "Rule 8: line 7 not met", Agent: "browser", Country: "AT", URL: "http://localhost:8080/at/", ExpectedStatus: 200, ExpectedResult: "No redirect"},
+		{Name: "Rule 3: line 2 matches wc-admin", Agent: "browser", Country: "DE", URL: "http://localhost:8080/wc-admin", ExpectedStatus: 404, ExpectedResult: "No redirect"},
+		{Name: "Rule 10: applies", Agent: "bingbot", Country: "DE", URL: "http://localhost:8080/", ExpectedStatus: 403, ExpectedResult: "No redirect"},
+	} {
+		got := cases[want.Name]
+		if got.Agent != want.Agent || got.Country != want.Country || got.URL != want.URL ||
+			got.ExpectedStatus != want.ExpectedStatus || got.ExpectedResult != want.ExpectedResult {
+			t.Errorf("case %q = %+v, want %+v", want.Name, got, want)
+		}
+	}
+
+	var unmet []string
+	for _, c := range coverage {
+		for _, g := range c.Goals {
+			if g.Case < 0 {
+				unmet = append(unmet, c.name(g))
+			}
+		}
+	}
+	if got := strings.Join(unmet, "; "); got != "Rule 12: applies; Rule 12: pattern does not match" {
+		t.Errorf("unmet goals = %s, want only those of the unreachable rule", got)
+	}
+
+	var out bytes.Buffer
+	if err := writeGeneratedTests(&out, tests); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseLinkTestFile(writeTestFile(t, "generated.testing", out.String()))
+	if err != nil || len(parsed) != len(tests) {
+		t.Fatalf("parseLinkTestFile() = %d cases, %v, want %d", len(parsed), err, len(tests))
+	}
+	oldTransport := backendTransport
+	defer func() { backendTransport = oldTransport }()
+	backendTransport = newRewriteBackend(path, nil)
+	for _, test := range parsed {
+		if result := runLinkTest(context.Background(), test); !result.Success {
+			t.Errorf("generated case %q fails: %s", test.Name, result.Reason)
+		}
+	}
+}
+
+// TestGenerateCoverage tests counting the existing cases per rule block and
+// flagging the blocks none applies to
+func TestGenerateCoverage(t *testing.T) {
+	rs := parseHtaccess(strings.NewReader(generateRules))
+	_, coverage := generateTests(rs, dockerDocRoot, generateConfig())
+	existing := []LinkTest{
+		{Agent: "browser", Country: "at", URL: "http://localhost:8080/"},
+		{Agent: "browser", Country: "us", URL: "http://localhost:8080/wp-admin/"},
+	}
+	countExisting(rs, dockerDocRoot, existing, coverage)
+
+	var out bytes.Buffer
+	uncovered, err := writeCoverage(&out, coverage, true)
+	if err != nil || uncovered != 4 {
+		t.Errorf("writeCoverage() = %d uncovered, %v, want 4:\n%s", uncovered, err, out.String())
+	}
+	for _, want := range []string{
+		"8     ^(.*)$ /at/$1 [R=302,L]  3/3    1\n",
+		"⚠️  Rule 5 (RewriteRule ^ -): no existing test case applies\n",
+		"⚠️  Rule 12 (RewriteRule ^never$ /x): no existing test case applies\n",
+		"❔ Rule 12: applies: no generated input shows it\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Rule 3 (") {
+		t.Errorf("rule applied by an existing case flagged:\n%s", out.String())
+	}
+
+	out.Reset()
+	if uncovered, _ := writeCoverage(&out, coverage, false); uncovered != 0 || !strings.Contains(out.String(), "3/3    —") {
+		t.Errorf("writeCoverage() without a test file = %d uncovered:\n%s", uncovered, out.String())
+	}
+}
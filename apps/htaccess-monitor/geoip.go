@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// geoAddrEnv is set to the address that was geolocated, like mod_geoip does
+const geoAddrEnv = "GEOIP_ADDR"
+
+// defaultGeoDBFile is where the geodb subcommand writes the fixture database
+const defaultGeoDBFile = "countries.mmdb"
+
+// clientIPHeaders sends each country's IP in X-Forwarded-For and X-Real-IP
+// instead of X-Test-Country (-client-ip)
+var clientIPHeaders bool
+
+// geoDatabase resolves client IPs of the offline backend and the mock
+// server; nil when no -geoip-db was given
+var geoDatabase *geoDB
+
+// geoDB looks up countries in a MaxMind GeoLite2/GeoIP2 Country database
+type geoDB struct {
+	reader *maxminddb.Reader
+}
+
+// geoRecord is the part of a Country database record that is used
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// openGeoDB opens a .mmdb file
+func openGeoDB(path string) (*geoDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database %s: %w", path, err)
+	}
+	return &geoDB{reader: reader}, nil
+}
+
+// Close releases the database
+func (db *geoDB) Close() error {
+	return db.reader.Close()
+}
+
+// country returns the ISO code of an address, or "" for addresses the
+// database does not know such as private ranges
+func (db *geoDB) country(ip net.IP) string {
+	var record geoRecord
+	if err := db.reader.Lookup(ip, &record); err != nil {
+		return ""
+	}
+	if record.Country.ISOCode != "" {
+		return record.Country.ISOCode
+	}
+	return record.RegisteredCountry.ISOCode
+}
+
+// builtinCountryIP returns the address of a built-in country, or ""
+func builtinCountryIP(code string) string {
+	for _, country := range countries {
+		if strings.EqualFold(country.Code, code) {
+			return country.IP
+		}
+	}
+	return ""
+}
+
+// countryIP returns the address sent for a country with -client-ip, or ""
+// when none is configured
+func countryIP(code string) string {
+	for _, country := range monitorConfig.Countries {
+		if strings.EqualFold(country.Code, code) {
+			return country.IP
+		}
+	}
+	return ""
+}
+
+// clientIP returns the address a request is geolocated by: the first valid
+// X-Forwarded-For entry, X-Real-IP, or the connection's address
+func clientIP(req *http.Request) net.IP {
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(value, ",") {
+			if ip := net.ParseIP(strings.TrimSpace(entry)); ip != nil {
+				return ip
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP"))); ip != nil {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// geoEnv sets GEOIP_ADDR and GEOIP_COUNTRY_CODE from the client IP of a
+// request when a database is loaded
+func geoEnv(req *http.Request, env map[string]string) {
+	if geoDatabase == nil {
+		return
+	}
+	ip := clientIP(req)
+	if ip == nil {
+		return
+	}
+	env[geoAddrEnv] = ip.String()
+	env[geoCountryEnv] = geoDatabase.country(ip)
+}
+
+// fixtureNetwork returns the /24 (IPv4) or /48 (IPv6) network of an address
+func fixtureNetwork(address string) (*net.IPNet, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", address)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}, nil
+	}
+	return &net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}, nil
+}
+
+// fixtureNetworks maps the network of every country's IP to its ISO code
+func fixtureNetworks(countries []Country) ([]geoNetwork, error) {
+	var networks []geoNetwork
+	for _, country := range countries {
+		if country.IP == "" {
+			continue
+		}
+		network, err := fixtureNetwork(country.IP)
+		if err != nil {
+			return nil, fmt.Errorf("country %s: %w", country.Code, err)
+		}
+		networks = append(networks, geoNetwork{Network: network, Country: isoCountryCode(country.Code)})
+	}
+	return networks, nil
+}
+
+// writeFixtureDB writes a country database covering the IPs of countries
+func writeFixtureDB(path string, countries []Country) error {
+	networks, err := fixtureNetworks(countries)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeCountryDB(file, networks); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runGeoDBCommand writes a fixture database for the configured countries
+func runGeoDBCommand(args []string) int {
+	fs := flag.NewFlagSet("geodb", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor geodb [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Writes a GeoLite2-Country compatible database mapping the network of every\ncountry's ip to that country, for use with -geoip-db.\n\n")
+		fs.PrintDefaults()
+	}
+	var output = fs.String("o", defaultGeoDBFile, "Output file")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	if err := writeFixtureDB(*output, monitorConfig.Countries); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error writing GeoIP database: %v\n", err)
+		return exitError
+	}
+	fmt.Printf("🌍 GeoIP database written to %s\n", *output)
+	return exitPass
+}
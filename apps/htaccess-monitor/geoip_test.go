@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useGeoDB loads the fixture database of countries for the duration of a test
+func useGeoDB(t *testing.T, countries []Country) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), defaultGeoDBFile)
+	if err := writeFixtureDB(path, countries); err != nil {
+		t.Fatalf("writeFixtureDB() error = %v", err)
+	}
+	db, err := openGeoDB(path)
+	if err != nil {
+		t.Fatalf("openGeoDB() error = %v", err)
+	}
+	old := geoDatabase
+	geoDatabase = db
+	t.Cleanup(func() {
+		geoDatabase = old
+		db.Close()
+	})
+}
+
+// TestClientIP tests which address a request is geolocated by
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		remote  string
+		want    string
+	}{
+		{"forwarded chain", map[string]string{"X-Forwarded-For": "85.214.132.117, 10.0.0.1"}, "127.0.0.1:1234", "85.214.132.117"},
+		{"invalid forwarded entry", map[string]string{"X-Forwarded-For": "unknown, 81.2.69.142"}, "", "81.2.69.142"},
+		{"real ip", map[string]string{"X-Real-IP": " 2001:db8::1 "}, "127.0.0.1:1234", "2001:db8::1"},
+		{"forwarded before real ip", map[string]string{"X-Forwarded-For": "8.8.8.8", "X-Real-IP": "1.1.1.1"}, "", "8.8.8.8"},
+		{"remote address", nil, "[2001:db8::2]:443", "2001:db8::2"},
+		{"nothing", nil, "", "<nil>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://localhost/", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			req.RemoteAddr = tt.remote
+			if got := clientIP(req).String(); got != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGeoIPOfflineBackend tests IP-based geolocation through the offline
+// engine, including IPv6, private and unknown addresses
+func TestGeoIPOfflineBackend(t *testing.T) {
+	useGeoDB(t, []Country{
+		{Code: "de", IP: "85.214.132.117"},
+		{Code: "uk", IP: "81.2.69.142"},
+		{Code: "jp", IP: "2001:db8:1::1"},
+	})
+	backend := newRewriteBackend(writeTestFile(t, ".htaccess", `RewriteEngine On
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^(DE|GB|JP)$
+RewriteRule ^$ /%1/ [R=302,L]
+RewriteRule ^$ /intl/ [R=302,L]
+`), offlineDocRoot)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"ipv4", map[string]string{"X-Forwarded-For": "85.214.132.1"}, "/DE/"},
+		{"alias code", map[string]string{"X-Real-IP": "81.2.69.1"}, "/GB/"},
+		{"ipv6", map[string]string{"X-Forwarded-For": "2001:db8:1:2::3"}, "/JP/"},
+		{"private range", map[string]string{"X-Forwarded-For": "192.168.1.10"}, "/intl/"},
+		{"unknown address", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "/intl/"},
+		{"test header wins", map[string]string{"X-Forwarded-For": "85.214.132.1", "X-Test-Country": "JP"}, "/JP/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			resp, err := backend.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if got := resp.Header.Get("Location"); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("Location = %q, want %s", got, tt.want)
+			}
+		})
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	req.Header.Set("X-Forwarded-For", "85.214.132.1")
+	rewriteReq := newRewriteRequest(req)
+	if rewriteReq.Env[geoAddrEnv] != "85.214.132.1" {
+		t.Errorf("%s = %q, want the geolocated address", geoAddrEnv, rewriteReq.Env[geoAddrEnv])
+	}
+}
+
+// TestTestHeadersClientIP tests that -client-ip replaces X-Test-Country with
+// the country's address
+func TestTestHeadersClientIP(t *testing.T) {
+	old := clientIPHeaders
+	defer func() { clientIPHeaders = old }()
+
+	clientIPHeaders = true
+	headers := testHeaders("de", googleBotUA)
+	if headers["X-Forwarded-For"] != "85.214.132.117" || headers["X-Real-IP"] != "85.214.132.117" {
+		t.Errorf("headers = %v, want the German address", headers)
+	}
+	if _, ok := headers["X-Test-Country"]; ok || headers["User-Agent"] != googleBotUA {
+		t.Errorf("headers = %v, want no X-Test-Country and the User-Agent", headers)
+	}
+
+	if headers := testHeaders("zz", ""); headers["X-Test-Country"] != "ZZ" {
+		t.Errorf("country without an address: headers = %v, want X-Test-Country", headers)
+	}
+
+	clientIPHeaders = false
+	if headers := testHeaders("de", ""); headers["X-Test-Country"] != "DE" || headers["X-Forwarded-For"] != "" {
+		t.Errorf("headers = %v, want X-Test-Country only", headers)
+	}
+}
+
+// TestFixtureNetworks tests the networks generated for the built-in countries
+func TestFixtureNetworks(t *testing.T) {
+	networks, err := fixtureNetworks(countries)
+	if err != nil {
+		t.Fatalf("fixtureNetworks() error = %v", err)
+	}
+	if len(networks) != len(countries) {
+		t.Fatalf("got %d networks, want one per country", len(networks))
+	}
+	for _, n := range networks {
+		if n.Country == "UK" {
+			t.Errorf("uk should be stored as GB")
+		}
+	}
+	if networks[0].Network.String() != "8.8.8.0/24" {
+		t.Errorf("networks[0] = %s, want 8.8.8.0/24", networks[0].Network)
+	}
+
+	if _, err := fixtureNetworks([]Country{{Code: "de", IP: "nope"}}); err == nil {
+		t.Error("fixtureNetworks() expected error for an invalid address")
+	}
+}
@@ -0,0 +1,683 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultHistoryFile is the history read when -history is not given
+const defaultHistoryFile = ".htmonitor-history.jsonl"
+
+// Modes of a recorded run
+const (
+	historyMonitor = "monitor"
+	historyLinks   = "links"
+)
+
+// historyPath is the JSON-lines file every run is appended to; empty, the
+// default, records nothing
+var historyPath string
+
+// historyFile is the history the history subcommand and pane read
+func historyFile() string {
+	if historyPath == "" {
+		return defaultHistoryFile
+	}
+	return historyPath
+}
+
+// historyRun is one run of the monitor or of the link tests
+type historyRun struct {
+	ID         int             `json:"id"`
+	Time       time.Time       `json:"time"`
+	Mode       string          `json:"mode"`
+	Source     string          `json:"source,omitempty"` // the test file of a link test run
+	Htaccess   string          `json:"htaccess"`
+	Hash       string          `json:"htaccess_sha256"` // empty when the file could not be read
+	Backend    string          `json:"backend"`
+	ConfigHash string          `json:"config_sha256,omitempty"` // SHA-256 of the test matrix of a monitor run, as YAML
+	Results    []historyResult `json:"results"`
+}
+
+// historyResult is the outcome of one cell or test case
+type historyResult struct {
+	Key     string `json:"key"`             // names the cell across runs, e.g. "👤 Regular Users · DE · /de/"
+	Outcome string `json:"outcome"`         // e.g. "302 /de/" or "Connection failed"
+	Check   string `json:"check,omitempty"` // pass, fail or error; empty without a declared outcome
+}
+
+// historyKey names a cell by its agent, country and request
+func historyKey(agent, country, request string) string {
+	var parts []string
+	for _, part := range []string{agent, strings.ToUpper(country), request} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// requestPath returns the path and query of a URL
+func requestPath(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.RequestURI()
+	}
+	return rawURL
+}
+
+// htaccessHash returns the SHA-256 of a file, or "" when it cannot be read
+func htaccessHash(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return sha256Hex(data)
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// newHistoryRun starts the record of a run of the current .htaccess
+func newHistoryRun(mode, source string, at time.Time) historyRun {
+	return historyRun{
+		Time:     at,
+		Mode:     mode,
+		Source:   source,
+		Htaccess: htaccessPath,
+		Hash:     htaccessHash(htaccessPath),
+		Backend:  backendName(),
+	}
+}
+
+// add appends a result, numbering keys that were already used
+func (r *historyRun) add(result historyResult) {
+	key := result.Key
+	for n := 2; r.result(result.Key) != nil; n++ {
+		result.Key = fmt.Sprintf("%s (%d)", key, n)
+	}
+	r.Results = append(r.Results, result)
+}
+
+// result returns the result of a key, or nil
+func (r historyRun) result(key string) *historyResult {
+	for i := range r.Results {
+		if r.Results[i].Key == key {
+			return &r.Results[i]
+		}
+	}
+	return nil
+}
+
+// monitorHistoryRun records a completed matrix run
+func monitorHistoryRun(ts TestSuite, cfg *Config) historyRun {
+	run := newHistoryRun(historyMonitor, "", ts.LastUpdate)
+	if data, err := yaml.Marshal(cfg); err == nil {
+		run.ConfigHash = sha256Hex(data)
+	}
+	for _, group := range ts.Groups {
+		for _, result := range group.Results {
+			key := historyKey(group.Title, result.Country.Code, requestPath(result.URL))
+			if group.Special {
+				key = historyKey(group.Title, "", result.Country.Name)
+			}
+			entry := historyResult{Key: key, Outcome: cellOutcome(result)}
+			switch {
+			case result.Expect == nil:
+			case result.Status == 0:
+				entry.Check = "error"
+			case result.Pass:
+				entry.Check = "pass"
+			default:
+				entry.Check = "fail"
+			}
+			run.add(entry)
+		}
+	}
+	return run
+}
+
+// linkHistoryRun records a link test run; tests without a result were
+// skipped and are left out
+func linkHistoryRun(testFile string, tests []LinkTest, results []LinkTestResult, started time.Time) historyRun {
+	run := newHistoryRun(historyLinks, testFile, started)
+	for i, result := range results {
+		test := tests[i]
+		request := test.Name
+		if request == "" {
+			request = linkTestMethod(test) + " " + requestPath(linkTestURL(test))
+		}
+		run.add(historyResult{
+			Key:     historyKey(test.Agent, test.Country, request),
+			Outcome: responseOutcome(linkTestURL(test), result.Status, result.Result, result.Response),
+			Check:   strings.ToLower(ciResultLabel(result)),
+		})
+	}
+	return run
+}
+
+// loadHistory reads every recorded run; a missing file is an empty history
+func loadHistory(path string) ([]historyRun, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []historyRun
+	decoder := json.NewDecoder(f)
+	for {
+		var run historyRun
+		if err := decoder.Decode(&run); err == io.EOF {
+			return runs, nil
+		} else if err != nil {
+			return runs, fmt.Errorf("%s: run %d: %w", path, len(runs)+1, err)
+		}
+		runs = append(runs, run)
+	}
+}
+
+// lastHistoryID returns the ID of the last recorded run, reading only the
+// end of the file; a missing or empty history has none
+func lastHistoryID(path string) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// Read ever larger tails until one holds the whole last line
+	size := info.Size()
+	for chunk := int64(4096); ; chunk *= 2 {
+		start := size - chunk
+		if start < 0 {
+			start = 0
+		}
+		buf := make([]byte, size-start)
+		if _, err := f.ReadAt(buf, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		tail := bytes.TrimRight(buf, "\n")
+		i := bytes.LastIndexByte(tail, '\n')
+		if i < 0 && start > 0 {
+			continue
+		}
+		if len(tail) == 0 {
+			return 0, nil
+		}
+		var last struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(tail[i+1:], &last); err != nil {
+			return 0, fmt.Errorf("%s: last run: %w", path, err)
+		}
+		return last.ID, nil
+	}
+}
+
+// appendHistory numbers a run after the last recorded one and appends it
+func appendHistory(path string, run historyRun) (historyRun, error) {
+	last, err := lastHistoryID(path)
+	if err != nil {
+		return run, err
+	}
+	run.ID = last + 1
+	data, err := json.Marshal(run)
+	if err != nil {
+		return run, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return run, err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return run, err
+	}
+	return run, f.Close()
+}
+
+// recordLinkRun appends a link test run to the history when -history is
+// given; a history that cannot be written does not fail the run
+func recordLinkRun(testFile string, tests []LinkTest, results []LinkTestResult, started time.Time) {
+	if historyPath == "" {
+		return
+	}
+	if _, err := appendHistory(historyPath, linkHistoryRun(testFile, tests, results, started)); err != nil {
+		log.Printf("Run not recorded in the history: %v", err)
+	}
+}
+
+// historyRecordedMsg reports a monitor run appended to the history
+type historyRecordedMsg struct {
+	run historyRun
+	err error
+}
+
+// recordHistoryCmd appends a monitor run to the history off the UI loop
+func recordHistoryCmd(run historyRun) tea.Cmd {
+	return func() tea.Msg {
+		run, err := appendHistory(historyPath, run)
+		return historyRecordedMsg{run: run, err: err}
+	}
+}
+
+// passRate returns the share of checked results that passed
+func (r historyRun) passRate() (float64, bool) {
+	checked, passed := 0, 0
+	for _, result := range r.Results {
+		if result.Check == "" {
+			continue
+		}
+		checked++
+		if result.Check == "pass" {
+			passed++
+		}
+	}
+	if checked == 0 {
+		return 0, false
+	}
+	return float64(passed) / float64(checked), true
+}
+
+// failures returns the number of results that failed or errored
+func (r historyRun) failures() int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Check == "fail" || result.Check == "error" {
+			n++
+		}
+	}
+	return n
+}
+
+// sameSource reports whether two runs test the same thing: the matrix, or
+// the same test file
+func (r historyRun) sameSource(other historyRun) bool {
+	return r.Mode == other.Mode && r.Source == other.Source
+}
+
+// label names a run in listings, e.g. "#12 2025-01-02 15:04:05 monitor"
+func (r historyRun) label() string {
+	label := fmt.Sprintf("#%d %s %s", r.ID, r.Time.Format("2006-01-02 15:04:05"), r.Mode)
+	if r.Source != "" {
+		label += " " + r.Source
+	}
+	return label
+}
+
+// shortHash abbreviates a content hash
+func shortHash(hash string) string {
+	if hash == "" {
+		return "-"
+	}
+	return hash[:min(8, len(hash))]
+}
+
+// diffHistoryRuns lists the results whose outcome or check differs between
+// two runs, and the ones only one of them has
+func diffHistoryRuns(before, after historyRun) []string {
+	var lines []string
+	for _, result := range after.Results {
+		previous := before.result(result.Key)
+		switch {
+		case previous == nil:
+			lines = append(lines, fmt.Sprintf("%s: added, %s", result.Key, result.describe()))
+		case previous.Outcome != result.Outcome || previous.Check != result.Check:
+			lines = append(lines, fmt.Sprintf("%s: %s → %s", result.Key, previous.describe(), result.describe()))
+		}
+	}
+	for _, result := range before.Results {
+		if after.result(result.Key) == nil {
+			lines = append(lines, fmt.Sprintf("%s: removed", result.Key))
+		}
+	}
+	return lines
+}
+
+// describe renders the outcome and check of a result, e.g. "302 /de/ ❌"
+func (r historyResult) describe() string {
+	switch r.Check {
+	case "pass":
+		return r.Outcome + " ✅"
+	case "fail", "error":
+		return r.Outcome + " ❌"
+	}
+	return r.Outcome
+}
+
+// sparkline draws pass rates as block characters; runs without checks are dots
+func sparkline(runs []historyRun) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	var line []rune
+	for _, run := range runs {
+		rate, ok := run.passRate()
+		if !ok {
+			line = append(line, '·')
+			continue
+		}
+		line = append(line, blocks[int(rate*float64(len(blocks)-1)+0.5)])
+	}
+	return string(line)
+}
+
+// writeHistory lists runs with their pass rate and the number of results
+// changed since the previous run of the same source, then the pass-rate
+// trend of each source
+func writeHistory(w io.Writer, runs []historyRun, all []historyRun) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tMODE\t.HTACCESS\tBACKEND\tPASS RATE\tFAILING\tCHANGED")
+	for _, run := range runs {
+		rate := "—"
+		if r, ok := run.passRate(); ok {
+			rate = fmt.Sprintf("%.1f%%", 100*r)
+		}
+		changed := "—"
+		if previous, ok := previousRun(all, run); ok {
+			changed = strconv.Itoa(len(diffHistoryRuns(previous, run)))
+		}
+		mode := run.Mode
+		if run.Source != "" {
+			mode += " " + run.Source
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", run.ID, run.Time.Format("2006-01-02 15:04:05"), mode, shortHash(run.Hash), run.Backend, rate, run.failures(), changed)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var sources [][]historyRun
+	for _, run := range runs {
+		found := false
+		for i := range sources {
+			if sources[i][0].sameSource(run) {
+				sources[i] = append(sources[i], run)
+				found = true
+				break
+			}
+		}
+		if !found {
+			sources = append(sources, []historyRun{run})
+		}
+	}
+	fmt.Fprintln(w)
+	for _, source := range sources {
+		name := source[0].Mode
+		if source[0].Source != "" {
+			name += " " + source[0].Source
+		}
+		fmt.Fprintf(w, "📈 %s: %s\n", name, sparkline(source))
+	}
+	return nil
+}
+
+// previousRun returns the run before run with the same source
+func previousRun(runs []historyRun, run historyRun) (historyRun, bool) {
+	var previous historyRun
+	found := false
+	for _, r := range runs {
+		if r.ID == run.ID {
+			return previous, found
+		}
+		if r.sameSource(run) {
+			previous, found = r, true
+		}
+	}
+	return historyRun{}, false
+}
+
+// findRun returns the run with an ID
+func findRun(runs []historyRun, id int) (historyRun, bool) {
+	for _, run := range runs {
+		if run.ID == id {
+			return run, true
+		}
+	}
+	return historyRun{}, false
+}
+
+// matchesCell reports whether every word of filter is a word of the key,
+// ignoring case, so "DE" selects the German cells but not /de/ paths
+func matchesCell(key, filter string) bool {
+	words := map[string]bool{}
+	for _, word := range strings.Fields(strings.ToLower(key)) {
+		words[word] = true
+	}
+	for _, word := range strings.Fields(strings.ToLower(filter)) {
+		if !words[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeCellHistory prints, for each cell matching filter, the runs in which
+// its outcome changed and since when it fails
+func writeCellHistory(w io.Writer, runs []historyRun, filter string) int {
+	var keys []string
+	seen := map[string]bool{}
+	for _, run := range runs {
+		for _, result := range run.Results {
+			if !seen[result.Key] && matchesCell(result.Key, filter) {
+				seen[result.Key] = true
+				keys = append(keys, result.Key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		fmt.Fprintln(w, key)
+		var last *historyResult
+		var failingSince *historyRun
+		for _, run := range runs {
+			result := run.result(key)
+			if result == nil {
+				continue
+			}
+			if last == nil || last.Outcome != result.Outcome || last.Check != result.Check {
+				fmt.Fprintf(w, "   %s  .htaccess %s  %s\n", run.label(), shortHash(run.Hash), result.describe())
+			}
+			failing := result.Check == "fail" || result.Check == "error"
+			switch {
+			case failing && failingSince == nil:
+				failingSince = &run
+			case !failing:
+				failingSince = nil
+			}
+			last = result
+		}
+		if failingSince != nil {
+			fmt.Fprintf(w, "   ❌ failing since run #%d (%s, .htaccess %s)\n", failingSince.ID, failingSince.Time.Format("2006-01-02 15:04:05"), shortHash(failingSince.Hash))
+		}
+	}
+	return len(keys)
+}
+
+// runHistoryCommand lists the recorded runs, one run, the changes between
+// two runs or the history of a cell
+func runHistoryCommand(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor history [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Lists the runs recorded in the history file (-history) with their pass rate and\nthe pass-rate trend of the monitor and of each test file.\n\n")
+		fs.PrintDefaults()
+	}
+	var last = fs.Int("n", 20, "List the last N runs (0 = all)")
+	var show = fs.Int("show", 0, "Print every result of the run with this ID")
+	var compare = fs.String("compare", "", "Print the results that differ between two runs, e.g. 12,15")
+	var cell = fs.String("cell", "", "Print when the outcome of the matching cells changed, e.g. \"DE /test-content\"")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	runs, err := loadHistory(historyFile())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading history: %v\n", err)
+		return exitError
+	}
+	if len(runs) == 0 {
+		fmt.Printf("No runs recorded in %s yet; record them with -history %s\n", historyFile(), historyFile())
+		return exitPass
+	}
+
+	switch {
+	case *show != 0:
+		run, ok := findRun(runs, *show)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "❌ No run #%d in %s\n", *show, historyFile())
+			return exitError
+		}
+		fmt.Printf("%s  .htaccess %s (%s)  backend %s\n", run.label(), shortHash(run.Hash), run.Htaccess, run.Backend)
+		for _, result := range run.Results {
+			fmt.Printf("   %s: %s\n", result.Key, result.describe())
+		}
+
+	case *compare != "":
+		ids := strings.Split(*compare, ",")
+		var pair []historyRun
+		for _, id := range ids {
+			n, err := strconv.Atoi(strings.TrimSpace(id))
+			run, ok := findRun(runs, n)
+			if err != nil || !ok {
+				fmt.Fprintf(os.Stderr, "❌ No run %q in %s\n", id, historyFile())
+				return exitError
+			}
+			pair = append(pair, run)
+		}
+		if len(pair) != 2 {
+			fmt.Fprintln(os.Stderr, "❌ -compare takes two run IDs, e.g. 12,15")
+			return exitError
+		}
+		fmt.Printf("%s → %s\n", pair[0].label(), pair[1].label())
+		lines := diffHistoryRuns(pair[0], pair[1])
+		for _, line := range lines {
+			fmt.Println("   " + line)
+		}
+		fmt.Printf("%d result(s) differ\n", len(lines))
+
+	case *cell != "":
+		if writeCellHistory(os.Stdout, runs, *cell) == 0 {
+			fmt.Fprintf(os.Stderr, "❌ No recorded cell matches %q\n", *cell)
+			return exitError
+		}
+
+	default:
+		listed := runs
+		if *last > 0 && len(listed) > *last {
+			listed = listed[len(listed)-*last:]
+		}
+		if err := writeHistory(os.Stdout, listed, runs); err != nil {
+			return exitError
+		}
+	}
+	return exitPass
+}
+
+// historyListLimit is how many runs the history pane of the monitor lists
+const historyListLimit = 10
+
+// openHistory loads the history for the monitor's history pane, selecting
+// the latest run
+func (m *model) openHistory() {
+	m.history, m.historyErr = loadHistory(historyFile())
+	m.historyCursor, m.historyMark = len(m.history)-1, -1
+}
+
+// historyKeyPress handles the keys of the history pane
+func (m *model) historyKeyPress(key string) {
+	switch key {
+	case "up", "k":
+		m.historyCursor = max(m.historyCursor-1, 0)
+	case "down", "j":
+		m.historyCursor = min(m.historyCursor+1, len(m.history)-1)
+	case "enter", " ":
+		if m.historyMark == m.historyCursor {
+			m.historyMark = -1
+		} else {
+			m.historyMark = m.historyCursor
+		}
+	}
+}
+
+// historyView renders the recorded runs and, once a run is marked, its
+// differences from the selected one
+func (m model) historyView() string {
+	paneStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("42")).
+		Padding(0, 1).
+		MarginTop(1)
+
+	switch {
+	case m.historyErr != nil:
+		return paneStyle.Render("🕘 History\n❌ " + m.historyErr.Error())
+	case len(m.history) == 0:
+		return paneStyle.Render("🕘 No runs recorded in " + historyFile() + " yet; record them with -history " + historyFile())
+	}
+
+	lines := []string{fmt.Sprintf("🕘 %d run(s) in %s  %s", len(m.history), historyFile(), sparkline(m.history))}
+	first := min(max(m.historyCursor-historyListLimit/2, 0), max(len(m.history)-historyListLimit, 0))
+	for i := first; i < min(first+historyListLimit, len(m.history)); i++ {
+		run := m.history[i]
+		prefix := "  "
+		if i == m.historyCursor {
+			prefix = "▸ "
+		}
+		if i == m.historyMark {
+			prefix += "● "
+		} else {
+			prefix += "  "
+		}
+		rate := "—"
+		if r, ok := run.passRate(); ok {
+			rate = fmt.Sprintf("%.1f%%", 100*r)
+		}
+		lines = append(lines, fmt.Sprintf("%s%s  .htaccess %s  pass %s  %d failing", prefix, run.label(), shortHash(run.Hash), rate, run.failures()))
+	}
+
+	if m.historyMark >= 0 && m.historyMark != m.historyCursor {
+		before, after := m.history[min(m.historyMark, m.historyCursor)], m.history[max(m.historyMark, m.historyCursor)]
+		changes := diffHistoryRuns(before, after)
+		lines = append(lines, "", fmt.Sprintf("🔀 #%d → #%d: %d result(s) differ", before.ID, after.ID, len(changes)))
+		for i, change := range changes {
+			if i == changeListLimit {
+				lines = append(lines, fmt.Sprintf("… %d more (history -compare %d,%d lists them)", len(changes)-changeListLimit, before.ID, after.ID))
+				break
+			}
+			lines = append(lines, change)
+		}
+	} else {
+		lines = append(lines, "", "↑/↓ select, enter marks a run to compare with the selected one, 'h' closes")
+	}
+	return paneStyle.Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// useHistory records the runs of a test in a temporary history file
+func useHistory(t *testing.T) string {
+	t.Helper()
+	old := historyPath
+	historyPath = filepath.Join(t.TempDir(), defaultHistoryFile)
+	t.Cleanup(func() { historyPath = old })
+	return historyPath
+}
+
+// historyFixture is a link test run of two cases
+func historyFixture(id int, at time.Time, de, fr string, deCheck string) historyRun {
+	return historyRun{ID: id, Time: at, Mode: historyLinks, Source: "links.testing", Hash: strings.Repeat("ab", 32), Results: []historyResult{
+		{Key: "googlebot · DE · GET /", Outcome: de, Check: deCheck},
+		{Key: "googlebot · FR · GET /", Outcome: fr, Check: "pass"},
+	}}
+}
+
+// TestHistoryStore tests appending runs and reading them back
+func TestHistoryStore(t *testing.T) {
+	path := useHistory(t)
+	if runs, err := loadHistory(path); err != nil || runs != nil {
+		t.Fatalf("loadHistory() of a missing file = %v, %v", runs, err)
+	}
+
+	at := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i := range 2 {
+		run, err := appendHistory(path, historyFixture(0, at.Add(time.Duration(i)*time.Hour), "302 /de/", "302 /fr/", "pass"))
+		if err != nil || run.ID != i+1 {
+			t.Fatalf("appendHistory() = #%d, %v, want #%d", run.ID, err, i+1)
+		}
+	}
+	runs, err := loadHistory(path)
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("loadHistory() = %d runs, %v", len(runs), err)
+	}
+	if runs[1].ID != 2 || !runs[1].Time.Equal(at.Add(time.Hour)) || runs[1].Results[0].Outcome != "302 /de/" {
+		t.Errorf("runs[1] = %+v", runs[1])
+	}
+
+	// Only the last line is read to number a run, however long it is
+	long := historyFixture(0, at, strings.Repeat("302 /de/", 1000), "302 /fr/", "pass")
+	if run, err := appendHistory(path, long); err != nil || run.ID != 3 {
+		t.Fatalf("appendHistory() after a short run = #%d, %v, want #3", run.ID, err)
+	}
+	if run, err := appendHistory(path, historyFixture(0, at, "302 /de/", "302 /fr/", "pass")); err != nil || run.ID != 4 {
+		t.Fatalf("appendHistory() after a long run = #%d, %v, want #4", run.ID, err)
+	}
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("{not json\n")
+	f.Close()
+	if _, err := loadHistory(path); err == nil || !strings.Contains(err.Error(), "run 5") {
+		t.Errorf("loadHistory() of a corrupt run error = %v", err)
+	}
+	if _, err := appendHistory(path, historyFixture(0, at, "302 /de/", "302 /fr/", "pass")); err == nil || !strings.Contains(err.Error(), "last run") {
+		t.Errorf("appendHistory() after a corrupt run error = %v", err)
+	}
+}
+
+// TestLinkHistoryRun tests the keys, outcomes and checks of a link test run
+func TestLinkHistoryRun(t *testing.T) {
+	oldPath := htaccessPath
+	defer func() { htaccessPath = oldPath }()
+	htaccessPath = writeTestFile(t, ".htaccess", "RewriteEngine On\n")
+
+	tests := []LinkTest{
+		{Agent: "googlebot", Country: "de", URL: "http://localhost:8080/?a=1"},
+		{Agent: "googlebot", Country: "de", URL: "http://localhost:8080/?a=1"},
+		{Name: "Admin", URL: "http://localhost:8080/wp-admin/"},
+		{Agent: "chrome", Country: "fr", URL: "http://localhost:8080/"},
+	}
+	results := []LinkTestResult{
+		{Status: 302, Result: "http://localhost:8080/de/", Response: http.Header{"Location": {"http://localhost:8080/de/"}}, Success: true},
+		{Status: 200, Result: "No redirect"},
+		{Status: 0, Result: "Connection failed"},
+	}
+	run := linkHistoryRun("links.testing", tests, results, time.Now())
+	want := []historyResult{
+		{Key: "googlebot · DE · GET /?a=1", Outcome: "302 /de/", Check: "pass"},
+		{Key: "googlebot · DE · GET /?a=1 (2)", Outcome: "200", Check: "fail"},
+		{Key: "Admin", Outcome: "Connection failed", Check: "error"},
+	}
+	if len(run.Results) != len(want) {
+		t.Fatalf("results = %+v, want %d (skipped tests left out)", run.Results, len(want))
+	}
+	for i := range want {
+		if run.Results[i] != want[i] {
+			t.Errorf("results[%d] = %+v, want %+v", i, run.Results[i], want[i])
+		}
+	}
+	if len(run.Hash) != 64 || run.Source != "links.testing" || run.Mode != historyLinks {
+		t.Errorf("run = %+v", run)
+	}
+	if rate, ok := run.passRate(); !ok || rate != 1.0/3 || run.failures() != 2 {
+		t.Errorf("passRate() = %v, %v; failures() = %d", rate, ok, run.failures())
+	}
+}
+
+// TestRecordLinkRun tests that link test runs are recorded only with -history
+func TestRecordLinkRun(t *testing.T) {
+	t.Chdir(t.TempDir())
+	old := historyPath
+	defer func() { historyPath = old }()
+	tests := []LinkTest{{Agent: "googlebot", Country: "de", URL: "http://localhost:8080/"}}
+	results := []LinkTestResult{{Status: 200, Result: "No redirect"}}
+
+	historyPath = ""
+	recordLinkRun("links.testing", tests, results, time.Now())
+	if _, err := os.Stat(defaultHistoryFile); !os.IsNotExist(err) {
+		t.Errorf("run recorded without -history: %v", err)
+	}
+
+	historyPath = "runs.jsonl"
+	recordLinkRun("links.testing", tests, results, time.Now())
+	if runs, err := loadHistory(historyPath); err != nil || len(runs) != 1 {
+		t.Errorf("recorded %d runs, %v, want 1", len(runs), err)
+	}
+}
+
+// TestDiffHistoryRuns tests changed, added and removed results
+func TestDiffHistoryRuns(t *testing.T) {
+	before := historyFixture(1, time.Now(), "302 /de/", "302 /fr/", "pass")
+	after := historyFixture(2, time.Now(), "200", "302 /fr/", "fail")
+	after.Results[1].Key = "googlebot · AT · GET /"
+	got := strings.Join(diffHistoryRuns(before, after), "\n")
+	want := "googlebot · DE · GET /: 302 /de/ ✅ → 200 ❌\ngooglebot · AT · GET /: added, 302 /fr/ ✅\ngooglebot · FR · GET /: removed"
+	if got != want {
+		t.Errorf("diffHistoryRuns() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestWriteCellHistory tests finding the run a cell started failing in
+func TestWriteCellHistory(t *testing.T) {
+	at := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	runs := []historyRun{
+		historyFixture(1, at, "302 /de/", "302 /fr/", "pass"),
+		historyFixture(2, at.Add(time.Hour), "200", "302 /fr/", "fail"),
+		historyFixture(3, at.Add(2*time.Hour), "302 /de/", "302 /fr/", "pass"),
+		historyFixture(4, at.Add(3*time.Hour), "200", "302 /fr/", "fail"),
+		historyFixture(5, at.Add(4*time.Hour), "200", "302 /fr/", "fail"),
+	}
+	var out bytes.Buffer
+	if n := writeCellHistory(&out, runs, "de"); n != 1 {
+		t.Fatalf("writeCellHistory() matched %d cells, want 1:\n%s", n, out.String())
+	}
+	for _, want := range []string{
+		"   #1 2025-01-02 15:04:05 links links.testing  .htaccess abababab  302 /de/ ✅\n",
+		"   #4 2025-01-02 18:04:05 links links.testing  .htaccess abababab  200 ❌\n",
+		"   ❌ failing since run #4 (2025-01-02 18:04:05, .htaccess abababab)\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "#5") {
+		t.Errorf("unchanged run listed:\n%s", out.String())
+	}
+	if n := writeCellHistory(&bytes.Buffer{}, runs, "GET"); n != 2 {
+		t.Errorf("writeCellHistory(GET) matched %d cells, want 2", n)
+	}
+}
+
+// TestWriteHistory tests the run listing and the pass-rate trend
+func TestWriteHistory(t *testing.T) {
+	at := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	runs := []historyRun{
+		historyFixture(1, at, "302 /de/", "302 /fr/", "pass"),
+		{ID: 2, Time: at.Add(time.Minute), Mode: historyMonitor, Results: []historyResult{{Key: "x", Outcome: "200"}}},
+		historyFixture(3, at.Add(time.Hour), "200", "302 /fr/", "fail"),
+	}
+	var out bytes.Buffer
+	if err := writeHistory(&out, runs, runs); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"1   2025-01-02 15:04:05  links links.testing  abababab",
+		"100.0%     0        —",
+		"50.0%      1        1",
+		"📈 links links.testing: █▅\n",
+		"📈 monitor: ·\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestModelHistory tests that monitor runs are recorded and compared in the
+// history pane
+func TestModelHistory(t *testing.T) {
+	path := useHistory(t)
+	first := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	m := completeRun(initialModel(), diffSuite(t, first, map[string]string{"de": "/de/test-content"}))
+	m = completeRun(m, diffSuite(t, first.Add(time.Minute), nil))
+	runs, err := loadHistory(path)
+	if err != nil || len(runs) != 2 || len(runs[0].ConfigHash) != 64 || runs[1].ConfigHash != runs[0].ConfigHash {
+		t.Fatalf("recorded %d runs, %v", len(runs), err)
+	}
+
+	press := func(key string) {
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = updated.(model)
+	}
+	press("h")
+	press(" ")
+	press("k")
+	view := m.View()
+	for _, want := range []string{"🕘 2 run(s)", "🔀 #1 → #2: 1 result(s) differ", "👤 Regular Users · DE · /test-content: 302 /de/test-content → 200"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("history pane missing %q:\n%s", want, view)
+		}
+	}
+	press("h")
+	if strings.Contains(m.View(), "🕘") {
+		t.Error("'h' did not close the history pane")
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultHTMLReport is the file written by the report subcommand
+const defaultHTMLReport = "htmonitor-report.html"
+
+// htmlReport is the data of a self-contained HTML report
+type htmlReport struct {
+	Generated    string
+	Backend      string
+	BaseURL      string
+	Htaccess     string
+	HtaccessHash string
+	TestFile     string
+	Summary      htmlSummary
+	Pages        []htmlColumnGroup // page headers above the agent columns
+	Agents       []string
+	Rows         []htmlRow // one row per country
+	Special      []htmlCell
+	LinkTests    []htmlCell
+}
+
+// htmlSummary counts the cells and link tests of a report
+type htmlSummary struct {
+	Cells       int
+	Checked     int
+	CellsFailed int
+	Links       int
+	LinksFailed int
+	Errors      int
+}
+
+// htmlColumnGroup is a page header spanning its agent columns
+type htmlColumnGroup struct {
+	Name string
+	Span int
+}
+
+// htmlRow is a country of the redirect map
+type htmlRow struct {
+	Country Country
+	Failing bool
+	Cells   []htmlCell
+}
+
+// htmlCell is a single request with its response, rendered with an
+// expandable detail block
+type htmlCell struct {
+	Label    string
+	Method   string
+	URL      string
+	Status   int
+	Result   string
+	Class    string // ok, redirect, client-error, error, pass or fail
+	Check    string // PASS, FAIL or empty when nothing was declared
+	Failing  bool
+	Expected string
+	Reason   string
+	Chain    string
+	Hops     []RedirectHop
+	Request  []string // "Name: value" lines
+	Response []string
+	Duration string
+	Line     int
+}
+
+// newHTMLReport builds the report of a matrix run and, optionally, a link test run
+func newHTMLReport(ts *TestSuite, testFile string, results []LinkTestResult) htmlReport {
+	report := htmlReport{
+		Generated: time.Now().Format("2006-01-02 15:04:05 MST"),
+		Backend:   backendName(),
+		BaseURL:   monitorConfig.BaseURL,
+		Htaccess:  htaccessPath,
+		TestFile:  testFile,
+	}
+	if hash, err := fileHash(htaccessPath); err == nil {
+		report.HtaccessHash = hash
+	} else {
+		report.HtaccessHash = "unavailable (" + err.Error() + ")"
+	}
+
+	if ts != nil {
+		report.addMatrix(*ts)
+	}
+	for _, result := range results {
+		cell := linkResultCell(result)
+		report.LinkTests = append(report.LinkTests, cell)
+		report.Summary.Links++
+		if cell.Failing {
+			report.Summary.LinksFailed++
+		}
+	}
+	return report
+}
+
+// addMatrix lays out the page × agent tables as one row per country
+func (r *htmlReport) addMatrix(ts TestSuite) {
+	for _, group := range ts.Groups {
+		if group.Special {
+			for _, result := range group.Results {
+				r.Special = append(r.Special, r.countCell(testResultCell(result.Country.Name, result)))
+			}
+			continue
+		}
+
+		if n := len(r.Pages); n > 0 && r.Pages[n-1].Name == group.Section {
+			r.Pages[n-1].Span++
+		} else {
+			r.Pages = append(r.Pages, htmlColumnGroup{Name: group.Section, Span: 1})
+		}
+		r.Agents = append(r.Agents, group.Title)
+
+		for i, result := range group.Results {
+			if i == len(r.Rows) {
+				r.Rows = append(r.Rows, htmlRow{Country: result.Country})
+			}
+			cell := r.countCell(testResultCell(result.Country.Name, result))
+			r.Rows[i].Cells = append(r.Rows[i].Cells, cell)
+			r.Rows[i].Failing = r.Rows[i].Failing || cell.Failing
+		}
+	}
+}
+
+// countCell adds a matrix cell to the summary
+func (r *htmlReport) countCell(cell htmlCell) htmlCell {
+	r.Summary.Cells++
+	if cell.Check != "" {
+		r.Summary.Checked++
+	}
+	if cell.Check == "FAIL" {
+		r.Summary.CellsFailed++
+	}
+	if cell.Status == 0 {
+		r.Summary.Errors++
+	}
+	return cell
+}
+
+// testResultCell converts a matrix cell
+func testResultCell(label string, result TestResult) htmlCell {
+	cell := htmlCell{
+		Label:    label,
+		Method:   "GET",
+		URL:      result.URL,
+		Status:   result.Status,
+		Result:   result.Result,
+		Chain:    describeChain(result.Result, result.Chain, result.ChainIssue),
+		Hops:     result.Chain,
+		Request:  headerLines(result.Headers),
+		Response: responseHeaderLines(result.Response),
+	}
+	if result.Duration > 0 {
+		cell.Duration = result.Duration.Round(time.Microsecond).String()
+	}
+	if result.Expect != nil {
+		cell.Expected = fmt.Sprintf("%d %s", result.Expect.ExpectedStatus, result.Expect.ExpectedResult)
+		cell.Check = "FAIL"
+		if result.Pass {
+			cell.Check = "PASS"
+		}
+		cell.Reason = result.Reason
+	}
+	cell.classify()
+	return cell
+}
+
+// linkResultCell converts a link test result
+func linkResultCell(result LinkTestResult) htmlCell {
+	test := result.Test
+	label := test.Name
+	if label == "" {
+		label = strings.TrimSpace(test.Agent + " " + countryLabel(test.Country))
+	}
+	cell := htmlCell{
+		Label:    label,
+		Method:   linkTestMethod(test),
+		URL:      linkTestURL(test),
+		Status:   result.Status,
+		Result:   result.Result,
+		Check:    "FAIL",
+		Expected: fmt.Sprintf("%d %s", test.ExpectedStatus, test.ExpectedResult),
+		Reason:   result.Reason,
+		Chain:    describeChain(result.Result, result.Chain, result.ChainIssue),
+		Hops:     result.Chain,
+		Request:  headerLines(linkTestHeaders(test)),
+		Response: responseHeaderLines(result.Response),
+		Duration: result.Duration.Round(time.Microsecond).String(),
+		Line:     test.Line,
+	}
+	if result.Success {
+		cell.Check = "PASS"
+	}
+	cell.classify()
+	return cell
+}
+
+// classify sets the color class of a cell and whether it counts as failing
+func (c *htmlCell) classify() {
+	switch {
+	case c.Status == 0:
+		c.Class = "error"
+	case c.Check == "PASS":
+		c.Class = "pass"
+	case c.Check == "FAIL":
+		c.Class = "fail"
+	case c.Status >= 300 && c.Status < 400:
+		c.Class = "redirect"
+	case c.Status >= 400:
+		c.Class = "client-error"
+	default:
+		c.Class = "ok"
+	}
+	c.Failing = c.Class == "error" || c.Class == "fail"
+}
+
+// headerLines renders request headers sorted by name
+func headerLines(headers map[string]string) []string {
+	var lines []string
+	for name, value := range headers {
+		lines = append(lines, name+": "+value)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// responseHeaderLines renders response headers sorted by name
+func responseHeaderLines(header http.Header) []string {
+	var lines []string
+	for name, values := range header {
+		for _, value := range values {
+			lines = append(lines, name+": "+value)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// fileHash returns the hex SHA-256 of a file
+func fileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// writeHTML renders the report
+func (r htmlReport) writeHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
+
+// runReportCommand runs the test matrix, and the link tests when -test is
+// given, and writes an HTML report
+func runReportCommand(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor report [flags]\n\n")
+		fs.PrintDefaults()
+	}
+	var output = fs.String("o", defaultHTMLReport, "HTML file to write")
+	var testFile = fs.String("test", "", "Also run the link tests from this links.testing file")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	var results []LinkTestResult
+	if *testFile != "" {
+		tests, err := parseLinkTestFile(*testFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error reading test file: %v\n", err)
+			return exitError
+		}
+		results = runLinkTests(tests)
+	}
+	ts := runMatrix(monitorConfig)
+
+	if err := writeHTMLFile(*output, newHTMLReport(&ts, *testFile, results)); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error writing report: %v\n", err)
+		return exitError
+	}
+	fmt.Printf("📄 Report written to %s\n", *output)
+	return exitPass
+}
+
+// writeHTMLFile writes a report to a file
+func writeHTMLFile(path string, report htmlReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.writeHTML(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// htmlTemplate is the report page; styles and scripts are inlined so the
+// file can be shared on its own
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"shortHash": func(hash string) string {
+		if len(hash) > 12 && !strings.Contains(hash, " ") {
+			return hash[:12]
+		}
+		return hash
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>.htaccess Geo-Redirection Report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2rem; color: #222; }
+h1 { font-size: 1.5rem; margin-bottom: .25rem; }
+h2 { font-size: 1.15rem; margin-top: 2rem; }
+.meta { color: #555; font-size: .9rem; }
+.meta code { background: #f2f2f2; padding: 0 .25rem; }
+.summary { display: flex; gap: 1rem; flex-wrap: wrap; margin: 1rem 0; }
+.summary div { border: 1px solid #ddd; border-radius: 6px; padding: .5rem 1rem; }
+.summary strong { display: block; font-size: 1.4rem; }
+table { border-collapse: collapse; margin-top: .5rem; }
+th, td { border: 1px solid #ddd; padding: .3rem .5rem; vertical-align: top; font-size: .85rem; text-align: left; }
+th { background: #f6f6f6; }
+td.ok { background: #e6f4ea; }
+td.redirect { background: #fff4d6; }
+td.client-error { background: #fde2e1; }
+td.error { background: #f4c7c3; }
+td.pass { background: #c8e6c9; }
+td.fail { background: #ef9a9a; }
+details summary { cursor: pointer; }
+details pre { margin: .25rem 0; font-size: .75rem; white-space: pre-wrap; }
+.reason { color: #b00020; }
+label.filter { display: inline-block; margin: .5rem 0; }
+body.failures-only .passing { display: none; }
+body.failures-only td.passing { display: table-cell; visibility: hidden; }
+</style>
+</head>
+<body>
+<h1>.htaccess Geo-Redirection Report</h1>
+<p class="meta">Generated {{.Generated}} against {{.Backend}}{{if .BaseURL}} ({{.BaseURL}}){{end}}<br>
+<code>{{.Htaccess}}</code> SHA-256 <code title="{{.HtaccessHash}}">{{shortHash .HtaccessHash}}</code>{{if .TestFile}}<br>Link tests from <code>{{.TestFile}}</code>{{end}}</p>
+
+<div class="summary">
+{{- with .Summary}}
+{{- if .Cells}}<div><strong>{{.Cells}}</strong>cells</div>
+<div><strong>{{.Checked}}</strong>with expectations</div>
+<div><strong>{{.CellsFailed}}</strong>failing cells</div>{{end}}
+{{- if .Links}}<div><strong>{{.Links}}</strong>link tests</div>
+<div><strong>{{.LinksFailed}}</strong>failing link tests</div>{{end}}
+<div><strong>{{.Errors}}</strong>request errors</div>
+{{- end}}
+</div>
+
+<label class="filter"><input type="checkbox" id="failures-only"> Show failures only</label>
+
+{{define "cell"}}<td class="{{.Class}}{{if not .Failing}} passing{{end}}">
+<details><summary>{{if .Check}}{{.Check}} {{end}}{{if .Hops}}{{.Chain}}{{else}}{{.Status}} {{.Result}}{{end}}</summary>
+<pre>{{.Method}} {{.URL}}{{if .Line}} (line {{.Line}}){{end}}
+{{range .Request}}{{.}}
+{{end}}</pre>
+{{- if .Expected}}<pre>Expected: {{.Expected}}</pre>{{end}}
+{{- if .Reason}}<pre class="reason">{{.Reason}}</pre>{{end}}
+{{- if .Hops}}<pre>{{range .Hops}}{{.Status}} {{.URL}}{{if .Location}} → {{.Location}}{{end}}
+{{end}}</pre>{{end}}
+{{- if .Response}}<pre>{{range .Response}}{{.}}
+{{end}}</pre>{{end}}
+{{- if .Duration}}<pre>Duration: {{.Duration}}</pre>{{end}}
+</details></td>{{end}}
+
+{{- if .Rows}}
+<h2>Redirect map</h2>
+<table>
+<tr><th rowspan="2">Country</th>{{range .Pages}}<th colspan="{{.Span}}">{{.Name}}</th>{{end}}</tr>
+<tr>{{range .Agents}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr{{if not .Failing}} class="passing"{{end}}><th>{{.Country.Flag}} {{.Country.Name}} ({{.Country.Code}})</th>{{range .Cells}}{{template "cell" .}}{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+
+{{- if .Special}}
+<h2>Special cases</h2>
+<table>
+{{- range .Special}}
+<tr{{if not .Failing}} class="passing"{{end}}><th>{{.Label}}</th>{{template "cell" .}}</tr>
+{{- end}}
+</table>
+{{- end}}
+
+{{- if .LinkTests}}
+<h2>Link tests</h2>
+<table>
+{{- range .LinkTests}}
+<tr{{if not .Failing}} class="passing"{{end}}><th>{{.Label}}</th><td>{{.Method}} {{.URL}}</td>{{template "cell" .}}</tr>
+{{- end}}
+</table>
+{{- end}}
+
+<script>
+document.getElementById("failures-only").addEventListener("change", function (e) {
+  document.body.classList.toggle("failures-only", e.target.checked);
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// htmlFixture returns a matrix of one page, two agents and two countries,
+// one special case and one failing link test
+func htmlFixture() (TestSuite, []LinkTestResult) {
+	us := Country{Code: "us", Name: "United States"}
+	de := Country{Code: "de", Name: "Germany"}
+	expect := &LinkTest{ExpectedStatus: 302, ExpectedResult: "/de/"}
+	ts := TestSuite{Groups: []ResultGroup{
+		{Section: "Home Page: http://example.com/", Title: "👤 Regular Users", Results: []TestResult{
+			{Country: us, Status: 200, Result: "No redirect", URL: "http://example.com/", Headers: map[string]string{"X-Test-Country": "US"}},
+			{Country: de, Status: 302, Result: "/de/", URL: "http://example.com/", Expect: expect, Pass: true,
+				Chain: []RedirectHop{{URL: "http://example.com/", Status: 302, Location: "http://example.com/de/"}, {URL: "http://example.com/de/", Status: 200}}},
+		}},
+		{Section: "Home Page: http://example.com/", Title: "🤖 Google Bot", Results: []TestResult{
+			{Country: us, Status: 0, Result: "Connection failed", URL: "http://example.com/"},
+			{Country: de, Status: 302, Result: "/uk/", URL: "http://example.com/", Expect: expect, Reason: "contains: Location \"/uk/\" does not contain \"/de/\""},
+		}},
+		{Section: "SPECIAL CASES", Title: "Special Cases", Special: true, Results: []TestResult{
+			{Country: Country{Name: "Robots.txt"}, Status: 200, Result: "No redirect", URL: "http://example.com/robots.txt"},
+		}},
+	}}
+	results := []LinkTestResult{{
+		Test:     LinkTest{Line: 2, Agent: "Browser", Country: "FR", URL: "http://example.com/<fr>", ExpectedStatus: 200, ExpectedResult: "No redirect"},
+		Status:   404,
+		Result:   "No redirect",
+		Reason:   "status: expected 200, got 404",
+		Response: http.Header{"Content-Type": {"text/html"}},
+		Duration: 3 * time.Millisecond,
+	}}
+	return ts, results
+}
+
+// TestNewHTMLReport tests the redirect map layout and the summary
+func TestNewHTMLReport(t *testing.T) {
+	ts, results := htmlFixture()
+	report := newHTMLReport(&ts, "links.testing", results)
+
+	if len(report.Pages) != 1 || report.Pages[0].Span != 2 || len(report.Agents) != 2 {
+		t.Errorf("columns = %+v %v, want one page spanning two agents", report.Pages, report.Agents)
+	}
+	if len(report.Rows) != 2 || len(report.Rows[0].Cells) != 2 || report.Rows[1].Country.Code != "de" {
+		t.Fatalf("rows = %+v, want one row per country with a cell per agent", report.Rows)
+	}
+
+	classes := []string{report.Rows[0].Cells[0].Class, report.Rows[0].Cells[1].Class, report.Rows[1].Cells[0].Class, report.Rows[1].Cells[1].Class}
+	if got := strings.Join(classes, ","); got != "ok,error,pass,fail" {
+		t.Errorf("cell classes = %s, want ok,error,pass,fail", got)
+	}
+	if !report.Rows[0].Failing || !report.Rows[1].Failing {
+		t.Errorf("rows with an error or failing cell are not marked failing")
+	}
+
+	want := htmlSummary{Cells: 5, Checked: 2, CellsFailed: 1, Links: 1, LinksFailed: 1, Errors: 1}
+	if report.Summary != want {
+		t.Errorf("summary = %+v, want %+v", report.Summary, want)
+	}
+	if len(report.Special) != 1 || report.LinkTests[0].Class != "fail" || report.LinkTests[0].Label != "Browser 🇫🇷 FR" {
+		t.Errorf("special = %+v, link tests = %+v", report.Special, report.LinkTests)
+	}
+}
+
+// TestWriteHTML tests that the report is a single escaped page carrying the
+// .htaccess hash
+func TestWriteHTML(t *testing.T) {
+	oldPath := htaccessPath
+	htaccessPath = writeTestFile(t, ".htaccess", "RewriteEngine On\n")
+	defer func() { htaccessPath = oldPath }()
+
+	ts, results := htmlFixture()
+	var out bytes.Buffer
+	if err := newHTMLReport(&ts, "links.testing", results).writeHTML(&out); err != nil {
+		t.Fatalf("writeHTML() error = %v", err)
+	}
+	page := out.String()
+
+	hash, err := fileHash(htaccessPath)
+	if err != nil {
+		t.Fatalf("fileHash() error = %v", err)
+	}
+	for _, want := range []string{
+		hash,
+		"<h2>Redirect map</h2>",
+		`<th colspan="2">Home Page: http://example.com/</th>`,
+		`<td class="fail">`,
+		"302 → 200 http://example.com/de/",
+		"http://example.com/&lt;fr&gt;",
+		"Content-Type: text/html",
+		"Duration: 3ms",
+		`id="failures-only"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	for _, external := range []string{"<link ", "<script src", "<img "} {
+		if strings.Contains(page, external) {
+			t.Errorf("report is not self-contained: found %q", external)
+		}
+	}
+}
+
+// TestReportHTMLFormat tests -report html with link tests only
+func TestReportHTMLFormat(t *testing.T) {
+	_, results := htmlFixture()
+	tests := []LinkTest{results[0].Test}
+	path := filepath.Join(t.TempDir(), "report.html")
+
+	reports = reportTargets{{reportHTML, path}}
+	defer func() { reports = nil }()
+	if err := writeReports("links.testing", tests, results, time.Now()); err != nil {
+		t.Fatalf("writeReports() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := newReport("links.testing", tests, results, time.Now()).write(&out, reportHTML); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "<h2>Link tests</h2>") || strings.Contains(out.String(), "Redirect map") {
+		t.Errorf("link test report should only contain link tests")
+	}
+}
@@ -319,8 +319,8 @@ Browser,US,http://example.com`,
 func TestIntegrationCountryCodeNormalization(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		country := r.Header.Get("X-Test-Country")
-		// Verify country code is uppercase
-		if country != "US" && country != "UK" && country != "DE" {
+		// Verify country code is uppercase and aliases are sent as ISO codes
+		if country != "US" && country != "GB" && country != "DE" {
 			t.Errorf("Country code not uppercase: %s", country)
 		}
 		w.WriteHeader(200)
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Lint severities
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// LintDiagnostic represents a single finding of the .htaccess linter
+type LintDiagnostic struct {
+	Path     string
+	Line     int
+	Severity string
+	Check    string
+	Message  string
+}
+
+// String formats the diagnostic as path:line: severity: message [check]
+func (d LintDiagnostic) String() string {
+	location := d.Path
+	if d.Line > 0 {
+		location = fmt.Sprintf("%s:%d", d.Path, d.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", location, d.Severity, d.Message, d.Check)
+}
+
+// alternationRe finds parenthesised alternations such as (au|at|ca)
+var alternationRe = regexp.MustCompile(`\(([^()]*\|[^()]*)\)`)
+
+// countryCodeRe matches a bare two-letter code inside an alternation
+var countryCodeRe = regexp.MustCompile(`^[A-Za-z]{2}$`)
+
+// countryList is a country alternation found in a pattern
+type countryList struct {
+	line   int
+	codes  []string // normalised, sorted ISO codes
+	others []string // non-country alternatives such as wp-json
+}
+
+// lintRuleSet runs every lint check against a parsed .htaccess file
+func lintRuleSet(rs *RuleSet) []LintDiagnostic {
+	var diags []LintDiagnostic
+	diags = append(diags, lintSyntax(rs)...)
+	diags = append(diags, lintCountryLists(rs)...)
+	diags = append(diags, lintUnmappedCountries(rs)...)
+	diags = append(diags, lintShadowedRules(rs)...)
+	diags = append(diags, lintRedirectLoops(rs)...)
+	diags = append(diags, lintMissingNoCase(rs)...)
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Line < diags[j].Line
+	})
+	return diags
+}
+
+// lintSyntax reports parse problems and patterns that do not compile
+func lintSyntax(rs *RuleSet) []LintDiagnostic {
+	var diags []LintDiagnostic
+	for _, p := range rs.Problems {
+		diags = append(diags, LintDiagnostic{rs.Path, p.Line, severityError, "syntax", p.Message})
+	}
+	for _, rule := range rs.Rules {
+		for _, cond := range rule.Conds {
+			if cond.Err != nil {
+				diags = append(diags, LintDiagnostic{rs.Path, cond.Line, severityError, "regex",
+					fmt.Sprintf("invalid RewriteCond pattern %q: %v", cond.Pattern, cond.Err)})
+			}
+		}
+		if rule.Err != nil {
+			diags = append(diags, LintDiagnostic{rs.Path, rule.Line, severityError, "regex",
+				fmt.Sprintf("invalid RewriteRule pattern %q: %v", rule.Pattern, rule.Err)})
+		}
+	}
+	return diags
+}
+
+// extractCountryLists finds country alternations in a pattern
+func extractCountryLists(line int, pattern string) []countryList {
+	var lists []countryList
+	for _, m := range alternationRe.FindAllStringSubmatch(pattern, -1) {
+		list := countryList{line: line}
+		for _, alt := range strings.Split(m[1], "|") {
+			if countryCodeRe.MatchString(alt) {
+				list.codes = append(list.codes, strings.ToLower(isoCountryCode(alt)))
+			} else {
+				list.others = append(list.others, alt)
+			}
+		}
+		if len(list.codes) >= 3 {
+			sort.Strings(list.codes)
+			lists = append(lists, list)
+		}
+	}
+	return lists
+}
+
+// lintCountryLists reports country alternations that drift from the list
+// used most often in the file
+func lintCountryLists(rs *RuleSet) []LintDiagnostic {
+	var lists []countryList
+	for _, rule := range rs.Rules {
+		for _, cond := range rule.Conds {
+			lists = append(lists, extractCountryLists(cond.Line, cond.Pattern)...)
+		}
+		lists = append(lists, extractCountryLists(rule.Line, rule.Pattern)...)
+	}
+	if len(lists) == 0 {
+		return nil
+	}
+
+	counts := map[string]int{}
+	lines := map[string][]string{}
+	canonical := ""
+	for _, list := range lists {
+		key := strings.Join(list.codes, "|")
+		counts[key]++
+		lines[key] = append(lines[key], fmt.Sprint(list.line))
+		if canonical == "" || counts[key] > counts[canonical] {
+			canonical = key
+		}
+	}
+
+	var diags []LintDiagnostic
+	canonicalCodes := strings.Split(canonical, "|")
+	for _, list := range lists {
+		key := strings.Join(list.codes, "|")
+		if key == canonical {
+			if len(list.others) > 0 {
+				diags = append(diags, LintDiagnostic{rs.Path, list.line, severityInfo, "country-list",
+					fmt.Sprintf("country list also contains non-country entries: %s", strings.Join(list.others, ", "))})
+			}
+			continue
+		}
+		missing := setDifference(canonicalCodes, list.codes)
+		extra := setDifference(list.codes, canonicalCodes)
+		message := fmt.Sprintf("country list differs from the one on line(s) %s", strings.Join(lines[canonical], ", "))
+		if len(missing) > 0 {
+			message += "; missing " + strings.ToUpper(strings.Join(missing, ", "))
+		}
+		if len(extra) > 0 {
+			message += "; extra " + strings.ToUpper(strings.Join(extra, ", "))
+		}
+		if len(list.others) > 0 {
+			message += "; also contains " + strings.Join(list.others, ", ")
+		}
+		diags = append(diags, LintDiagnostic{rs.Path, list.line, severityWarning, "country-list", message})
+	}
+	return diags
+}
+
+// setDifference returns the items of a that are not in b
+func setDifference(a, b []string) []string {
+	seen := map[string]bool{}
+	for _, item := range b {
+		seen[item] = true
+	}
+	var diff []string
+	for _, item := range a {
+		if !seen[item] {
+			diff = append(diff, item)
+		}
+	}
+	return diff
+}
+
+// lintUnmappedCountries reports monitored countries for which no rule block
+// tests GEOIP_COUNTRY_CODE successfully
+func lintUnmappedCountries(rs *RuleSet) []LintDiagnostic {
+	var diags []LintDiagnostic
+	for _, country := range countries {
+		code := isoCountryCode(country.Code)
+		ctx := &rewriteContext{
+			rs:  rs,
+			req: &RewriteRequest{Header: http.Header{}},
+			env: map[string]string{geoCountryEnv: code},
+		}
+
+		handled := false
+		for _, rule := range rs.Rules {
+			var geoConds []RewriteCond
+			for _, cond := range rule.Conds {
+				if strings.Contains(cond.TestString, geoCountryEnv) && cond.Err == nil {
+					geoConds = append(geoConds, cond)
+				}
+			}
+			if rule.Disabled || len(geoConds) == 0 {
+				continue
+			}
+			if _, ok, _ := ctx.matchConds(geoConds, nil); ok {
+				handled = true
+				break
+			}
+		}
+		if !handled {
+			diags = append(diags, LintDiagnostic{rs.Path, 0, severityWarning, "unmapped-country",
+				fmt.Sprintf("country %s (%s) is monitored but no rule matches GEOIP_COUNTRY_CODE=%s", strings.ToUpper(country.Code), country.Name, code)})
+		}
+	}
+	return diags
+}
+
+// isCatchAll reports whether a rule pattern matches every path
+func isCatchAll(rule *RewriteRule) bool {
+	return rule.re != nil && !rule.Negate &&
+		rule.re.MatchString("") && rule.re.MatchString("any/path/page.html")
+}
+
+// stopsProcessing reports whether a matching rule ends the current pass
+func stopsProcessing(rule *RewriteRule) bool {
+	f := rule.Flags
+	return f.Last || f.End || f.Redirect != 0 || f.Forbidden || f.Gone
+}
+
+// condKey identifies a condition for shadowing comparisons
+func condKey(cond RewriteCond) string {
+	return fmt.Sprintf("%s %s %v %v", cond.TestString, cond.Pattern, cond.NoCase, cond.OrNext)
+}
+
+// lintShadowedRules reports rules that can never run because an earlier
+// terminating catch-all rule with a subset of their conditions stops first
+func lintShadowedRules(rs *RuleSet) []LintDiagnostic {
+	var diags []LintDiagnostic
+	for j := range rs.Rules {
+		later := &rs.Rules[j]
+		if later.Disabled {
+			continue
+		}
+		laterConds := map[string]bool{}
+		for _, cond := range later.Conds {
+			laterConds[condKey(cond)] = true
+		}
+
+		for i := 0; i < j; i++ {
+			earlier := &rs.Rules[i]
+			if earlier.Disabled || earlier.Flags.Chain || !stopsProcessing(earlier) || !isCatchAll(earlier) {
+				continue
+			}
+			subset := true
+			for _, cond := range earlier.Conds {
+				if !laterConds[condKey(cond)] {
+					subset = false
+					break
+				}
+			}
+			if subset {
+				diags = append(diags, LintDiagnostic{rs.Path, later.Line, severityWarning, "unreachable",
+					fmt.Sprintf("rule is unreachable: shadowed by %s on line %d", earlier.Flags.Raw, earlier.Line)})
+				break
+			}
+		}
+	}
+	return diags
+}
+
+// lintRedirectLoops follows redirects produced by the rules for every
+// monitored country and reports chains that revisit a URL or never end. A
+// redirect that only fires after an internal rewrite, such as the WordPress
+// fallback to /index.php, is a warning naming that rewrite
+func lintRedirectLoops(rs *RuleSet) []LintDiagnostic {
+	if !rs.Engine || rs.hasErrors() {
+		return nil
+	}
+	docRoot := offlineDocRoot
+	if docRoot == nil {
+		docRoot = dockerDocRoot
+	}
+
+	codes := []string{"", "ZZ"}
+	for _, country := range countries {
+		codes = append(codes, isoCountryCode(country.Code))
+	}
+
+	var diags []LintDiagnostic
+	reported := map[int]bool{}
+	report := func(line int, severity, check, message string) {
+		if !reported[line] {
+			reported[line] = true
+			diags = append(diags, LintDiagnostic{rs.Path, line, severity, check, message})
+		}
+	}
+	for _, code := range codes {
+		for _, start := range []string{"/", "/page", "/page/"} {
+			current := start
+			hops := []string{start}
+			seen := map[string]bool{start: true}
+			for hop := 0; ; hop++ {
+				result, trace := rs.EvaluateTrace(&RewriteRequest{
+					Method: "GET",
+					Host:   "localhost",
+					Path:   current,
+					Header: http.Header{},
+					Env:    map[string]string{geoCountryEnv: code},
+				}, docRoot)
+				if result.Location == "" || trace.Terminal == nil || trace.Terminal.Rule.Flags.Redirect == 0 {
+					break
+				}
+				line := trace.Terminal.Rule.Line
+				target, err := url.Parse(result.Location)
+				if err != nil {
+					break
+				}
+				hops = append(hops, target.Path)
+				if rewrite := internalRewrite(trace); rewrite != nil {
+					report(line, severityWarning, "redirect-after-rewrite",
+						fmt.Sprintf("redirect fires after line %d rewrites %s internally to %s (GEOIP_COUNTRY_CODE=%q): %s",
+							rewrite.Rule.Line, current, trace.Rounds[len(trace.Rounds)-1].URI, code, strings.Join(hops, " → ")))
+					break
+				}
+				if seen[target.Path] {
+					report(line, severityError, "redirect-loop",
+						fmt.Sprintf("redirect target was already visited (GEOIP_COUNTRY_CODE=%q): %s", code, strings.Join(hops, " → ")))
+					break
+				}
+				if hop == defaultFollowLimit {
+					report(line, severityError, "redirect-loop",
+						fmt.Sprintf("redirect chain does not end after %d hops (GEOIP_COUNTRY_CODE=%q): %s", defaultFollowLimit, code, strings.Join(hops, " → ")))
+					break
+				}
+				seen[target.Path] = true
+				current = target.Path
+			}
+		}
+	}
+	return diags
+}
+
+// internalRewrite returns the rule whose internal rewrite led to the round
+// that ended processing, or nil when it ended in the first round
+func internalRewrite(trace *RewriteTrace) *TraceRule {
+	if len(trace.Rounds) < 2 {
+		return nil
+	}
+	rules := trace.Rounds[len(trace.Rounds)-2].Rules
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].Applied && rules[i].Rule.Substitution != "-" {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// letterRe detects literal letters that are case-sensitive without [NC]
+var letterRe = regexp.MustCompile(`[A-Za-z]`)
+
+// lintMissingNoCase reports REQUEST_URI conditions without [NC]
+func lintMissingNoCase(rs *RuleSet) []LintDiagnostic {
+	var diags []LintDiagnostic
+	escapes := regexp.MustCompile(`\\.`)
+	for _, rule := range rs.Rules {
+		for _, cond := range rule.Conds {
+			if !strings.Contains(cond.TestString, "REQUEST_URI") || cond.re == nil || cond.NoCase {
+				continue
+			}
+			if letterRe.MatchString(escapes.ReplaceAllString(cond.Pattern, "")) {
+				diags = append(diags, LintDiagnostic{rs.Path, cond.Line, severityWarning, "missing-nc",
+					fmt.Sprintf("URI check %q is case-sensitive; add [NC]", cond.Pattern)})
+			}
+		}
+	}
+	return diags
+}
+
+// runLintCommand implements the lint subcommand and returns the exit code
+func runLintCommand(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor lint [flags] [.htaccess]\n\n")
+		fs.PrintDefaults()
+	}
+	var quiet = fs.Bool("quiet", false, "Hide info diagnostics")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	path := htaccessPath
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	rs, err := parseHtaccessFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading .htaccess: %v\n", err)
+		return 2
+	}
+
+	counts := map[string]int{}
+	for _, d := range lintRuleSet(rs) {
+		counts[d.Severity]++
+		if *quiet && d.Severity == severityInfo {
+			continue
+		}
+		fmt.Println(d)
+	}
+
+	fmt.Printf("\n%d error(s), %d warning(s), %d info\n", counts[severityError], counts[severityWarning], counts[severityInfo])
+	if counts[severityError]+counts[severityWarning] > 0 {
+		return 1
+	}
+	return 0
+}
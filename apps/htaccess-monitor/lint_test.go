@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lintChecks runs the linter on content and returns diagnostics by check name
+func lintChecks(t *testing.T, content string) map[string][]LintDiagnostic {
+	t.Helper()
+	rs := parseHtaccess(strings.NewReader(content))
+	rs.Path = ".htaccess"
+	checks := map[string][]LintDiagnostic{}
+	for _, d := range lintRuleSet(rs) {
+		checks[d.Check] = append(checks[d.Check], d)
+	}
+	return checks
+}
+
+// TestLintCountryLists tests drift detection between country alternations
+func TestLintCountryLists(t *testing.T) {
+	checks := lintChecks(t, `RewriteEngine On
+RewriteCond %{REQUEST_URI} !^/(de|at|uk)(/|$) [NC]
+RewriteRule ^(.*)$ /de/$1 [R=302,L]
+RewriteCond %{REQUEST_URI} !^/(de|at|uk)(/|$) [NC]
+RewriteRule ^(.*)$ /at/$1 [R=302,L]
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} !^(DE|AT|GB)$
+RewriteCond %{REQUEST_URI} !^/(de|fr|uk|wp-admin)(/|$) [NC]
+RewriteRule ^(.*)$ /uk/$1 [R=302,L]
+`)
+
+	diags := checks["country-list"]
+	if len(diags) != 1 {
+		t.Fatalf("country-list diagnostics = %v, want 1", diags)
+	}
+	if diags[0].Line != 7 || !strings.Contains(diags[0].Message, "missing AT") || !strings.Contains(diags[0].Message, "extra FR") {
+		t.Errorf("unexpected diagnostic: %s", diags[0])
+	}
+}
+
+// TestLintShadowedRules tests detection of unreachable rules
+func TestLintShadowedRules(t *testing.T) {
+	checks := lintChecks(t, `RewriteEngine On
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^US$
+RewriteRule ^ - [L]
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^US$
+RewriteCond %{REQUEST_URI} ^/shop [NC]
+RewriteRule ^(.*)$ /us/$1 [R=302,L]
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^DE$
+RewriteRule ^(.*)$ /de/$1 [R=302,L]
+`)
+
+	diags := checks["unreachable"]
+	if len(diags) != 1 || diags[0].Line != 6 || !strings.Contains(diags[0].Message, "line 3") {
+		t.Errorf("unreachable diagnostics = %v, want rule on line 6 shadowed by line 3", diags)
+	}
+}
+
+// TestLintRedirectLoops tests detection of redirect chains that revisit a
+// URL or never end, and of redirects that only follow an internal rewrite
+func TestLintRedirectLoops(t *testing.T) {
+	checks := lintChecks(t, `RewriteEngine On
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^DE$
+RewriteRule ^(.*)$ /de/$1 [R=302,L]
+RewriteRule ^page$ /other [R=302,L]
+RewriteRule ^other$ /page [R=302,L]
+`)
+
+	diags := checks["redirect-loop"]
+	if len(diags) != 2 || diags[0].Line != 3 || diags[0].Severity != severityError {
+		t.Fatalf("redirect-loop diagnostics = %v, want errors on lines 3 and 5", diags)
+	}
+	if !strings.Contains(diags[0].Message, "does not end after 10 hops") || !strings.Contains(diags[0].Message, "/ → /de/ → /de/de/") {
+		t.Errorf("endless chain message should show the hops: %s", diags[0])
+	}
+	if diags[1].Line != 5 || !strings.Contains(diags[1].Message, "already visited") || !strings.Contains(diags[1].Message, "/page → /other → /page") {
+		t.Errorf("loop message should show the hops: %s", diags[1])
+	}
+
+	// The WordPress fallback sends /de/page to /index.php, which the country
+	// rule redirects; no URL repeats, so this is no loop
+	checks = lintChecks(t, `RewriteEngine On
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^DE$
+RewriteCond %{REQUEST_URI} !^/de(/|$) [NC]
+RewriteRule ^(.*)$ /de/$1 [R=302,L]
+RewriteCond %{REQUEST_FILENAME} !-f
+RewriteCond %{REQUEST_FILENAME} !-d
+RewriteRule . /index.php [L]
+`)
+	if diags := checks["redirect-loop"]; len(diags) != 0 {
+		t.Errorf("redirect-loop diagnostics = %v, want none", diags)
+	}
+	diags = checks["redirect-after-rewrite"]
+	if len(diags) != 1 || diags[0].Line != 4 || diags[0].Severity != severityWarning ||
+		!strings.Contains(diags[0].Message, "line 7 rewrites /de/page internally to /index.php") {
+		t.Errorf("redirect-after-rewrite diagnostics = %v, want a warning on line 4 naming line 7", diags)
+	}
+}
+
+// TestLintMissingNoCaseAndRegex tests case sensitivity and regex checks
+func TestLintMissingNoCaseAndRegex(t *testing.T) {
+	checks := lintChecks(t, `RewriteEngine On
+RewriteCond %{REQUEST_URI} ^/wp-admin
+RewriteRule ^ - [L]
+RewriteCond %{REQUEST_URI} ^/\d+$
+RewriteRule ^ - [L]
+RewriteRule (?!x)y - [L]
+`)
+
+	if diags := checks["missing-nc"]; len(diags) != 1 || diags[0].Line != 2 {
+		t.Errorf("missing-nc diagnostics = %v, want line 2 only", diags)
+	}
+	if diags := checks["regex"]; len(diags) != 1 || diags[0].Line != 6 {
+		t.Errorf("regex diagnostics = %v, want line 6", diags)
+	}
+}
+
+// TestLintUnmappedCountries tests countries without a matching rule
+func TestLintUnmappedCountries(t *testing.T) {
+	checks := lintChecks(t, `RewriteEngine On
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^(US|GB)$
+RewriteRule ^ - [L]
+`)
+
+	unmapped := map[string]bool{}
+	for _, d := range checks["unmapped-country"] {
+		unmapped[strings.Fields(d.Message)[1]] = true
+	}
+	if unmapped["US"] || unmapped["UK"] {
+		t.Errorf("US and UK (GB) have rules but were reported: %v", unmapped)
+	}
+	if !unmapped["DE"] || !unmapped["JP"] {
+		t.Errorf("DE and JP should be reported as unmapped: %v", unmapped)
+	}
+}
+
+// TestRunLintCommand tests exit codes of the lint subcommand
+func TestRunLintCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	clean := filepath.Join(tmpDir, "clean.htaccess")
+	if err := os.WriteFile(clean, []byte("RewriteEngine On\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	broken := filepath.Join(tmpDir, "broken.htaccess")
+	if err := os.WriteFile(broken, []byte("RewriteEngine On\nRewriteRule ( - [L]\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	oldCountries := countries
+	defer func() { countries = oldCountries }()
+	countries = nil
+
+	if code := runLintCommand([]string{clean}); code != 0 {
+		t.Errorf("clean file exit code = %d, want 0", code)
+	}
+	if code := runLintCommand([]string{broken}); code != 1 {
+		t.Errorf("broken file exit code = %d, want 1", code)
+	}
+	if code := runLintCommand([]string{filepath.Join(tmpDir, "missing")}); code != 2 {
+		t.Errorf("missing file exit code = %d, want 2", code)
+	}
+}
@@ -1,15 +1,16 @@
 package main
 
 import (
-	"encoding/csv"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -17,7 +18,6 @@ import (
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/fsnotify/fsnotify"
 	"golang.org/x/term"
 )
 
@@ -29,9 +29,10 @@ var (
 
 // Country represents a test country with flag and name
 type Country struct {
-	Code string
-	Flag string
-	Name string
+	Code string `yaml:"code"`
+	Flag string `yaml:"flag"`
+	Name string `yaml:"name"`
+	IP   string `yaml:"ip"` // address sent with -client-ip, see countryIP
 }
 
 // TestResult represents the result of a single test
@@ -40,33 +41,66 @@ type TestResult struct {
 	Status     int
 	StatusText string
 	Result     string
+	URL        string
+	Headers    map[string]string
+	Response   http.Header // headers of the first response
+	Chain      []RedirectHop
+	ChainIssue string
+	Expect     *LinkTest // declared outcome, nil when none is configured
+	Pass       bool
+	Reason     string // why the declared outcome was not met
+	Pending    bool   // the request has not been answered yet
+	Changed    bool   // status or redirect target differs from the previous run
+	Duration   time.Duration
 }
 
-// LinkTest represents a test case from CSV
+// LinkTest represents a test case from a links.testing file
 type LinkTest struct {
+	Line           int // line of the test case in its file, 0 when not read from one
+	Name           string
 	Agent          string
 	Country        string
 	URL            string
+	Method         string            // GET when empty
+	Headers        map[string]string // extra request headers
+	Cookies        map[string]string
+	Query          map[string]string // appended to the URL's query string
 	ExpectedStatus int
 	ExpectedResult string
+	Match          string            // redirect target matcher, see match.go; "contains" when empty
+	PreserveQuery  bool              // the redirect must keep the request's query parameters
+	ExpectedFinal  string            // optional: substring of the URL the redirect chain ends at
+	ExpectedHops   int               // optional: number of responses in the chain, 0 = not checked
+	ExpectHeaders  map[string]string // response headers that must contain the value; "" only requires presence
+	BodyContains   []string
+	BodyRegex      string
 }
 
 // LinkTestResult represents the result of a link test
 type LinkTestResult struct {
-	Test    LinkTest
-	Status  int
-	Result  string
-	Success bool
+	Test       LinkTest
+	Status     int
+	Result     string
+	Success    bool
+	Reason     string      // why the test failed
+	Response   http.Header // headers of the first response
+	Chain      []RedirectHop
+	ChainIssue string
+	Duration   time.Duration // time taken by the request, including the chain
 }
 
-// TestSuite represents all test results
+// TestSuite represents all test results, one group per monitor table
 type TestSuite struct {
-	HomeRegular    []TestResult
-	HomeGoogleBot  []TestResult
-	ContentRegular []TestResult
-	ContentBot     []TestResult
-	SpecialCases   []TestResult
-	LastUpdate     time.Time
+	Groups     []ResultGroup
+	LastUpdate time.Time
+}
+
+// ResultGroup represents one table of the monitor grid
+type ResultGroup struct {
+	Section string // header of the page the table is shown under
+	Title   string
+	Special bool
+	Results []TestResult
 }
 
 // SpecialCase represents a special test case
@@ -76,22 +110,22 @@ type SpecialCase struct {
 	Result string
 }
 
-// Countries list with UTF-8 flags
+// Countries list with UTF-8 flags and an address geolocated to each country
 var countries = []Country{
-	{"us", "", "United States"},
-	{"uk", "", "United Kingdom"},
-	{"au", "", "Australia"},
-	{"at", "", "Austria"},
-	{"ca", "", "Canada"},
-	{"fr", "", "France"},
-	{"de", "", "Germany"},
-	{"ie", "", "Ireland"},
-	{"it", "", "Italy"},
-	{"ch", "", "Switzerland"},
-	{"es", "", "Spain"},
-	{"lu", "", "Luxembourg"},
-	{"li", "", "Liechtenstein"},
-	{"jp", "", "Japan"},
+	{Code: "us", Flag: "🇺🇸", Name: "United States", IP: "8.8.8.8"},
+	{Code: "uk", Flag: "🇬🇧", Name: "United Kingdom", IP: "81.2.69.142"},
+	{Code: "au", Flag: "🇦🇺", Name: "Australia", IP: "139.130.4.5"},
+	{Code: "at", Flag: "🇦🇹", Name: "Austria", IP: "194.232.104.3"},
+	{Code: "ca", Flag: "🇨🇦", Name: "Canada", IP: "24.48.0.1"},
+	{Code: "fr", Flag: "🇫🇷", Name: "France", IP: "193.51.24.1"},
+	{Code: "de", Flag: "🇩🇪", Name: "Germany", IP: "85.214.132.117"},
+	{Code: "ie", Flag: "🇮🇪", Name: "Ireland", IP: "87.32.0.1"},
+	{Code: "it", Flag: "🇮🇹", Name: "Italy", IP: "151.38.39.114"},
+	{Code: "ch", Flag: "🇨🇭", Name: "Switzerland", IP: "195.186.1.111"},
+	{Code: "es", Flag: "🇪🇸", Name: "Spain", IP: "80.58.61.250"},
+	{Code: "lu", Flag: "🇱🇺", Name: "Luxembourg", IP: "158.64.1.1"},
+	{Code: "li", Flag: "🇱🇮", Name: "Liechtenstein", IP: "82.117.0.1"},
+	{Code: "jp", Flag: "🇯🇵", Name: "Japan", IP: "202.12.27.33"},
 }
 
 // Model represents the application state
@@ -102,13 +136,55 @@ type model struct {
 	testing   bool
 	width     int
 	height    int
+	focus     int  // index of the table holding the selected cell
+	explain   bool // show the rule trace of the selected cell
+	detail    bool // show the request and response of the selected cell
+	run       int  // id of the latest matrix run; older results are dropped
+	done      int  // cells of the current run answered so far
+	total     int
+	cancel    context.CancelFunc // stops the current run
+	watcher   *fileWatcher       // nil when the files could not be watched
+	watchErr  error
+	changed   []string // files of the last change
+	changedAt time.Time
+	last      TestSuite    // the latest completed run, untouched by streaming
+	previous  TestSuite    // the completed run before it
+	changes   []cellChange // cells of last that differ from previous
+	showPrev  bool         // the tables show previous instead of the current run
+	recordErr error        // why the last run could not be recorded in the history
+
+	historyOpen   bool // show the history pane
+	history       []historyRun
+	historyErr    error
+	historyCursor int // selected run
+	historyMark   int // run marked for comparison, -1 when none
 }
 
 // Messages
-type testCompleteMsg TestSuite
-type fileChangedMsg string
 type testStartMsg struct{}
 
+// testPlanMsg starts a matrix run with every cell pending
+type testPlanMsg struct {
+	run     int
+	suite   TestSuite
+	updates <-chan tea.Msg
+	cancel  context.CancelFunc
+}
+
+// cellResultMsg streams the result of a single cell
+type cellResultMsg struct {
+	run          int
+	updates      <-chan tea.Msg
+	group, index int
+	result       TestResult
+}
+
+// testCompleteMsg ends a matrix run
+type testCompleteMsg struct {
+	run   int
+	suite TestSuite
+}
+
 // Styles
 var (
 	statusStyle = lipgloss.NewStyle().
@@ -116,6 +192,15 @@ var (
 		MarginTop(1)
 )
 
+// Expectation summary styles for the status line
+var (
+	failStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+	passStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+)
+
+// changeStyle highlights the file change indicator of the status line
+var changeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+
 func initialModel() model {
 	return model{
 		testSuite: TestSuite{},
@@ -129,44 +214,149 @@ func initialModel() model {
 
 func (m model) Init() tea.Cmd {
 	return tea.Batch(
-		watchFile(),
-		runTests(),
+		m.waitForChange(),
+		runTests(0),
 	)
 }
 
+// waitForChange waits for the next change of the watched files
+func (m model) waitForChange() tea.Cmd {
+	if m.watcher == nil {
+		return nil
+	}
+	return waitForChange(m.watcher)
+}
+
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 		if m.ready {
-			m.tables = createTables(m.testSuite, m.width)
+			m.setTables(createTables(m.shown(), m.width))
 		}
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.historyOpen {
+			switch msg.String() {
+			case "up", "down", "k", "j", "enter", " ":
+				m.historyKeyPress(msg.String())
+				return m, nil
+			}
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return m, tea.Quit
 		case "r":
-			m.testing = true
-			return m, runTests()
+			return m, m.startTests()
+		case "e":
+			m.explain = !m.explain
+			return m, nil
+		case "enter":
+			m.detail = !m.detail
+			return m, nil
+		case "esc":
+			m.detail, m.explain, m.historyOpen = false, false, false
+			return m, nil
+		case "h":
+			m.historyOpen = !m.historyOpen
+			if m.historyOpen {
+				m.openHistory()
+			}
+			return m, nil
+		case "p":
+			if m.ready && len(m.previous.Groups) > 0 {
+				m.showPrev = !m.showPrev
+				m.setTables(createTables(m.shown(), m.width))
+			}
+			return m, nil
+		case "n", "N":
+			step := 1
+			if msg.String() == "N" {
+				step = -1
+			}
+			m.jumpToFailure(step)
+			return m, nil
+		case "tab", "shift+tab":
+			if m.ready && len(m.tables) > 0 {
+				step := 1
+				if msg.String() == "shift+tab" {
+					step = len(m.tables) - 1
+				}
+				m.focusTable((m.focus + step) % len(m.tables))
+			}
+			return m, nil
+		case "up", "down", "k", "j":
+			if m.ready && len(m.tables) > 0 {
+				var cmd tea.Cmd
+				m.tables[m.focus], cmd = m.tables[m.focus].Update(msg)
+				return m, cmd
+			}
 		}
 
 	case testStartMsg:
+		return m, m.startTests()
+
+	case testPlanMsg:
+		if msg.run != m.run {
+			msg.cancel()
+			return m, nil
+		}
+		m.cancel = msg.cancel
 		m.testing = true
-		return m, runTests()
+		m.done, m.total = 0, msg.suite.cells()
+		if !m.ready || len(m.testSuite.Groups) != len(msg.suite.Groups) {
+			// First run or a new layout: show the pending cells
+			m.testSuite = msg.suite
+			m.setTables(createTables(m.shown(), m.width))
+			m.ready = true
+		}
+		return m, waitForUpdate(msg.updates)
+
+	case cellResultMsg:
+		if msg.run != m.run {
+			return m, nil
+		}
+		if before, ok := m.last.previousResult(msg.group, msg.index, msg.result); ok {
+			msg.result.Changed = cellChanged(before, msg.result)
+		}
+		m.testSuite.Groups[msg.group].Results[msg.index] = msg.result
+		m.done++
+		m.setTables(createTables(m.shown(), m.width))
+		return m, waitForUpdate(msg.updates)
 
 	case testCompleteMsg:
-		m.testSuite = TestSuite(msg)
-		m.tables = createTables(m.testSuite, m.width)
+		if msg.run != m.run {
+			return m, nil
+		}
+		if len(m.last.Groups) > 0 {
+			m.previous = m.last
+		}
+		var record tea.Cmd
+		if historyPath != "" {
+			record = recordHistoryCmd(monitorHistoryRun(msg.suite, monitorConfig))
+		}
+		m.last = msg.suite.clone()
+		m.changes = diffSuites(m.previous, m.last)
+		markChanges(m.previous, m.changes)
+		m.testSuite = msg.suite
+		markChanges(m.testSuite, m.changes)
+		m.setTables(createTables(m.shown(), m.width))
 		m.ready = true
 		m.testing = false
-		return m, watchFile() // Restart file watcher after tests complete
+		return m, record
+
+	case historyRecordedMsg:
+		m.recordErr = msg.err
+		if msg.err == nil && m.historyOpen {
+			m.history = append(m.history, msg.run)
+		}
+		return m, nil
 
 	case fileChangedMsg:
-		m.testing = true
-		return m, runTests()
+		m.changed, m.changedAt = msg.files, msg.at
+		return m, tea.Batch(m.startTests(), m.waitForChange())
 	}
 
 	return m, nil
@@ -192,65 +382,56 @@ func (m model) View() string {
 
 	// Status
 	status := ""
-	if m.testing {
-		status = statusStyle.Render(" Running tests...")
-	} else {
-		status = statusStyle.Render(fmt.Sprintf(" Last updated: %s", m.testSuite.LastUpdate.Format("15:04:05")))
+	switch {
+	case m.showPrev:
+		status = changeStyle.Render(fmt.Sprintf(" ⏮  Previous run of %s | Backend: %s", m.previous.LastUpdate.Format("15:04:05"), backendName()))
+		if failures := m.previous.Failures(); failures > 0 {
+			status += failStyle.Render(fmt.Sprintf(" | ❌ %d failing", failures))
+		}
+	case m.testing:
+		status = statusStyle.Render(fmt.Sprintf(" Running tests... %d/%d", m.done, m.total))
+	default:
+		status = statusStyle.Render(fmt.Sprintf(" Last updated: %s | Backend: %s", m.testSuite.LastUpdate.Format("15:04:05"), backendName()))
+		if failures := m.testSuite.Failures(); failures > 0 {
+			status += failStyle.Render(fmt.Sprintf(" | ❌ %d failing", failures))
+		} else if m.testSuite.checked() {
+			status += passStyle.Render(" | ✅ all expectations met")
+		}
+	}
+	switch {
+	case m.watchErr != nil:
+		status += failStyle.Render(" | ⚠️  not watching: " + m.watchErr.Error())
+	case len(m.changed) > 0:
+		status += changeStyle.Render(fmt.Sprintf(" | 📝 %s changed at %s", describeChange(m.changed), m.changedAt.Format("15:04:05")))
+	}
+	if m.recordErr != nil {
+		status += failStyle.Render(" | ⚠️  history: " + m.recordErr.Error())
 	}
 	sections = append(sections, status)
 
-	// Tables in 2x3 grid (2 rows, 3 columns)
-	if len(m.tables) >= 5 {
-		// Section header for HOMEPAGE
-		homepageHeader := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("15")).
-			Background(lipgloss.Color("33")).
-			Bold(true).
-			Padding(0, 2).
-			MarginBottom(0).
-			Render(" Home Page: http://localhost:8080/")
-
-		topRow := lipgloss.JoinHorizontal(lipgloss.Top,
-			m.tables[0].View(),
-			"  ",
-			m.tables[1].View(),
-		)
-
-		// Section header for TEST CONTENT PAGE
-		testContentHeader := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("15")).
-			Background(lipgloss.Color("99")).
-			Bold(true).
-			Padding(0, 2).
-			MarginBottom(0).
-			Render(" Test Content Page: http://localhost:8080/test-content  ")
-
-		middleRow := lipgloss.JoinHorizontal(lipgloss.Top,
-			m.tables[2].View(),
-			"  ",
-			m.tables[3].View(),
-		)
-
-		// Section header for SPECIAL CASES
-		specialCasesHeader := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("15")).
-			Background(lipgloss.Color("129")).
-			Bold(true).
-			Padding(0, 2).
-			MarginBottom(0).
-			Render(" SPECIAL CASES")
-
-		bottomRow := m.tables[4].View() // Special cases table
-
-		grid := lipgloss.JoinVertical(lipgloss.Left,
-			homepageHeader, topRow,
-			testContentHeader, middleRow,
-			specialCasesHeader, bottomRow)
-		sections = append(sections, grid)
+	// Tables grouped by page, one column per agent
+	if len(m.tables) == len(m.shown().Groups) {
+		sections = append(sections, m.gridView())
+	}
+
+	if len(m.changes) > 0 && !m.testing {
+		sections = append(sections, m.changesView())
+	}
+
+	if m.detail {
+		sections = append(sections, m.detailView())
+	}
+
+	if m.explain {
+		sections = append(sections, m.explainView())
+	}
+
+	if m.historyOpen {
+		sections = append(sections, m.historyView())
 	}
 
 	// Controls
-	controls := statusStyle.Render("⌨️ Press 'r' to run tests manually, 'q' to quit")
+	controls := statusStyle.Render("⌨️ Press 'r' to run tests manually, 'tab'/arrows to select, 'n'/'N' for next/previous failure, 'enter' for details, 'e' to explain, 'p' for the previous run, 'h' for history, 'q' to quit")
 	sections = append(sections, controls)
 	// Branding footer - full width gray background with right-aligned text
 	brandingText := "🏢 Tradik Limited / 2025 Commercial License"
@@ -281,33 +462,214 @@ func (m model) View() string {
 	return lipgloss.JoinVertical(lipgloss.Left, sections...)
 }
 
+// startTests starts a new matrix run; results of earlier runs still in
+// flight are dropped
+func (m *model) startTests() tea.Cmd {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.run++
+	m.testing = true
+	return runTests(m.run)
+}
+
+// shown returns the run the tables show: the current one or, after 'p',
+// the previous one
+func (m model) shown() TestSuite {
+	if m.showPrev {
+		return m.previous
+	}
+	return m.testSuite
+}
+
+// setTables replaces the tables while keeping the selected cell
+func (m *model) setTables(tables []table.Model) {
+	cursor := 0
+	if m.focus < len(m.tables) {
+		cursor = m.tables[m.focus].Cursor()
+	}
+	m.tables = tables
+	if m.focus >= len(m.tables) {
+		m.focus = 0
+	}
+	if len(m.tables) > 0 {
+		m.focusTable(m.focus)
+		m.tables[m.focus].SetCursor(cursor)
+	}
+}
+
+// focusTable moves the keyboard focus and the selection highlight to a table
+func (m *model) focusTable(index int) {
+	if m.focus < len(m.tables) {
+		m.tables[m.focus].Blur()
+		m.tables[m.focus].SetStyles(tableStyles(false))
+	}
+	m.focus = index
+	m.tables[m.focus].Focus()
+	m.tables[m.focus].SetStyles(tableStyles(true))
+}
+
+// jumpToFailure moves the selection to the next (step 1) or previous
+// (step -1) failing cell, wrapping around the grid
+func (m *model) jumpToFailure(step int) {
+	shown := m.shown()
+	if !m.ready || len(m.tables) != len(shown.Groups) {
+		return
+	}
+	type cell struct{ table, row int }
+	var cells []cell
+	current := -1
+	for i, group := range shown.Groups {
+		for j := range group.Results {
+			if i == m.focus && j == m.tables[i].Cursor() {
+				current = len(cells)
+			}
+			cells = append(cells, cell{i, j})
+		}
+	}
+
+	for n := 1; n <= len(cells); n++ {
+		index := ((current+step*n)%len(cells) + len(cells)) % len(cells)
+		target := cells[index]
+		if !shown.Groups[target.table].Results[target.row].failing() {
+			continue
+		}
+		m.focusTable(target.table)
+		m.tables[m.focus].SetCursor(target.row)
+		return
+	}
+}
+
+// sectionColors are the header backgrounds of consecutive page sections
+var sectionColors = []string{"33", "99"}
+
+// gridView renders the tables under their page headers, agents side by side
+func (m model) gridView() string {
+	shown := m.shown()
+	perRow := gridColumns(shown, m.width-10)
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("15")).
+		Bold(true).
+		Padding(0, 2).
+		MarginBottom(0)
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+
+	var rows []string
+	var row []string
+	flush := func() {
+		if len(row) > 0 {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+			row = nil
+		}
+	}
+
+	section, page := "", 0
+	for i, group := range shown.Groups {
+		if i == 0 || group.Section != section {
+			flush()
+			color := "129"
+			if !group.Special {
+				color = sectionColors[page%len(sectionColors)]
+				page++
+			}
+			rows = append(rows, headerStyle.Background(lipgloss.Color(color)).Render(" "+group.Section))
+			section = group.Section
+		}
+
+		view := m.tables[i].View()
+		if !group.Special {
+			view = lipgloss.JoinVertical(lipgloss.Left, titleStyle.Render(group.Title), view)
+		}
+		if len(row) > 0 {
+			row = append(row, "  ")
+		}
+		row = append(row, view)
+		if group.Special || (len(row)+1)/2 == perRow {
+			flush()
+		}
+	}
+	flush()
+
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+// gridColumns returns how many agent tables are shown side by side: one per
+// agent, fewer when more than two would not fit the terminal
+func gridColumns(ts TestSuite, availableWidth int) int {
+	perRow, count, section := 1, 0, ""
+	for _, group := range ts.Groups {
+		if group.Special {
+			continue
+		}
+		if group.Section != section {
+			count, section = 0, group.Section
+		}
+		count++
+		perRow = max(perRow, count)
+	}
+	for perRow > 2 && (availableWidth-2*perRow)/perRow < 60 {
+		perRow--
+	}
+	return perRow
+}
+
+// selectedResult returns the result under the cursor of the focused table
+func (m model) selectedResult() (TestResult, bool) {
+	groups := m.shown().Groups
+	if m.focus >= len(groups) || m.focus >= len(m.tables) {
+		return TestResult{}, false
+	}
+	cursor := m.tables[m.focus].Cursor()
+	if cursor < 0 || cursor >= len(groups[m.focus].Results) {
+		return TestResult{}, false
+	}
+	return groups[m.focus].Results[cursor], true
+}
+
+// explainView renders the rule trace pane for the selected cell
+func (m model) explainView() string {
+	paneStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("99")).
+		Padding(0, 1).
+		MarginTop(1)
+
+	result, ok := m.selectedResult()
+	if !ok {
+		return paneStyle.Render("🔎 No cell selected")
+	}
+
+	title := fmt.Sprintf("🔎 Rule trace: %s — %s", result.Country.Name, result.URL)
+	lines, err := explainURL(result.URL, result.Headers)
+	if err != nil {
+		return paneStyle.Render(title + "\n❌ " + err.Error())
+	}
+	return paneStyle.Render(title + "\n" + strings.Join(lines, "\n"))
+}
+
 func createTables(ts TestSuite, terminalWidth int) []table.Model {
-	tables := make([]table.Model, 5)
+	tables := make([]table.Model, len(ts.Groups))
 
 	// Calculate table width based on terminal size
 	// Leave space for padding and borders
-	availableWidth := terminalWidth - 10   // Account for margins and spacing
-	tableWidth := (availableWidth - 4) / 2 // Two tables side by side with spacing
+	availableWidth := terminalWidth - 10 // Account for margins and spacing
+	perRow := gridColumns(ts, availableWidth)
+	tableWidth := (availableWidth - 2*perRow) / perRow // Agent tables side by side with spacing
 
 	// Minimum width to ensure readability
 	if tableWidth < 60 {
 		tableWidth = 60
 	}
 
-	// Table 1: Home Page - Regular Users
-	tables[0] = createTable("🏠 Home Page - Regular Users\nURL: http://localhost:8080/", ts.HomeRegular, tableWidth)
-
-	// Table 2: Home Page - Google Bot
-	tables[1] = createTable("🤖 Home Page - Google Bot\nURL: http://localhost:8080/", ts.HomeGoogleBot, tableWidth)
-
-	// Table 3: Test Content - Regular Users
-	tables[2] = createTable("📄 Test Content - Regular Users\nURL: http://localhost:8080/test-content", ts.ContentRegular, tableWidth)
-
-	// Table 4: Test Content - Google Bot
-	tables[3] = createTable("🤖 Test Content - Google Bot\nURL: http://localhost:8080/test-content", ts.ContentBot, tableWidth)
-
-	// Table 5: Special Cases (full width)
-	tables[4] = createSpecialCasesTable(ts.SpecialCases, availableWidth)
+	for i, group := range ts.Groups {
+		if group.Special {
+			// Special cases table (full width)
+			tables[i] = createSpecialCasesTable(group.Results, availableWidth)
+			continue
+		}
+		tables[i] = createTable(group.Title, group.Results, tableWidth)
+	}
 
 	return tables
 }
@@ -323,20 +685,31 @@ func createTable(title string, results []TestResult, tableWidth int) table.Model
 		resultWidth = 30
 	}
 
+	checked := hasExpectations(results)
+	if checked {
+		resultWidth -= checkWidth
+	}
+
 	columns := []table.Column{
 		{Title: "Country", Width: countryWidth},
 		{Title: "Status", Width: statusWidth},
 		{Title: "Result", Width: resultWidth},
 	}
+	if checked {
+		columns = append(columns, table.Column{Title: "Check", Width: checkWidth})
+	}
 
 	rows := make([]table.Row, 0, len(results))
 	for _, result := range results {
-		statusIcon := getStatusIcon(result.Status)
-		rows = append(rows, table.Row{
-			fmt.Sprintf("%s %s", result.Country.Flag, result.Country.Name),
-			fmt.Sprintf("%s %d", statusIcon, result.Status),
-			result.Result,
-		})
+		row := table.Row{
+			changeText(result) + fmt.Sprintf("%s %s", result.Country.Flag, result.Country.Name),
+			statusText(result),
+			resultText(result),
+		}
+		if checked {
+			row = append(row, checkText(result))
+		}
+		rows = append(rows, row)
 	}
 
 	t := table.New(
@@ -345,17 +718,7 @@ func createTable(title string, results []TestResult, tableWidth int) table.Model
 		table.WithHeight(len(results)+2),
 	)
 
-	s := table.DefaultStyles()
-	s.Header = s.Header.
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("240")).
-		BorderBottom(true).
-		Bold(false)
-	s.Selected = s.Selected.
-		Foreground(lipgloss.Color("229")).
-		Background(lipgloss.Color("57")).
-		Bold(false)
-	t.SetStyles(s)
+	t.SetStyles(tableStyles(false))
 
 	return t
 }
@@ -371,20 +734,31 @@ func createSpecialCasesTable(results []TestResult, tableWidth int) table.Model {
 		resultWidth = 35
 	}
 
+	checked := hasExpectations(results)
+	if checked {
+		resultWidth -= checkWidth
+	}
+
 	columns := []table.Column{
 		{Title: "Test Case", Width: testCaseWidth},
 		{Title: "Status", Width: statusWidth},
 		{Title: "Result", Width: resultWidth},
 	}
+	if checked {
+		columns = append(columns, table.Column{Title: "Check", Width: checkWidth})
+	}
 
 	rows := make([]table.Row, 0, len(results))
 	for _, result := range results {
-		statusIcon := getStatusIcon(result.Status)
-		rows = append(rows, table.Row{
-			result.Country.Name, // Using Name field for test case name
-			fmt.Sprintf("%s %d", statusIcon, result.Status),
-			result.Result,
-		})
+		row := table.Row{
+			changeText(result) + result.Country.Name, // Using Name field for test case name
+			statusText(result),
+			resultText(result),
+		}
+		if checked {
+			row = append(row, checkText(result))
+		}
+		rows = append(rows, row)
 	}
 
 	t := table.New(
@@ -393,19 +767,127 @@ func createSpecialCasesTable(results []TestResult, tableWidth int) table.Model {
 		table.WithHeight(len(results)+2),
 	)
 
+	t.SetStyles(tableStyles(false))
+
+	return t
+}
+
+// tableStyles returns the styles of a monitor table; only the focused one
+// highlights its selected row
+func tableStyles(focused bool) table.Styles {
 	s := table.DefaultStyles()
 	s.Header = s.Header.
 		BorderStyle(lipgloss.NormalBorder()).
 		BorderForeground(lipgloss.Color("240")).
 		BorderBottom(true).
 		Bold(false)
-	s.Selected = s.Selected.
-		Foreground(lipgloss.Color("229")).
-		Background(lipgloss.Color("57")).
-		Bold(false)
-	t.SetStyles(s)
+	s.Selected = lipgloss.NewStyle()
+	if focused {
+		s.Selected = s.Selected.
+			Foreground(lipgloss.Color("229")).
+			Background(lipgloss.Color("57"))
+	}
+	return s
+}
 
-	return t
+// checkWidth is the width of the PASS/FAIL column
+const checkWidth = 9
+
+// hasExpectations reports whether any result has a declared outcome
+func hasExpectations(results []TestResult) bool {
+	for _, result := range results {
+		if result.Expect != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// statusText renders the status cell of a result
+func statusText(result TestResult) string {
+	if result.Pending {
+		return "⏳"
+	}
+	return fmt.Sprintf("%s %d", getStatusIcon(result.Status), result.Status)
+}
+
+// resultText renders the result cell of a result
+func resultText(result TestResult) string {
+	if result.Pending {
+		return "…"
+	}
+	return describeChain(result.Result, result.Chain, result.ChainIssue)
+}
+
+// changeText prefixes the first cell of a row that changed since the
+// previous run
+func changeText(result TestResult) string {
+	if result.Changed {
+		return changeMarker + " "
+	}
+	return ""
+}
+
+// checkText renders the PASS/FAIL cell of a result
+func checkText(result TestResult) string {
+	switch {
+	case result.Pending:
+		return "…"
+	case result.Expect == nil:
+		return "—"
+	case result.Pass:
+		return "✅ PASS"
+	default:
+		return "❌ FAIL"
+	}
+}
+
+// failing reports whether a result contradicts its declared outcome
+func (r TestResult) failing() bool {
+	return r.Expect != nil && !r.Pass && !r.Pending
+}
+
+// checked reports whether any cell has a declared outcome
+func (ts TestSuite) checked() bool {
+	for _, group := range ts.Groups {
+		if hasExpectations(group.Results) {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns a copy of the suite that shares no result slices with it
+func (ts TestSuite) clone() TestSuite {
+	groups := make([]ResultGroup, len(ts.Groups))
+	for i, group := range ts.Groups {
+		groups[i] = group
+		groups[i].Results = append([]TestResult(nil), group.Results...)
+	}
+	ts.Groups = groups
+	return ts
+}
+
+// cells returns the number of cells in the suite
+func (ts TestSuite) cells() int {
+	n := 0
+	for _, group := range ts.Groups {
+		n += len(group.Results)
+	}
+	return n
+}
+
+// Failures returns the number of cells that contradict their declared outcome
+func (ts TestSuite) Failures() int {
+	failures := 0
+	for _, group := range ts.Groups {
+		for _, result := range group.Results {
+			if result.failing() {
+				failures++
+			}
+		}
+	}
+	return failures
 }
 
 func getStatusIcon(status int) string {
@@ -419,83 +901,174 @@ func getStatusIcon(status int) string {
 	}
 }
 
-func runTests() tea.Cmd {
+// runTests starts a matrix run whose results stream into the model
+func runTests(run int) tea.Cmd {
+	cfg := monitorConfig
+	return func() tea.Msg {
+		ts, cells := planMatrix(cfg)
+		plan := testPlanMsg{run: run, suite: ts.clone()}
+		updates := make(chan tea.Msg, len(cells)+1)
+		ctx, cancel := runContext()
+		go func() {
+			defer cancel()
+			updates <- testCompleteMsg{run: run, suite: runMatrixCells(ctx, ts, cells, func(cell matrixCell, result TestResult) {
+				updates <- cellResultMsg{run: run, updates: updates, group: cell.group, index: cell.index, result: result}
+			})}
+		}()
+		plan.updates, plan.cancel = updates, cancel
+		return plan
+	}
+}
+
+// waitForUpdate delivers the next streamed message of a matrix run
+func waitForUpdate(updates <-chan tea.Msg) tea.Cmd {
 	return func() tea.Msg {
-		baseURL := "http://localhost:8080"
-		googleBotUA := "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
-
-		ts := TestSuite{
-			LastUpdate: time.Now(),
-		}
-
-		// Test home page - regular users
-		for _, country := range countries {
-			result := testURL(baseURL+"/", country.Code, "")
-			ts.HomeRegular = append(ts.HomeRegular, TestResult{
-				Country:    country,
-				Status:     result.Status,
-				StatusText: result.StatusText,
-				Result:     result.Result,
-			})
-		}
-
-		// Test home page - Google Bot
-		for _, country := range countries {
-			result := testURL(baseURL+"/", country.Code, googleBotUA)
-			ts.HomeGoogleBot = append(ts.HomeGoogleBot, TestResult{
-				Country:    country,
-				Status:     result.Status,
-				StatusText: result.StatusText,
-				Result:     result.Result,
-			})
-		}
-
-		// Test content - regular users
-		for _, country := range countries {
-			result := testURL(baseURL+"/test-content", country.Code, "")
-			ts.ContentRegular = append(ts.ContentRegular, TestResult{
-				Country:    country,
-				Status:     result.Status,
-				StatusText: result.StatusText,
-				Result:     result.Result,
-			})
-		}
-
-		// Test content - Google Bot
-		for _, country := range countries {
-			result := testURL(baseURL+"/test-content", country.Code, googleBotUA)
-			ts.ContentBot = append(ts.ContentBot, TestResult{
-				Country:    country,
-				Status:     result.Status,
-				StatusText: result.StatusText,
-				Result:     result.Result,
-			})
-		}
-
-		// Test special cases
-		specialCases := []struct {
-			name    string
-			url     string
-			headers map[string]string
-		}{
-			{"No Country Set", baseURL + "/", map[string]string{}},
-			{"Empty Country Code", baseURL + "/", map[string]string{"X-Test-Country": ""}},
-			{"WordPress Admin", baseURL + "/wp-admin/", map[string]string{"X-Test-Country": "DE"}},
-			{"Robots.txt", baseURL + "/robots.txt", map[string]string{"X-Test-Country": "DE"}},
-			{"Sitemap", baseURL + "/sitemap_index.xml", map[string]string{"X-Test-Country": "DE"}},
-		}
-
-		for _, testCase := range specialCases {
-			result := testSpecialURL(testCase.url, testCase.headers)
-			ts.SpecialCases = append(ts.SpecialCases, TestResult{
-				Country:    Country{Code: "", Flag: "", Name: testCase.name},
-				Status:     result.Status,
-				StatusText: result.StatusText,
-				Result:     result.Result,
-			})
-		}
-
-		return testCompleteMsg(ts)
+		return <-updates
+	}
+}
+
+// matrixCell is a request of the test matrix and the table cell it fills
+type matrixCell struct {
+	group, index int
+	run          func(ctx context.Context) TestResult
+}
+
+// runMatrix requests every cell of the test matrix: each page by each agent
+// from each country, followed by the special cases
+func runMatrix(cfg *Config) TestSuite {
+	ctx, cancel := runContext()
+	defer cancel()
+	ts, cells := planMatrix(cfg)
+	return runMatrixCells(ctx, ts, cells, nil)
+}
+
+// runMatrixCells sends the cells of a planned matrix through the worker pool
+// and fills in their results, calling update as each one arrives
+func runMatrixCells(ctx context.Context, ts TestSuite, cells []matrixCell, update func(matrixCell, TestResult)) TestSuite {
+	runPool(ctx, len(cells), func(ctx context.Context, i int) TestResult {
+		return cells[i].run(ctx)
+	}, func(i int, result TestResult) bool {
+		cell := cells[i]
+		ts.Groups[cell.group].Results[cell.index] = result
+		if update != nil {
+			update(cell, result)
+		}
+		return true
+	})
+	ts.LastUpdate = time.Now()
+	return ts
+}
+
+// planMatrix lays out the test matrix with every cell pending and returns
+// the requests that fill it
+func planMatrix(cfg *Config) (TestSuite, []matrixCell) {
+	ts := TestSuite{
+		LastUpdate: time.Now(),
+	}
+	var cells []matrixCell
+
+	for _, page := range cfg.Pages {
+		url := cfg.pageURL(page.Path)
+		section := fmt.Sprintf("%s: %s", page.Name, url)
+		for _, agent := range cfg.Agents {
+			group := ResultGroup{
+				Section: section,
+				Title:   strings.TrimSpace(agent.Icon + " " + agent.Name),
+			}
+			for _, country := range cfg.Countries {
+				pending := TestResult{
+					Country: country,
+					URL:     url,
+					Headers: testHeaders(country.Code, agent.UserAgent),
+					Pending: true,
+				}
+				if outcome := cfg.expectation(page, agent, country); outcome != nil {
+					expect := outcome.linkTest(url, agent.Name, country.Code, page.Path)
+					pending.Expect = &expect
+				}
+				cells = append(cells, matrixCell{
+					group: len(ts.Groups),
+					index: len(group.Results),
+					run: func(ctx context.Context) TestResult {
+						start := time.Now()
+						return pending.complete(testRequest(ctx, "GET", url, pending.Headers, followLimit, false), start)
+					},
+				})
+				group.Results = append(group.Results, pending)
+			}
+			ts.Groups = append(ts.Groups, group)
+		}
+	}
+
+	if len(cfg.SpecialCases) == 0 {
+		return ts, cells
+	}
+	special := ResultGroup{Section: "SPECIAL CASES", Title: "Special Cases", Special: true}
+	for _, testCase := range cfg.SpecialCases {
+		url := cfg.pageURL(testCase.Path)
+		pending := TestResult{
+			Country: Country{Code: "", Flag: "", Name: testCase.Name},
+			URL:     url,
+			Headers: testCase.Headers,
+			Pending: true,
+		}
+		if testCase.Expect != nil {
+			expect := testCase.Expect.linkTest(url, testCase.Name, testCase.Headers["X-Test-Country"], testCase.Path)
+			pending.Expect = &expect
+		}
+		cells = append(cells, matrixCell{
+			group: len(ts.Groups),
+			index: len(special.Results),
+			run: func(ctx context.Context) TestResult {
+				start := time.Now()
+				return pending.complete(testSpecialURLContext(ctx, url, pending.Headers), start)
+			},
+		})
+		special.Results = append(special.Results, pending)
+	}
+	ts.Groups = append(ts.Groups, special)
+
+	return ts, cells
+}
+
+// complete fills a pending cell with its response and judges it against the
+// declared outcome
+func (r TestResult) complete(result HTTPResult, start time.Time) TestResult {
+	r.Pending = false
+	r.Duration = time.Since(start)
+	r.Status = result.Status
+	r.StatusText = result.StatusText
+	r.Result = result.Result
+	r.Response = result.Header
+	r.Chain = result.Chain
+	r.ChainIssue = result.ChainIssue
+	if r.Expect != nil {
+		r.Pass, r.Reason = checkLinkTest(*r.Expect, result)
+	}
+	return r
+}
+
+// backendTransport answers test requests; nil sends them over the network
+var backendTransport http.RoundTripper
+
+// htaccessPath is the .htaccess file watched and evaluated by the monitor
+var htaccessPath = "../../.htaccess"
+
+// newHTTPClient creates a client that stops at the first redirect and keeps
+// enough idle connections for the worker pool
+func newHTTPClient() *http.Client {
+	transport := backendTransport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.MaxIdleConnsPerHost = max(concurrency, 2)
+		transport = t
+	}
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Timeout:   5 * time.Second,
+		Transport: transport,
 	}
 }
 
@@ -503,29 +1076,71 @@ type HTTPResult struct {
 	Status     int
 	StatusText string
 	Result     string
+	Chain      []RedirectHop // every response when redirects are followed
+	ChainIssue string
+	Header     http.Header // headers of the first response
+	Body       string      // body of the first response, only read when asserted on
 }
 
-func testURL(url, countryCode, userAgent string) HTTPResult {
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Timeout: 5 * time.Second,
+// testHeaders returns the request headers testURL sends for a country and agent
+func testHeaders(countryCode, userAgent string) map[string]string {
+	headers := map[string]string{"X-Test-Country": isoCountryCode(countryCode)}
+	if ip := countryIP(countryCode); clientIPHeaders && ip != "" {
+		headers = map[string]string{"X-Forwarded-For": ip, "X-Real-IP": ip}
 	}
+	if userAgent != "" {
+		headers["User-Agent"] = userAgent
+	}
+	return headers
+}
+
+// newTestRequest creates a GET request with the given headers
+func newTestRequest(url string, headers map[string]string) (*http.Request, error) {
+	return newTestRequestMethod("GET", url, headers)
+}
+
+// newTestRequestMethod creates a request with the given method and headers
+func newTestRequestMethod(method, url string, headers map[string]string) (*http.Request, error) {
+	return newTestRequestContext(context.Background(), method, url, headers)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// newTestRequestContext is newTestRequestMethod bound to a context
+func newTestRequestContext(ctx context.Context, method, url string, headers map[string]string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return HTTPResult{Status: 0, StatusText: "Error", Result: "Request failed"}
+		return nil, err
 	}
-
-	req.Header.Set("X-Test-Country", strings.ToUpper(countryCode))
-	if userAgent != "" {
-		req.Header.Set("User-Agent", userAgent)
+	for key, value := range headers {
+		req.Header.Set(key, value)
 	}
+	return req, nil
+}
+
+// maxBodySize limits how much of a response body is kept for assertions
+const maxBodySize = 1 << 20
+
+func testURL(url, countryCode, userAgent string) HTTPResult {
+	return testURLFollow(url, countryCode, userAgent, followLimit)
+}
 
-	resp, err := client.Do(req)
+// testURLFollow is testURL with an explicit redirect follow limit
+func testURLFollow(url, countryCode, userAgent string, limit int) HTTPResult {
+	return testRequest(context.Background(), "GET", url, testHeaders(countryCode, userAgent), limit, false)
+}
+
+// testRequest sends a test request, optionally follows its redirect chain
+// and reads the response body
+func testRequest(ctx context.Context, method, url string, headers map[string]string, limit int, readBody bool) HTTPResult {
+	client := testClient()
+
+	req, err := newTestRequestContext(ctx, method, url, headers)
 	if err != nil {
-		return HTTPResult{Status: 0, StatusText: "Error", Result: "Connection failed"}
+		return HTTPResult{Status: 0, StatusText: "Error", Result: "Request failed"}
+	}
+
+	resp, failure := doTestRequest(client, req)
+	if resp == nil {
+		return HTTPResult{Status: 0, StatusText: "Error", Result: failure}
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -541,34 +1156,41 @@ func testURL(url, countryCode, userAgent string) HTTPResult {
 		}
 	}
 
-	return HTTPResult{
+	httpResult := HTTPResult{
 		Status:     resp.StatusCode,
 		StatusText: resp.Status,
 		Result:     result,
+		Header:     resp.Header.Clone(),
+	}
+	if readBody {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if err != nil {
+			log.Printf("Error reading response body: %v", err)
+		}
+		httpResult.Body = string(body)
+	}
+	if limit > 0 {
+		httpResult.Chain, httpResult.ChainIssue = followChain(ctx, client, newRedirectHop(url, resp), headers, limit)
 	}
+	return httpResult
 }
 
 func testSpecialURL(url string, headers map[string]string) HTTPResult {
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Timeout: 5 * time.Second,
-	}
+	return testSpecialURLContext(context.Background(), url, headers)
+}
+
+// testSpecialURLContext is testSpecialURL bound to a context
+func testSpecialURLContext(ctx context.Context, url string, headers map[string]string) HTTPResult {
+	client := testClient()
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := newTestRequestContext(ctx, "GET", url, headers)
 	if err != nil {
 		return HTTPResult{Status: 0, StatusText: "Error", Result: "Request failed"}
 	}
 
-	// Set custom headers
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return HTTPResult{Status: 0, StatusText: "Error", Result: "Connection failed"}
+	resp, failure := doTestRequest(client, req)
+	if resp == nil {
+		return HTTPResult{Status: 0, StatusText: "Error", Result: failure}
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -592,152 +1214,116 @@ func testSpecialURL(url string, headers map[string]string) HTTPResult {
 		}
 	}
 
-	return HTTPResult{
+	httpResult := HTTPResult{
 		Status:     resp.StatusCode,
 		StatusText: resp.Status,
 		Result:     result,
+		Header:     resp.Header.Clone(),
+	}
+	if followLimit > 0 {
+		httpResult.Chain, httpResult.ChainIssue = followChain(ctx, client, newRedirectHop(url, resp), headers, followLimit)
 	}
+	return httpResult
 }
 
-func watchFile() tea.Cmd {
-	return func() tea.Msg {
-		watcher, err := fsnotify.NewWatcher()
-		if err != nil {
-			return fmt.Errorf("failed to create watcher: %w", err)
-		}
-		defer func() {
-			if err := watcher.Close(); err != nil {
-				log.Printf("Error closing watcher: %v", err)
-			}
-		}()
-
-		// Add .htaccess file to watcher (now in packages/shared)
-		htaccessPath := "../../.htaccess"
-		if err := watcher.Add(htaccessPath); err != nil {
-			return fmt.Errorf("failed to watch .htaccess: %w", err)
-		}
-
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return nil
-				}
+// linkTestParams returns the country header value and User-Agent for a
+// LinkTest; the agent is looked up in the user-agent catalog
+func linkTestParams(test LinkTest) (countryCode, userAgent string) {
+	if agent, ok := lookupUserAgent(test.Agent); ok {
+		userAgent = agent.UserAgent
+	}
 
-				// Check if the event is for .htaccess file
-				if filepath.Base(event.Name) == ".htaccess" {
-					// Trigger on Write, Create, or Rename events
-					if event.Op&fsnotify.Write == fsnotify.Write ||
-						event.Op&fsnotify.Create == fsnotify.Create ||
-						event.Op&fsnotify.Rename == fsnotify.Rename {
-						return fileChangedMsg(event.Name)
-					}
-				}
+	return test.Country, userAgent
+}
 
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return nil
-				}
-				log.Printf("Watcher error: %v", err)
-			}
-		}
+// linkTestMethod returns the HTTP method of a LinkTest
+func linkTestMethod(test LinkTest) string {
+	if test.Method == "" {
+		return "GET"
 	}
+	return test.Method
 }
 
-// parseLinkTestFile reads and parses the links.testing CSV file
-func parseLinkTestFile(filename string) ([]LinkTest, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// linkTestURL returns the URL of a LinkTest with its query parameters added
+func linkTestURL(test LinkTest) string {
+	if len(test.Query) == 0 {
+		return test.URL
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			log.Printf("Error closing file: %v", err)
-		}
-	}()
-
-	reader := csv.NewReader(file)
-	reader.TrimLeadingSpace = true
-
-	records, err := reader.ReadAll()
+	u, err := url.Parse(test.URL)
 	if err != nil {
-		return nil, err
+		return test.URL
+	}
+	query := u.Query()
+	for name, value := range test.Query {
+		query.Set(name, value)
 	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
 
-	var tests []LinkTest
-	// Skip header row
-	for i, record := range records {
-		if i == 0 || len(record) < 5 {
-			continue
+// linkTestHeaders returns the request headers of a LinkTest: the country and
+// agent headers, then its own headers and cookies
+func linkTestHeaders(test LinkTest) map[string]string {
+	headers := testHeaders(linkTestParams(test))
+	for name, value := range test.Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	if len(test.Cookies) > 0 {
+		names := make([]string, 0, len(test.Cookies))
+		for name := range test.Cookies {
+			names = append(names, name)
 		}
-
-		// Parse expected status
-		expectedStatus, err := strconv.Atoi(strings.TrimSpace(record[3]))
-		if err != nil {
-			expectedStatus = 200 // default
+		sort.Strings(names)
+		cookies := make([]string, 0, len(names))
+		for _, name := range names {
+			cookies = append(cookies, (&http.Cookie{Name: name, Value: test.Cookies[name]}).String())
 		}
-
-		test := LinkTest{
-			Agent:          strings.TrimSpace(record[0]),
-			Country:        strings.TrimSpace(record[1]),
-			URL:            strings.TrimSpace(record[2]),
-			ExpectedStatus: expectedStatus,
-			ExpectedResult: strings.TrimSpace(record[4]),
+		if existing := headers["Cookie"]; existing != "" {
+			cookies = append([]string{existing}, cookies...)
 		}
-		tests = append(tests, test)
+		headers["Cookie"] = strings.Join(cookies, "; ")
 	}
-
-	return tests, nil
+	return headers
 }
 
 // runLinkTests executes tests from links.testing file
 func runLinkTests(tests []LinkTest) []LinkTestResult {
-	var results []LinkTestResult
+	ctx, cancel := runContext()
+	defer cancel()
 
-	for _, test := range tests {
-		var userAgent string
-		if strings.ToLower(test.Agent) == "googlebot" {
-			userAgent = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
-		}
+	results := make([]LinkTestResult, len(tests))
+	runPool(ctx, len(tests), func(ctx context.Context, i int) LinkTestResult {
+		return runLinkTest(ctx, tests[i])
+	}, func(i int, result LinkTestResult) bool {
+		results[i] = result
+		return true
+	})
 
-		// Set country header
-		countryCode := strings.ToUpper(test.Country)
-		if countryCode == "UK" {
-			countryCode = "GB"
-		}
-
-		httpResult := testURL(test.URL, countryCode, userAgent)
-
-		// Check if result matches expectations - status code is primary indicator
-		success := true
-		if httpResult.Status != test.ExpectedStatus {
-			success = false
-		} else {
-			// If status matches, check result only for additional validation
-			if test.ExpectedResult != "No redirect" && !strings.Contains(httpResult.Result, test.ExpectedResult) {
-				// Only fail if status is redirect but result doesn't match expected redirect
-				if httpResult.Status == 301 || httpResult.Status == 302 {
-					success = false
-				}
-			}
-			if test.ExpectedResult == "No redirect" && httpResult.Result != "No redirect" {
-				// Only fail if we expected no redirect but got one with wrong status
-				if httpResult.Status != test.ExpectedStatus {
-					success = false
-				}
-			}
-		}
+	return results
+}
 
-		result := LinkTestResult{
-			Test:    test,
-			Status:  httpResult.Status,
-			Result:  httpResult.Result,
-			Success: success,
-		}
-		results = append(results, result)
+// runLinkTest sends a single test case and judges the response
+func runLinkTest(ctx context.Context, test LinkTest) LinkTestResult {
+	limit := followLimit
+	if limit == 0 && (test.ExpectedFinal != "" || test.ExpectedHops > 0) {
+		limit = defaultFollowLimit
+	}
+	readBody := len(test.BodyContains) > 0 || test.BodyRegex != ""
+	start := time.Now()
+	httpResult := testRequest(ctx, linkTestMethod(test), linkTestURL(test), linkTestHeaders(test), limit, readBody)
+
+	success, reason := checkLinkTest(test, httpResult)
+	return LinkTestResult{
+		Test:       test,
+		Status:     httpResult.Status,
+		Result:     httpResult.Result,
+		Success:    success,
+		Reason:     reason,
+		Response:   httpResult.Header,
+		Chain:      httpResult.Chain,
+		ChainIssue: httpResult.ChainIssue,
+		Duration:   time.Since(start),
 	}
-
-	return results
 }
 
 // FilterState represents the current filtering options
@@ -1009,20 +1595,13 @@ func displayResultsPageCore(results []LinkTestResult, start, end, currentPage, t
 		}
 
 		// Add icons for different agents
-		var agentIcon string
-		if strings.Contains(strings.ToLower(result.Test.Agent), "googlebot") {
-			agentIcon = "🤖"
-		} else if strings.Contains(strings.ToLower(result.Test.Agent), "browser") {
-			agentIcon = "🌐"
-		} else {
-			agentIcon = "🔍"
-		}
+		agentIcon := userAgentIcon(result.Test.Agent)
 
 		// Color code the agent with icon
 		agentStyled := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B6B")).Render(agentIcon + " " + result.Test.Agent)
 
 		// Color code the country with flag
-		countryStyled := lipgloss.NewStyle().Foreground(lipgloss.Color("#4ECDC4")).Render(result.Test.Country)
+		countryStyled := lipgloss.NewStyle().Foreground(lipgloss.Color("#4ECDC4")).Render(countryLabel(result.Test.Country))
 
 		// Color code the URL with link icon
 		url := result.Test.URL
@@ -1052,9 +1631,9 @@ func displayResultsPageCore(results []LinkTestResult, start, end, currentPage, t
 		expectedStyled := lipgloss.NewStyle().Foreground(lipgloss.Color("#96CEB4")).Render(expectedIcon + " " + fmt.Sprintf("%d", result.Test.ExpectedStatus))
 		actualStyled := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFEAA7")).Render(actualIcon + " " + fmt.Sprintf("%d", result.Status))
 
-		resultText := result.Result
-		if len(resultText) > 42 {
-			resultText = resultText[:38] + "..."
+		resultText := describeChain(result.Result, result.Chain, result.ChainIssue)
+		if runes := []rune(resultText); len(runes) > 42 {
+			resultText = string(runes[:38]) + "..."
 		}
 		resultStyled := lipgloss.NewStyle().Foreground(lipgloss.Color("#DDA0DD")).Render(resultText)
 		expectedResult := result.Test.ExpectedResult
@@ -1093,6 +1672,12 @@ func displayResultsPageCore(results []LinkTestResult, start, end, currentPage, t
 	} else {
 		fmt.Printf("⚠️  %d tests failed\n", len(results)-totalSuccessCount)
 		fmt.Print("\r")
+		for i := start; i < end; i++ {
+			if result := results[i]; !result.Success && result.Reason != "" {
+				fmt.Printf("   ↳ %s %s %s: %s\n", result.Test.Agent, result.Test.Country, result.Test.URL, result.Reason)
+				fmt.Print("\r")
+			}
+		}
 	}
 
 	fmt.Println()
@@ -1139,15 +1724,10 @@ func displayResultsPageCore(results []LinkTestResult, start, end, currentPage, t
 
 // watchFilesAndRetest monitors files for changes and re-runs tests
 func watchFilesAndRetest(testFile string) {
-	fmt.Printf("👁️  Watching files for changes: %s, .htaccess\n", testFile)
-	fmt.Println("Press Ctrl+C to stop watching")
-
-	// Initial test run
-	runTestsOnce(testFile)
-
-	watcher, err := fsnotify.NewWatcher()
+	paths := monitorWatchPaths(testFile)
+	watcher, err := newFileWatcher(paths, watchDebounce)
 	if err != nil {
-		fmt.Printf("❌ Failed to create watcher: %v\n", err)
+		fmt.Printf("❌ %v\n", err)
 		os.Exit(1)
 	}
 	defer func() {
@@ -1156,17 +1736,11 @@ func watchFilesAndRetest(testFile string) {
 		}
 	}()
 
-	// Add files to watch
-	if err := watcher.Add(testFile); err != nil {
-		fmt.Printf("❌ Failed to watch test file %s: %v\n", testFile, err)
-		os.Exit(1)
-	}
+	fmt.Printf("👁️  Watching files for changes: %s\n", strings.Join(paths, ", "))
+	fmt.Println("Press Ctrl+C to stop watching")
 
-	htaccessPath := "../../.htaccess"
-	if err := watcher.Add(htaccessPath); err != nil {
-		fmt.Printf("❌ Failed to watch .htaccess file %s: %v\n", htaccessPath, err)
-		os.Exit(1)
-	}
+	// Initial test run
+	runTestsOnce(testFile)
 
 	// Create channels for communication
 	quit := make(chan bool)
@@ -1203,26 +1777,12 @@ func watchFilesAndRetest(testFile string) {
 
 	for {
 		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-
-			// Check for write events
-			if event.Op&fsnotify.Write == fsnotify.Write ||
-				event.Op&fsnotify.Create == fsnotify.Create ||
-				event.Op&fsnotify.Rename == fsnotify.Rename {
-
-				// Small delay to ensure file write is complete
-				time.Sleep(500 * time.Millisecond)
-				runTestsOnce(testFile)
-			}
-
-		case err, ok := <-watcher.Errors:
+		case files, ok := <-watcher.Changes():
 			if !ok {
 				return
 			}
-			fmt.Printf("❌ Watcher error: %v\n", err)
+			runTestsOnce(testFile)
+			fmt.Printf("📝 Re-ran after %s changed at %s\n", describeChange(files), time.Now().Format("15:04:05"))
 		case <-quit:
 			return
 		}
@@ -1242,7 +1802,12 @@ func runTestsOnce(testFile string) {
 		return
 	}
 
+	started := time.Now()
 	results := runLinkTests(tests)
+	recordLinkRun(testFile, tests, results, started)
+	if err := writeReports(testFile, tests, results, started); err != nil {
+		fmt.Printf("❌ %v\n", err)
+	}
 	displayLinkTestResultsWatch(results)
 }
 
@@ -1296,8 +1861,99 @@ func main() {
 	var testFile = flag.String("test", "", "Run tests from links.testing file")
 	var watch = flag.Bool("watch", false, "Watch files for changes and re-run tests")
 	var version = flag.Bool("version", false, "Show version information")
+	var backend = flag.String("backend", "http", "Backend to test against: http (live server) or offline (in-process .htaccess evaluation)")
+	var htaccess = flag.String("htaccess", "", "Path to the .htaccess file to watch and evaluate (default: "+htaccessPath+", or ./"+htaccessName+" when that does not exist)")
+	flag.Var(&extraWatchPaths, "watch-path", "Also watch this file, glob (e.g. 'sites/*/.htaccess') or directory (every .htaccess below it); repeatable")
+	var docRoot = flag.String("docroot", "", "Document root for offline -f/-d checks (default: Docker test image layout)")
+	var explain = flag.Bool("explain", false, "With -test, print which RewriteCond/RewriteRule lines fired for each test case")
+	var follow = flag.Int("follow", 0, "Follow redirect chains up to N redirects and flag loops (0 = first hop only)")
+	var configFile = flag.String("config", "", "Test matrix config file (default: "+defaultConfigFile+" when present)")
+	var ci = flag.Bool("ci", false, "With -test, run once with plain output and exit 0 (pass), 1 (failures) or 2 (error); implied when stdout is not a terminal")
+	var failFast = flag.Bool("fail-fast", false, "In CI mode, stop at the first failing test")
+	var maxFailures = flag.Int("max-failures", 0, "In CI mode, stop after N failing tests (0 = no limit)")
+	flag.Var(&reports, "report", "With -test, write a report as format=path (junit, tap, json, markdown, html; path - for stdout); repeatable")
+	flag.IntVar(&concurrency, "concurrency", defaultConcurrency, "Number of test requests sent in parallel")
+	flag.DurationVar(&runDeadline, "deadline", 0, "Give up on requests still unanswered this long after a run started, e.g. 30s (0 = no deadline)")
+	flag.StringVar(&reportGroup, "report-group", groupByAgent, "Group report test cases into suites by agent or country")
+	flag.BoolVar(&clientIPHeaders, "client-ip", false, "Send each country's IP in X-Forwarded-For/X-Real-IP instead of X-Test-Country")
+	var geoIPDB = flag.String("geoip-db", "", "MaxMind .mmdb database the offline backend and serve use to geolocate client IPs")
+	var rdns = flag.String("rdns", "", "Reverse DNS for REMOTE_HOST and crawler checks: system, or a hosts file as a stub resolver")
+	flag.StringVar(&historyPath, "history", "", "JSON-lines file every run is appended to, e.g. "+defaultHistoryFile+" (default: no history)")
+	var agentList = flag.String("agents", "", "Comma-separated catalog agents to test the matrix with, one table each (e.g. googlebot,bingbot,chrome-mobile)")
 	flag.Parse()
 
+	htaccessPath = defaultHtaccessPath()
+	if *htaccess != "" {
+		htaccessPath = *htaccess
+	}
+	followLimit = *follow
+	if concurrency < 1 {
+		fmt.Println("❌ -concurrency must be at least 1")
+		os.Exit(exitError)
+	}
+	if reportGroup != groupByAgent && reportGroup != groupByCountry {
+		fmt.Printf("❌ -report-group must be %s or %s\n", groupByAgent, groupByCountry)
+		os.Exit(exitError)
+	}
+	cfg, err := resolveConfig(*configFile)
+	if err != nil {
+		fmt.Printf("❌ Error reading config: %v\n", err)
+		os.Exit(exitError)
+	}
+	if *agentList != "" {
+		if cfg.Agents, err = parseAgentList(cfg, *agentList); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	monitorConfig, countries = cfg, cfg.Countries
+	if err := configureBackend(*backend, *docRoot); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(exitError)
+	}
+	if *geoIPDB != "" {
+		db, err := openGeoDB(*geoIPDB)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(exitError)
+		}
+		defer db.Close()
+		geoDatabase = db
+	}
+
+	if *rdns != "" {
+		if reverseDNS, err = openReverseDNS(*rdns); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+
+	// Subcommands
+	switch flag.Arg(0) {
+	case "lint":
+		os.Exit(runLintCommand(flag.Args()[1:]))
+	case "report":
+		os.Exit(runReportCommand(flag.Args()[1:]))
+	case "serve":
+		os.Exit(runServeCommand(flag.Args()[1:]))
+	case "geodb":
+		os.Exit(runGeoDBCommand(flag.Args()[1:]))
+	case "sweep":
+		os.Exit(runSweepCommand(flag.Args()[1:]))
+	case "agents":
+		os.Exit(runAgentsCommand(flag.Args()[1:]))
+	case "spoof":
+		os.Exit(runSpoofCommand(flag.Args()[1:]))
+	case "history":
+		os.Exit(runHistoryCommand(flag.Args()[1:]))
+	case "bisect":
+		os.Exit(runBisectCommand(flag.Args()[1:]))
+	case "compare":
+		os.Exit(runCompareCommand(flag.Args()[1:]))
+	case "generate":
+		os.Exit(runGenerateCommand(flag.Args()[1:]))
+	}
+
 	if *version {
 		fmt.Printf("Version: %s\n", Version)
 		fmt.Printf("Build Time: %s\n", BuildTime)
@@ -1306,9 +1962,15 @@ func main() {
 
 	// Check if we should run link tests
 	if *testFile != "" {
+		if *explain {
+			runExplain(*testFile)
+			return
+		}
 		if *watch {
 			// Watch mode - monitor files for changes
 			watchFilesAndRetest(*testFile)
+		} else if *ci || !stdoutIsTerminal() {
+			os.Exit(runCI(os.Stdout, *testFile, ciOptions{failFast: *failFast, maxFailures: *maxFailures}))
 		} else {
 			// Single run mode
 			fmt.Printf("🔍 Running link tests from: %s\n", *testFile)
@@ -1325,14 +1987,25 @@ func main() {
 			}
 
 			fmt.Printf("📋 Found %d test cases\n", len(tests))
+			started := time.Now()
 			results := runLinkTests(tests)
+			recordLinkRun(*testFile, tests, results, started)
+			if err := writeReports(*testFile, tests, results, started); err != nil {
+				fmt.Printf("❌ %v\n", err)
+				os.Exit(exitError)
+			}
 			displayLinkTestResults(results)
 		}
 		return
 	}
 
 	// Default behavior - run interactive monitor
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
+	m := initialModel()
+	m.watcher, m.watchErr = newFileWatcher(monitorWatchPaths(""), watchDebounce)
+	if m.watcher != nil {
+		defer m.watcher.Close()
+	}
+	p := tea.NewProgram(m, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error running program: %v", err)
 		os.Exit(1)
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Redirect target matchers for the expected result of a test case
+const (
+	matchContains = "contains" // Location contains the expected result; the default
+	matchExact    = "exact"    // Location equals the expected URL after normalisation
+	matchPath     = "path"     // Location path equals the expected path
+	matchPrefix   = "prefix"   // Location starts with the expected URL after normalisation
+	matchRegex    = "regex"    // Location, as sent, matches the expected regular expression
+	matchNone     = "none"     // no Location header
+)
+
+// matchers lists the valid matcher names
+var matchers = []string{matchContains, matchExact, matchPath, matchPrefix, matchRegex, matchNone}
+
+// validateMatcher checks the matcher of a test case against its expected result
+func validateMatcher(test LinkTest) error {
+	switch {
+	case test.Match == "" || test.Match == matchContains || test.Match == matchNone:
+	case !slices.Contains(matchers, test.Match):
+		return fmt.Errorf("unknown matcher %q (want one of %s)", test.Match, strings.Join(matchers, ", "))
+	case test.ExpectedResult == "" || test.ExpectedResult == "No redirect":
+		return fmt.Errorf("matcher %q needs an expected result", test.Match)
+	case test.Match == matchRegex:
+		if _, err := regexp.Compile(test.ExpectedResult); err != nil {
+			return fmt.Errorf("invalid expected result regex: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkLinkTest judges a response against a LinkTest and explains every
+// failed check
+func checkLinkTest(test LinkTest, httpResult HTTPResult) (bool, string) {
+	var failures []string
+	if httpResult.Status != test.ExpectedStatus {
+		failures = append(failures, fmt.Sprintf("status: expected %d, got %d", test.ExpectedStatus, httpResult.Status))
+	}
+	if reason := checkTarget(test, httpResult); reason != "" {
+		failures = append(failures, reason)
+	}
+	if reason := checkQueryPreserved(test, httpResult); reason != "" {
+		failures = append(failures, reason)
+	}
+
+	// Redirect chain checks apply whenever the chain was followed
+	if httpResult.ChainIssue != "" {
+		failures = append(failures, fmt.Sprintf("chain: %s (%s)", httpResult.ChainIssue, formatChain(httpResult.Chain, "")))
+	}
+	if test.ExpectedFinal != "" {
+		if final := chainFinalURL(httpResult.Chain); !strings.Contains(final, test.ExpectedFinal) {
+			failures = append(failures, fmt.Sprintf("final: %q does not contain %q", final, test.ExpectedFinal))
+		}
+	}
+	if test.ExpectedHops > 0 && len(httpResult.Chain) != test.ExpectedHops {
+		failures = append(failures, fmt.Sprintf("hops: expected %d, got %d (%s)", test.ExpectedHops, len(httpResult.Chain), formatChain(httpResult.Chain, "")))
+	}
+
+	// Response header and body assertions
+	names := make([]string, 0, len(test.ExpectHeaders))
+	for name := range test.ExpectHeaders {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		value := test.ExpectHeaders[name]
+		values := httpResult.Header.Values(name)
+		switch {
+		case len(values) == 0:
+			failures = append(failures, fmt.Sprintf("header %s: missing", name))
+		case !strings.Contains(strings.Join(values, ", "), value):
+			failures = append(failures, fmt.Sprintf("header %s: %q does not contain %q", name, strings.Join(values, ", "), value))
+		}
+	}
+	for _, text := range test.BodyContains {
+		if !strings.Contains(httpResult.Body, text) {
+			failures = append(failures, fmt.Sprintf("body: does not contain %q", text))
+		}
+	}
+	if test.BodyRegex != "" {
+		if re, err := regexp.Compile(test.BodyRegex); err != nil || !re.MatchString(httpResult.Body) {
+			failures = append(failures, fmt.Sprintf("body: does not match /%s/", test.BodyRegex))
+		}
+	}
+
+	return len(failures) == 0, strings.Join(failures, "; ")
+}
+
+// responseLocation returns the Location header of a response
+func responseLocation(httpResult HTTPResult) string {
+	if location := httpResult.Header.Get("Location"); location != "" {
+		return location
+	}
+	if httpResult.Header == nil && httpResult.Result != "No redirect" && httpResult.Status >= 300 && httpResult.Status < 400 {
+		return httpResult.Result
+	}
+	return ""
+}
+
+// checkTarget applies the redirect target matcher of a test case
+func checkTarget(test LinkTest, httpResult HTTPResult) string {
+	location := responseLocation(httpResult)
+	expected := test.ExpectedResult
+
+	switch test.Match {
+	case "", matchContains:
+		// Original semantics: the expected result is only checked when the
+		// status matches, on 301/302, and "No redirect" rejects any redirect
+		if httpResult.Status != test.ExpectedStatus {
+			return ""
+		}
+		if expected == "No redirect" {
+			if httpResult.Result != "No redirect" {
+				return fmt.Sprintf("contains: expected no redirect, got Location %q", httpResult.Result)
+			}
+			return ""
+		}
+		if (httpResult.Status == 301 || httpResult.Status == 302) && !strings.Contains(httpResult.Result, expected) {
+			return fmt.Sprintf("contains: Location %q does not contain %q", httpResult.Result, expected)
+		}
+		return ""
+	case matchNone:
+		if location != "" {
+			return fmt.Sprintf("none: expected no redirect, got Location %q", location)
+		}
+		return ""
+	}
+
+	if location == "" {
+		return fmt.Sprintf("%s: expected Location matching %q, got none", test.Match, expected)
+	}
+	requestURL := linkTestURL(test)
+	actual := normalizeLocation(requestURL, location)
+	want := normalizeLocation(requestURL, expected)
+
+	switch test.Match {
+	case matchExact:
+		if actual != want {
+			return fmt.Sprintf("exact: Location %q is not %q", actual, want)
+		}
+	case matchPrefix:
+		if !strings.HasPrefix(actual, want) {
+			return fmt.Sprintf("prefix: Location %q does not start with %q", actual, want)
+		}
+	case matchPath:
+		if got, wantPath := locationPath(actual), locationPath(want); got != wantPath {
+			return fmt.Sprintf("path: Location path %q is not %q", got, wantPath)
+		}
+	case matchRegex:
+		re, err := regexp.Compile(expected)
+		if err != nil {
+			return fmt.Sprintf("regex: invalid pattern: %v", err)
+		}
+		if !re.MatchString(location) {
+			return fmt.Sprintf("regex: Location %q does not match /%s/", location, expected)
+		}
+	}
+	return ""
+}
+
+// checkQueryPreserved verifies that the redirect kept every query parameter
+// of the request, as a [QSA] rule should
+func checkQueryPreserved(test LinkTest, httpResult HTTPResult) string {
+	if !test.PreserveQuery {
+		return ""
+	}
+	location := responseLocation(httpResult)
+	if location == "" {
+		return "query: expected a redirect preserving the query string, got no Location"
+	}
+
+	requestURL := linkTestURL(test)
+	request, err := url.Parse(requestURL)
+	if err != nil {
+		return fmt.Sprintf("query: invalid request URL %q", requestURL)
+	}
+	target, err := url.Parse(normalizeLocation(requestURL, location))
+	if err != nil {
+		return fmt.Sprintf("query: invalid Location %q", location)
+	}
+
+	kept := target.Query()
+	var missing []string
+	for name, values := range request.Query() {
+		for _, value := range values {
+			if !slices.Contains(kept[name], value) {
+				missing = append(missing, name+"="+value)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Sprintf("query: Location %q drops %s", location, strings.Join(missing, "&"))
+	}
+	return ""
+}
+
+// normalizeLocation resolves a possibly relative Location against the request
+// URL and lower-cases the scheme and host
+func normalizeLocation(requestURL, location string) string {
+	base, err := url.Parse(requestURL)
+	if err != nil {
+		return location
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	resolved := base.ResolveReference(ref)
+	resolved.Scheme = strings.ToLower(resolved.Scheme)
+	resolved.Host = strings.ToLower(resolved.Host)
+	return resolved.String()
+}
+
+// locationPath returns the path of a URL
+func locationPath(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	return u.Path
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// redirectResult builds an HTTPResult for a response with a Location header
+func redirectResult(status int, location string) HTTPResult {
+	header := http.Header{}
+	result := "No redirect"
+	if location != "" {
+		header.Set("Location", location)
+		if status == 301 || status == 302 {
+			result = location
+		}
+	}
+	return HTTPResult{Status: status, Result: result, Header: header}
+}
+
+// TestCheckLinkTestMatchers tests the redirect target matchers and their failure messages
+func TestCheckLinkTestMatchers(t *testing.T) {
+	tests := []struct {
+		name     string
+		test     LinkTest
+		response HTTPResult
+		reason   string // empty when the check passes
+	}{
+		{"contains", LinkTest{ExpectedStatus: 302, ExpectedResult: "/de/"}, redirectResult(302, "http://localhost/de/"), ""},
+		{"contains mismatch", LinkTest{ExpectedStatus: 302, ExpectedResult: "/de/"}, redirectResult(302, "http://localhost/uk/"),
+			`contains: Location "http://localhost/uk/" does not contain "/de/"`},
+		{"contains no redirect", LinkTest{ExpectedStatus: 301, ExpectedResult: "No redirect"}, redirectResult(301, "/uk/"),
+			`contains: expected no redirect, got Location "/uk/"`},
+		{"contains ignores non-redirects", LinkTest{ExpectedStatus: 200, ExpectedResult: "OK"}, redirectResult(200, ""), ""},
+		{"status", LinkTest{ExpectedStatus: 302, ExpectedResult: "/de/"}, redirectResult(301, "http://localhost/de/"),
+			"status: expected 302, got 301"},
+
+		{"exact relative location", LinkTest{ExpectedStatus: 302, ExpectedResult: "http://LOCALHOST:8080/de/", Match: matchExact}, redirectResult(302, "/de/"), ""},
+		{"exact relative expectation", LinkTest{ExpectedStatus: 302, ExpectedResult: "/de/", Match: matchExact}, redirectResult(302, "http://localhost:8080/de/"), ""},
+		{"exact rejects longer", LinkTest{ExpectedStatus: 302, ExpectedResult: "/de/", Match: matchExact}, redirectResult(302, "/de/page"),
+			`exact: Location "http://localhost:8080/de/page" is not "http://localhost:8080/de/"`},
+		{"exact on 307", LinkTest{ExpectedStatus: 307, ExpectedResult: "/de/", Match: matchExact}, redirectResult(307, "/de/"), ""},
+		{"prefix", LinkTest{ExpectedStatus: 302, ExpectedResult: "/de/", Match: matchPrefix}, redirectResult(302, "/de/page?a=1"), ""},
+		{"prefix other host", LinkTest{ExpectedStatus: 302, ExpectedResult: "/de/", Match: matchPrefix}, redirectResult(302, "https://example.com/de/"),
+			`prefix: Location "https://example.com/de/" does not start with "http://localhost:8080/de/"`},
+		{"path ignores host and query", LinkTest{ExpectedStatus: 301, ExpectedResult: "/uk/", Match: matchPath}, redirectResult(301, "https://www.example.com/uk/?x=1"), ""},
+		{"path mismatch", LinkTest{ExpectedStatus: 301, ExpectedResult: "/uk/", Match: matchPath}, redirectResult(301, "/uk/uk/"),
+			`path: Location path "/uk/uk/" is not "/uk/"`},
+		{"regex", LinkTest{ExpectedStatus: 302, ExpectedResult: `^http://[^/]+/(de|at)/$`, Match: matchRegex}, redirectResult(302, "http://localhost/at/"), ""},
+		{"regex mismatch", LinkTest{ExpectedStatus: 302, ExpectedResult: `^/de/$`, Match: matchRegex}, redirectResult(302, "http://localhost/de/"),
+			`regex: Location "http://localhost/de/" does not match /^/de/$/`},
+		{"matcher without redirect", LinkTest{ExpectedStatus: 200, ExpectedResult: "/de/", Match: matchExact}, redirectResult(200, ""),
+			`exact: expected Location matching "/de/", got none`},
+		{"none", LinkTest{ExpectedStatus: 200, Match: matchNone}, redirectResult(200, ""), ""},
+		{"none with location", LinkTest{ExpectedStatus: 200, Match: matchNone}, redirectResult(200, "/de/"),
+			`none: expected no redirect, got Location "/de/"`},
+
+		{"query preserved", LinkTest{URL: "http://localhost:8080/page?a=1&b=2", ExpectedStatus: 302, ExpectedResult: "/de/", PreserveQuery: true},
+			redirectResult(302, "/de/page?b=2&a=1"), ""},
+		{"query dropped", LinkTest{URL: "http://localhost:8080/page?a=1&b=2", ExpectedStatus: 302, ExpectedResult: "/de/", PreserveQuery: true},
+			redirectResult(302, "/de/page?b=2"), `query: Location "/de/page?b=2" drops a=1`},
+		{"query with test query", LinkTest{URL: "http://localhost:8080/", Query: map[string]string{"utm": "x"}, ExpectedStatus: 302, ExpectedResult: "/de/", PreserveQuery: true},
+			redirectResult(302, "/de/"), `query: Location "/de/" drops utm=x`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.test.URL == "" {
+				tt.test.URL = "http://localhost:8080/"
+			}
+			passed, reason := checkLinkTest(tt.test, tt.response)
+			if passed != (tt.reason == "") || reason != tt.reason {
+				t.Errorf("checkLinkTest() = %v, %q; want reason %q", passed, reason, tt.reason)
+			}
+		})
+	}
+}
+
+// TestCheckLinkTestReasons tests that every failed check is explained
+func TestCheckLinkTestReasons(t *testing.T) {
+	test := LinkTest{
+		URL:            "http://localhost:8080/",
+		ExpectedStatus: 302,
+		ExpectedResult: "/de/",
+		Match:          matchExact,
+		ExpectedHops:   2,
+		ExpectHeaders:  map[string]string{"Vary": "X-Test-Country"},
+		BodyContains:   []string{"Moved"},
+	}
+	response := redirectResult(302, "/uk/")
+	response.Chain = []RedirectHop{{URL: "http://localhost:8080/", Status: 302}, {URL: "http://localhost:8080/uk/", Status: 302}, {URL: "http://localhost:8080/uk/uk/", Status: 200}}
+
+	_, reason := checkLinkTest(test, response)
+	for _, want := range []string{"exact: Location", "hops: expected 2, got 3 (302 → 302 → 200)", "header Vary: missing", `body: does not contain "Moved"`} {
+		if !strings.Contains(reason, want) {
+			t.Errorf("reason %q does not mention %q", reason, want)
+		}
+	}
+}
+
+// TestValidateMatcher tests matcher validation in test files
+func TestValidateMatcher(t *testing.T) {
+	path := writeTestFile(t, "links.testing", `URL,Expected status,Expected result,Match,Preserve query
+http://localhost/,302,/de/,exact,true
+http://localhost/,302,/de/,fuzzy,
+http://localhost/,200,,exact,
+http://localhost/,302,(,regex,
+http://localhost/,302,/de/,prefix,maybe
+`)
+	_, err := parseLinkTestFile(path)
+	if err == nil {
+		t.Fatal("parseLinkTestFile() expected errors")
+	}
+	for _, want := range []string{`:3: unknown matcher "fuzzy"`, `:4: matcher "exact" needs an expected result`, ":5: invalid expected result regex", `:6: invalid preserve query flag "maybe"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "links.testing:2:") {
+		t.Errorf("valid row reported: %v", err)
+	}
+}
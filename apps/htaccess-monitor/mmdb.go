@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"time"
+)
+
+// mmdbMetadataMarker separates the data section from the metadata
+const mmdbMetadataMarker = "\xAB\xCD\xEFMaxMind.com"
+
+// mmdbDataSeparator is the size of the zero block between tree and data
+const mmdbDataSeparator = 16
+
+// MaxMind DB data types
+const (
+	mmdbString = 2
+	mmdbMap    = 7
+	mmdbUint16 = 5
+	mmdbUint32 = 6
+	mmdbUint64 = 9
+	mmdbArray  = 11
+)
+
+// geoNetwork maps a network to a country in a generated database
+type geoNetwork struct {
+	Network *net.IPNet
+	Country string // ISO 3166 alpha-2 code
+}
+
+// mmdbNode is a node of the search tree; each side either points to another
+// node or holds a 1-based data index (0 = no data)
+type mmdbNode struct {
+	children [2]*mmdbNode
+	data     [2]int
+	index    int
+}
+
+// writeCountryDB writes a GeoLite2-Country compatible database of the given
+// networks. It is a minimal writer meant for test fixtures: networks must
+// not overlap and records only carry country.iso_code.
+func writeCountryDB(w io.Writer, networks []geoNetwork) error {
+	root := &mmdbNode{}
+	var codes []string
+	for _, n := range networks {
+		index := slices.Index(codes, n.Country)
+		if index < 0 {
+			codes = append(codes, n.Country)
+			index = len(codes) - 1
+		}
+		if err := root.insert(n.Network, index+1); err != nil {
+			return err
+		}
+	}
+
+	// Number the nodes breadth first so the root is node 0
+	nodes := []*mmdbNode{root}
+	for i := 0; i < len(nodes); i++ {
+		nodes[i].index = i
+		for _, child := range nodes[i].children {
+			if child != nil {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+
+	var data mmdbEncoder
+	offsets := make([]int, len(codes))
+	for i, code := range codes {
+		offsets[i] = data.Len()
+		data.value(map[string]any{"country": map[string]any{"iso_code": code}})
+	}
+
+	nodeCount := len(nodes)
+	record := func(n *mmdbNode, side int) uint32 {
+		switch {
+		case n.children[side] != nil:
+			return uint32(n.children[side].index)
+		case n.data[side] > 0:
+			return uint32(nodeCount + mmdbDataSeparator + offsets[n.data[side]-1])
+		}
+		return uint32(nodeCount)
+	}
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		binary.Write(&out, binary.BigEndian, [2]uint32{record(n, 0), record(n, 1)})
+	}
+	out.Write(make([]byte, mmdbDataSeparator))
+	out.Write(data.Bytes())
+	out.WriteString(mmdbMetadataMarker)
+
+	var meta mmdbEncoder
+	meta.value(map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               "GeoLite2-Country",
+		"description":                 map[string]any{"en": "htaccess-monitor test fixture"},
+		"ip_version":                  uint16(6),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(32),
+	})
+	out.Write(meta.Bytes())
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// insert stores a data index for a network, with IPv4 networks placed in
+// the ::/96 subtree like MaxMind does
+func (root *mmdbNode) insert(network *net.IPNet, data int) error {
+	ip := network.IP.To16()
+	ones, bits := network.Mask.Size()
+	if ip4 := network.IP.To4(); ip4 != nil && bits == 32 {
+		ip = append(make(net.IP, 12), ip4...)
+		ones += 96
+	}
+	if ip == nil || ones == 0 {
+		return fmt.Errorf("invalid network %s", network)
+	}
+
+	node := root
+	for i := range ones {
+		bit := int(ip[i/8]>>(7-i%8)) & 1
+		if node.data[bit] > 0 {
+			return fmt.Errorf("network %s overlaps another network", network)
+		}
+		if i == ones-1 {
+			if node.children[bit] != nil {
+				return fmt.Errorf("network %s overlaps another network", network)
+			}
+			node.data[bit] = data
+			return nil
+		}
+		if node.children[bit] == nil {
+			node.children[bit] = &mmdbNode{}
+		}
+		node = node.children[bit]
+	}
+	return nil
+}
+
+// mmdbEncoder writes values in the MaxMind DB data section format
+type mmdbEncoder struct {
+	bytes.Buffer
+}
+
+// control writes the control byte(s) of a value of the given type and size
+func (e *mmdbEncoder) control(typ, size int) {
+	var ext []byte
+	switch {
+	case size >= 65821:
+		s := size - 65821
+		ext, size = []byte{byte(s >> 16), byte(s >> 8), byte(s)}, 31
+	case size >= 285:
+		s := size - 285
+		ext, size = []byte{byte(s >> 8), byte(s)}, 30
+	case size >= 29:
+		ext, size = []byte{byte(size - 29)}, 29
+	}
+	if typ <= 7 {
+		e.WriteByte(byte(typ<<5 | size))
+	} else {
+		e.WriteByte(byte(size))
+		e.WriteByte(byte(typ - 7))
+	}
+	e.Write(ext)
+}
+
+// uint writes an unsigned integer with leading zero bytes dropped
+func (e *mmdbEncoder) uint(typ int, v uint64) {
+	b := binary.BigEndian.AppendUint64(nil, v)
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	e.control(typ, len(b))
+	e.Write(b)
+}
+
+// value writes a string, unsigned integer, map or array
+func (e *mmdbEncoder) value(v any) {
+	switch v := v.(type) {
+	case string:
+		e.control(mmdbString, len(v))
+		e.WriteString(v)
+	case uint16:
+		e.uint(mmdbUint16, uint64(v))
+	case uint32:
+		e.uint(mmdbUint32, uint64(v))
+	case uint64:
+		e.uint(mmdbUint64, v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		e.control(mmdbMap, len(keys))
+		for _, key := range keys {
+			e.value(key)
+			e.value(v[key])
+		}
+	case []any:
+		e.control(mmdbArray, len(v))
+		for _, item := range v {
+			e.value(item)
+		}
+	default:
+		panic(fmt.Sprintf("mmdb: unsupported type %T", v))
+	}
+}
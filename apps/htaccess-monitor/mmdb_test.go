@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mustCIDR parses a network for a test
+func mustCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return network
+}
+
+// writeTestGeoDB writes a country database of networks and opens it
+func writeTestGeoDB(t *testing.T, networks []geoNetwork) *geoDB {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeCountryDB(&buf, networks); err != nil {
+		t.Fatalf("writeCountryDB() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "countries.mmdb")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := openGeoDB(path)
+	if err != nil {
+		t.Fatalf("openGeoDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// TestWriteCountryDB tests that the generated database is valid and answers
+// IPv4, IPv6 and unknown addresses like a GeoLite2-Country database
+func TestWriteCountryDB(t *testing.T) {
+	db := writeTestGeoDB(t, []geoNetwork{
+		{Network: mustCIDR(t, "85.214.132.0/24"), Country: "DE"},
+		{Network: mustCIDR(t, "81.2.0.0/16"), Country: "GB"},
+		{Network: mustCIDR(t, "2001:db8:1::/48"), Country: "JP"},
+		{Network: mustCIDR(t, "85.214.133.0/24"), Country: "DE"},
+	})
+
+	if err := db.reader.Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if db.reader.Metadata.DatabaseType != "GeoLite2-Country" || db.reader.Metadata.IPVersion != 6 {
+		t.Errorf("metadata = %+v", db.reader.Metadata)
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"85.214.132.117", "DE"},
+		{"85.214.133.1", "DE"},
+		{"85.214.134.1", ""},
+		{"81.2.69.142", "GB"},
+		{"::ffff:81.2.69.142", "GB"},
+		{"2001:db8:1:ffff::1", "JP"},
+		{"2001:db8:2::1", ""},
+		{"10.0.0.1", ""},
+		{"::1", ""},
+	}
+	for _, tt := range tests {
+		if got := db.country(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("country(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+// TestWriteCountryDBOverlap tests that overlapping networks are rejected
+func TestWriteCountryDBOverlap(t *testing.T) {
+	networks := []geoNetwork{
+		{Network: mustCIDR(t, "10.0.0.0/8"), Country: "US"},
+		{Network: mustCIDR(t, "10.1.0.0/16"), Country: "DE"},
+	}
+	var buf bytes.Buffer
+	if err := writeCountryDB(&buf, networks); err == nil || !strings.Contains(err.Error(), "overlaps") {
+		t.Errorf("writeCountryDB() error = %v, want an overlap error", err)
+	}
+}
+
+// TestMMDBEncoderSizes tests the size encoding of long values by reading
+// them back as the database type of an empty database
+func TestMMDBEncoderSizes(t *testing.T) {
+	for _, size := range []int{0, 28, 29, 284, 285, 65820, 65821, 70000} {
+		var meta mmdbEncoder
+		meta.value(map[string]any{
+			"binary_format_major_version": uint16(2),
+			"database_type":               strings.Repeat("x", size),
+			"ip_version":                  uint16(6),
+			"node_count":                  uint32(0),
+			"record_size":                 uint16(32),
+		})
+		db := append(make([]byte, mmdbDataSeparator), mmdbMetadataMarker...)
+		reader, err := maxminddb.FromBytes(append(db, meta.Bytes()...))
+		if err != nil {
+			t.Fatalf("size %d: FromBytes() error = %v", size, err)
+		}
+		if got := len(reader.Metadata.DatabaseType); got != size {
+			t.Errorf("size %d: decoded length %d", size, got)
+		}
+	}
+}
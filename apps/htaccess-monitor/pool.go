@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultConcurrency is the number of test requests in flight at once
+const defaultConcurrency = 8
+
+// resultDeadline is the result of a request that was not answered before the
+// run deadline
+const resultDeadline = "Deadline exceeded"
+
+// Worker pool settings, set from -concurrency and -deadline
+var (
+	concurrency = defaultConcurrency
+	runDeadline time.Duration // 0 = no deadline
+)
+
+// The client shared by every test request, rebuilt when the backend changes
+var (
+	clientMu        sync.Mutex
+	sharedClient    *http.Client
+	sharedTransport http.RoundTripper
+)
+
+// testClient returns the shared, connection-reusing test client
+func testClient() *http.Client {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if sharedClient == nil || sharedTransport != backendTransport {
+		sharedClient = newHTTPClient()
+		sharedTransport = backendTransport
+	}
+	return sharedClient
+}
+
+// doTestRequest sends a test request; without a response it returns the
+// result to report, telling requests cut short by the run deadline apart
+// from connection failures
+func doTestRequest(client *http.Client, req *http.Request) (*http.Response, string) {
+	if req.Context().Err() != nil {
+		return nil, resultDeadline
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if req.Context().Err() != nil {
+			return nil, resultDeadline
+		}
+		return nil, "Connection failed"
+	}
+	return resp, ""
+}
+
+// runContext returns the context of a test run, bounded by -deadline
+func runContext() (context.Context, context.CancelFunc) {
+	if runDeadline > 0 {
+		return context.WithTimeout(context.Background(), runDeadline)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// runPool calls run for the indexes 0..n-1 on up to concurrency workers and
+// passes each result to emit, in completion order, on the calling goroutine.
+// Returning false from emit cancels the jobs that are still running.
+func runPool[T any](ctx context.Context, n int, run func(context.Context, int) T, emit func(int, T) bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type done struct {
+		index int
+		value T
+	}
+	jobs := make(chan int)
+	results := make(chan done, n)
+
+	var wg sync.WaitGroup
+	for range max(1, min(concurrency, n)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- done{i, run(ctx, i)}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range n {
+			jobs <- i
+		}
+	}()
+
+	for range n {
+		result := <-results
+		if !emit(result.index, result.value) {
+			cancel()
+			break
+		}
+	}
+	wg.Wait()
+}
+
+// runOrdered is runPool with the results passed to emit in index order
+func runOrdered[T any](ctx context.Context, n int, run func(context.Context, int) T, emit func(int, T) bool) {
+	pending := map[int]T{}
+	next := 0
+	runPool(ctx, n, run, func(i int, value T) bool {
+		pending[i] = value
+		for {
+			value, ok := pending[next]
+			if !ok {
+				return true
+			}
+			delete(pending, next)
+			if !emit(next, value) {
+				return false
+			}
+			next++
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newDelayServer returns a server that answers after ?delay= milliseconds
+// and counts the requests in flight
+func newDelayServer(t *testing.T, inFlight, peak *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordPeak(peak, atomic.AddInt32(inFlight, 1))
+		defer atomic.AddInt32(inFlight, -1)
+		delay, _ := strconv.Atoi(r.URL.Query().Get("delay"))
+		select {
+		case <-time.After(time.Duration(delay) * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(200)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// recordPeak raises peak to n when n is higher
+func recordPeak(peak *int32, n int32) {
+	for {
+		old := atomic.LoadInt32(peak)
+		if n <= old || atomic.CompareAndSwapInt32(peak, old, n) {
+			return
+		}
+	}
+}
+
+// setConcurrency sets the worker pool size for the duration of a test
+func setConcurrency(t *testing.T, n int, deadline time.Duration) {
+	t.Helper()
+	oldConcurrency, oldDeadline := concurrency, runDeadline
+	concurrency, runDeadline = n, deadline
+	t.Cleanup(func() { concurrency, runDeadline = oldConcurrency, oldDeadline })
+}
+
+// TestRunOrdered tests that results are emitted in index order while the
+// jobs run concurrently, and that emit can stop the pool
+func TestRunOrdered(t *testing.T) {
+	setConcurrency(t, 4, 0)
+
+	var running, peak int32
+	var order []int
+	runOrdered(context.Background(), 12, func(ctx context.Context, i int) int {
+		recordPeak(&peak, atomic.AddInt32(&running, 1))
+		defer atomic.AddInt32(&running, -1)
+		time.Sleep(time.Duration(12-i) * time.Millisecond)
+		return i * i
+	}, func(i, value int) bool {
+		if value != i*i {
+			t.Errorf("emit(%d, %d), want value %d", i, value, i*i)
+		}
+		order = append(order, i)
+		return true
+	})
+
+	if fmt.Sprint(order) != fmt.Sprint([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}) {
+		t.Errorf("emit order = %v, want ascending", order)
+	}
+	if peak < 2 || peak > 4 {
+		t.Errorf("peak concurrency = %d, want 2..4", peak)
+	}
+
+	emitted := 0
+	runOrdered(context.Background(), 10, func(ctx context.Context, i int) int { return i }, func(i, _ int) bool {
+		emitted++
+		return i < 2
+	})
+	if emitted != 3 {
+		t.Errorf("emit called %d times after stopping at index 2, want 3", emitted)
+	}
+}
+
+// TestRunLinkTestsPool tests ordered link test results, the concurrency
+// bound and per-request timing
+func TestRunLinkTestsPool(t *testing.T) {
+	var inFlight, peak int32
+	server := newDelayServer(t, &inFlight, &peak)
+	setConcurrency(t, 3, 0)
+
+	var tests []LinkTest
+	for i := range 9 {
+		tests = append(tests, LinkTest{
+			Line:           i + 1,
+			URL:            fmt.Sprintf("%s/?delay=%d", server.URL, 10*(9-i)),
+			ExpectedStatus: 200,
+			ExpectedResult: "No redirect",
+		})
+	}
+
+	results := runLinkTests(tests)
+	for i, result := range results {
+		if result.Test.Line != i+1 || !result.Success {
+			t.Errorf("results[%d] = line %d success %v, want line %d passing", i, result.Test.Line, result.Success, i+1)
+		}
+		if result.Duration <= 0 {
+			t.Errorf("results[%d].Duration = %v, want the request time", i, result.Duration)
+		}
+	}
+	if peak > 3 {
+		t.Errorf("peak requests in flight = %d, want at most 3", peak)
+	}
+}
+
+// TestRunLinkTestsDeadline tests that the global deadline ends a run against
+// a hanging server
+func TestRunLinkTestsDeadline(t *testing.T) {
+	var inFlight, peak int32
+	server := newDelayServer(t, &inFlight, &peak)
+	setConcurrency(t, 2, 100*time.Millisecond)
+
+	tests := []LinkTest{
+		{URL: server.URL + "/?delay=0", ExpectedStatus: 200, ExpectedResult: "No redirect"},
+	}
+	for range 6 {
+		tests = append(tests, LinkTest{URL: server.URL + "/?delay=3000", ExpectedStatus: 200, ExpectedResult: "No redirect"})
+	}
+
+	start := time.Now()
+	results := runLinkTests(tests)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("runLinkTests() took %v, want the deadline to stop it", elapsed)
+	}
+	if !results[0].Success {
+		t.Errorf("request answered before the deadline failed: %+v", results[0])
+	}
+	for i, result := range results[1:] {
+		if result.Status != 0 || result.Result != resultDeadline {
+			t.Errorf("results[%d] = %d %s, want %s", i+1, result.Status, result.Result, resultDeadline)
+		}
+	}
+}
+
+// TestTestClientShared tests that the test client is reused until the
+// backend changes
+func TestTestClientShared(t *testing.T) {
+	oldTransport := backendTransport
+	defer func() { backendTransport = oldTransport }()
+
+	backendTransport = nil
+	first := testClient()
+	if testClient() != first {
+		t.Error("testClient() returned a new client for the same backend")
+	}
+	backendTransport = newRewriteBackend(writeTestFile(t, ".htaccess", "RewriteEngine On\n"), offlineDocRoot)
+	if testClient() == first {
+		t.Error("testClient() kept the client after the backend changed")
+	}
+}
+
+// TestModelStreamsResults tests that cell results fill the tables as they
+// arrive and that results of an older run are dropped
+func TestModelStreamsResults(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Pages = cfg.Pages[:1]
+	cfg.SpecialCases = nil
+	ts, cells := planMatrix(cfg)
+
+	m := initialModel()
+	m.run = 1
+	updated, _ := m.Update(testPlanMsg{run: 1, suite: ts.clone(), updates: make(chan tea.Msg), cancel: func() {}})
+	m = updated.(model)
+	if !m.ready || !m.testing || m.total != len(cells) {
+		t.Fatalf("after plan: ready %v, testing %v, total %d, want ready testing with %d cells", m.ready, m.testing, m.total, len(cells))
+	}
+	if got := m.tables[0].Rows()[0][1]; got != "⏳" {
+		t.Errorf("pending status cell = %q, want ⏳", got)
+	}
+
+	result := ts.Groups[0].Results[0]
+	result.Pending, result.Status, result.Result = false, 302, "/uk/"
+	updated, _ = m.Update(cellResultMsg{run: 1, group: 0, index: 0, result: result})
+	m = updated.(model)
+	if got := m.tables[0].Rows()[0][1]; got != "🔄 302" {
+		t.Errorf("streamed status cell = %q, want 🔄 302", got)
+	}
+	if m.done != 1 {
+		t.Errorf("done = %d, want 1", m.done)
+	}
+
+	stale := result
+	stale.Status = 500
+	updated, _ = m.Update(cellResultMsg{run: 0, group: 0, index: 0, result: stale})
+	m = updated.(model)
+	if m.testSuite.Groups[0].Results[0].Status != 302 {
+		t.Error("result of an older run replaced the current one")
+	}
+}
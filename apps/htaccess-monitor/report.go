@@ -0,0 +1,484 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Report formats accepted by -report
+const (
+	reportJUnit    = "junit"
+	reportTAP      = "tap"
+	reportJSON     = "json"
+	reportMarkdown = "markdown"
+	reportHTML     = "html"
+)
+
+// reportFormatNames lists the valid report formats
+var reportFormatNames = []string{reportJUnit, reportTAP, reportJSON, reportMarkdown, reportHTML}
+
+// Ways of grouping test cases into suites
+const (
+	groupByAgent   = "agent"
+	groupByCountry = "country"
+)
+
+// reportTarget is a report format and the file it is written to; "-" is stdout
+type reportTarget struct {
+	Format string
+	Path   string
+}
+
+// reportTargets collects repeated -report format=path flags
+type reportTargets []reportTarget
+
+// String implements flag.Value
+func (r *reportTargets) String() string {
+	var parts []string
+	for _, target := range *r {
+		parts = append(parts, target.Format+"="+target.Path)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set implements flag.Value
+func (r *reportTargets) Set(value string) error {
+	format, path, ok := strings.Cut(value, "=")
+	if !ok || path == "" {
+		return fmt.Errorf("want format=path, got %q", value)
+	}
+	format = strings.ToLower(strings.TrimSpace(format))
+	if !slices.Contains(reportFormatNames, format) {
+		return fmt.Errorf("unknown report format %q (want one of %s)", format, strings.Join(reportFormatNames, ", "))
+	}
+	*r = append(*r, reportTarget{Format: format, Path: path})
+	return nil
+}
+
+// Reports written after every link test run, and how their suites are grouped
+var (
+	reports     reportTargets
+	reportGroup = groupByAgent
+)
+
+// Report is the serialized form of a link test run
+type Report struct {
+	TestFile string        `json:"test_file"`
+	Backend  string        `json:"backend"`
+	Started  time.Time     `json:"started"`
+	Duration float64       `json:"duration_ms"`
+	Summary  ReportSummary `json:"summary"`
+	Suites   []ReportSuite `json:"suites"`
+
+	results []LinkTestResult // kept for the HTML report
+}
+
+// ReportSummary counts the test cases of a report
+type ReportSummary struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Errors  int `json:"errors"`
+	Skipped int `json:"skipped"`
+}
+
+// ReportSuite is a group of test cases sharing an agent or country
+type ReportSuite struct {
+	Name    string        `json:"name"`
+	Summary ReportSummary `json:"summary"`
+	Cases   []ReportCase  `json:"cases"`
+}
+
+// ReportCase is a single test case and its outcome
+type ReportCase struct {
+	Name             string   `json:"name"`
+	File             string   `json:"file"`
+	Line             int      `json:"line,omitempty"`
+	Agent            string   `json:"agent"`
+	Country          string   `json:"country"`
+	Method           string   `json:"method"`
+	URL              string   `json:"url"`
+	ExpectedStatus   int      `json:"expected_status"`
+	ExpectedLocation string   `json:"expected_location"`
+	Status           int      `json:"status"`
+	Location         string   `json:"location"`
+	Chain            []string `json:"chain,omitempty"`
+	Outcome          string   `json:"outcome"` // pass, fail, error or skipped
+	Reason           string   `json:"reason,omitempty"`
+	Duration         float64  `json:"duration_ms"`
+}
+
+// newReport builds a report from the tests of a run and the results of the
+// ones that ran; tests without a result are reported as skipped
+func newReport(testFile string, tests []LinkTest, results []LinkTestResult, started time.Time) Report {
+	report := Report{
+		TestFile: testFile,
+		Backend:  backendName(),
+		Started:  started,
+		Duration: milliseconds(time.Since(started)),
+		results:  results,
+	}
+
+	index := map[string]int{}
+	for i, test := range tests {
+		c := ReportCase{
+			Name:             reportCaseName(test),
+			File:             testFile,
+			Line:             test.Line,
+			Agent:            test.Agent,
+			Country:          test.Country,
+			Method:           linkTestMethod(test),
+			URL:              linkTestURL(test),
+			ExpectedStatus:   test.ExpectedStatus,
+			ExpectedLocation: test.ExpectedResult,
+			Outcome:          "skipped",
+		}
+		if i < len(results) {
+			result := results[i]
+			c.Status = result.Status
+			c.Location = result.Result
+			c.Reason = result.Reason
+			c.Duration = milliseconds(result.Duration)
+			c.Outcome = strings.ToLower(ciResultLabel(result))
+			for _, hop := range result.Chain {
+				c.Chain = append(c.Chain, fmt.Sprintf("%d %s", hop.Status, hop.URL))
+			}
+		}
+
+		name := reportSuiteName(test)
+		if _, ok := index[name]; !ok {
+			index[name] = len(report.Suites)
+			report.Suites = append(report.Suites, ReportSuite{Name: name})
+		}
+		suite := &report.Suites[index[name]]
+		suite.Cases = append(suite.Cases, c)
+		suite.Summary.add(c.Outcome)
+		report.Summary.add(c.Outcome)
+	}
+	return report
+}
+
+// add counts a case outcome
+func (s *ReportSummary) add(outcome string) {
+	s.Total++
+	switch outcome {
+	case "pass":
+		s.Passed++
+	case "fail":
+		s.Failed++
+	case "error":
+		s.Errors++
+	default:
+		s.Skipped++
+	}
+}
+
+// reportCaseName names a test case after its name or its request
+func reportCaseName(test LinkTest) string {
+	if test.Name != "" {
+		return test.Name
+	}
+	name := linkTestMethod(test) + " " + linkTestURL(test)
+	if test.Country != "" {
+		name += " [" + strings.ToUpper(test.Country) + "]"
+	}
+	return name
+}
+
+// reportSuiteName returns the suite a test case belongs to under reportGroup
+func reportSuiteName(test LinkTest) string {
+	if reportGroup == groupByCountry {
+		if test.Country == "" {
+			return "No country"
+		}
+		return strings.ToUpper(test.Country)
+	}
+	if test.Agent == "" {
+		return "Default agent"
+	}
+	return test.Agent
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// writeReports writes every -report target for a run
+func writeReports(testFile string, tests []LinkTest, results []LinkTestResult, started time.Time) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	report := newReport(testFile, tests, results, started)
+	for _, target := range reports {
+		if err := report.writeFile(target); err != nil {
+			return fmt.Errorf("writing %s report %s: %w", target.Format, target.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeFile writes the report in the target format
+func (r Report) writeFile(target reportTarget) error {
+	return writeOutputFile(target.Path, func(w io.Writer) error {
+		return r.write(w, target.Format)
+	})
+}
+
+// writeOutputFile creates path and writes to it, or to stdout for "-"
+func writeOutputFile(path string, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// write renders the report in a format
+func (r Report) write(w io.Writer, format string) error {
+	switch format {
+	case reportJUnit:
+		return r.writeJUnit(w)
+	case reportTAP:
+		return r.writeTAP(w)
+	case reportJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case reportMarkdown:
+		return r.writeMarkdown(w)
+	case reportHTML:
+		return newHTMLReport(nil, r.TestFile, r.results).writeHTML(w)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+// JUnit XML elements, as understood by the common CI test report parsers
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// seconds formats milliseconds as JUnit seconds
+func seconds(ms float64) string {
+	return fmt.Sprintf("%.3f", ms/1000)
+}
+
+// writeJUnit renders the report as JUnit XML
+func (r Report) writeJUnit(w io.Writer) error {
+	doc := junitTestSuites{
+		Name:     r.TestFile,
+		Tests:    r.Summary.Total,
+		Failures: r.Summary.Failed,
+		Errors:   r.Summary.Errors,
+		Skipped:  r.Summary.Skipped,
+		Time:     seconds(r.Duration),
+	}
+	for _, suite := range r.Suites {
+		js := junitTestSuite{
+			Name:      suite.Name,
+			Tests:     suite.Summary.Total,
+			Failures:  suite.Summary.Failed,
+			Errors:    suite.Summary.Errors,
+			Skipped:   suite.Summary.Skipped,
+			Timestamp: r.Started.Format(time.RFC3339),
+			Properties: []junitProperty{
+				{Name: "backend", Value: r.Backend},
+				{Name: "group", Value: reportGroup},
+			},
+		}
+		var total float64
+		for _, c := range suite.Cases {
+			total += c.Duration
+			jc := junitTestCase{
+				Name:      c.Name,
+				ClassName: suite.Name,
+				File:      c.File,
+				Line:      c.Line,
+				Time:      seconds(c.Duration),
+			}
+			switch c.Outcome {
+			case "pass":
+				jc.SystemOut = c.details()
+			case "fail":
+				jc.Failure = &junitMessage{Message: c.Reason, Text: c.details()}
+			case "error":
+				jc.Error = &junitMessage{Message: "request failed: " + c.Location, Text: c.details()}
+			case "skipped":
+				jc.Skipped = &junitMessage{Message: "not run (stopped early)"}
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+		js.Time = seconds(total)
+		doc.Suites = append(doc.Suites, js)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// details describes the request and the expected and actual responses
+func (c ReportCase) details() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "agent: %s\ncountry: %s\nrequest: %s %s\n", c.Agent, c.Country, c.Method, c.URL)
+	fmt.Fprintf(&b, "expected: %d %s\n", c.ExpectedStatus, c.ExpectedLocation)
+	if c.Outcome != "skipped" {
+		fmt.Fprintf(&b, "actual: %d %s\n", c.Status, c.Location)
+	}
+	if len(c.Chain) > 0 {
+		fmt.Fprintf(&b, "chain: %s\n", strings.Join(c.Chain, " -> "))
+	}
+	if c.Reason != "" {
+		fmt.Fprintf(&b, "reason: %s\n", c.Reason)
+	}
+	return b.String()
+}
+
+// writeTAP renders the report as TAP version 13 with a YAML block per
+// failing test
+func (r Report) writeTAP(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", r.Summary.Total)
+	n := 0
+	for _, suite := range r.Suites {
+		fmt.Fprintf(&b, "# %s\n", suite.Name)
+		for _, c := range suite.Cases {
+			n++
+			switch c.Outcome {
+			case "pass":
+				fmt.Fprintf(&b, "ok %d - %s\n", n, c.Name)
+			case "skipped":
+				fmt.Fprintf(&b, "ok %d - %s # SKIP not run (stopped early)\n", n, c.Name)
+			default:
+				fmt.Fprintf(&b, "not ok %d - %s\n", n, c.Name)
+				b.WriteString("  ---\n")
+				fmt.Fprintf(&b, "  message: %s\n", tapQuote(c.Reason))
+				fmt.Fprintf(&b, "  severity: %s\n", c.Outcome)
+				fmt.Fprintf(&b, "  agent: %s\n", tapQuote(c.Agent))
+				fmt.Fprintf(&b, "  country: %s\n", tapQuote(c.Country))
+				fmt.Fprintf(&b, "  url: %s\n", tapQuote(c.URL))
+				fmt.Fprintf(&b, "  expected: %s\n", tapQuote(fmt.Sprintf("%d %s", c.ExpectedStatus, c.ExpectedLocation)))
+				fmt.Fprintf(&b, "  actual: %s\n", tapQuote(fmt.Sprintf("%d %s", c.Status, c.Location)))
+				fmt.Fprintf(&b, "  duration_ms: %.3f\n", c.Duration)
+				if c.Line > 0 {
+					fmt.Fprintf(&b, "  at: %s\n", tapQuote(fmt.Sprintf("%s:%d", c.File, c.Line)))
+				}
+				b.WriteString("  ...\n")
+			}
+		}
+	}
+	fmt.Fprintf(&b, "# pass %d\n# fail %d\n", r.Summary.Passed, r.Summary.Failed+r.Summary.Errors)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tapQuote quotes a value for the YAML block of a TAP test
+func tapQuote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// writeMarkdown renders the report as Markdown tables, one per suite
+func (r Report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Link Test Report\n\n")
+	fmt.Fprintf(&b, "- Test file: `%s`\n", r.TestFile)
+	fmt.Fprintf(&b, "- Backend: %s\n", r.Backend)
+	fmt.Fprintf(&b, "- Started: %s\n", r.Started.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Result: %s\n", r.Summary.markdown())
+
+	for _, suite := range r.Suites {
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n\n", suite.Name, suite.Summary.markdown())
+		b.WriteString("| | Agent | Country | URL | Expected | Actual | Duration | Reason |\n")
+		b.WriteString("|---|---|---|---|---|---|---|---|\n")
+		for _, c := range suite.Cases {
+			icon := map[string]string{"pass": "✅", "fail": "❌", "error": "💥", "skipped": "⏭️"}[c.Outcome]
+			actual := ""
+			if c.Outcome != "skipped" {
+				actual = fmt.Sprintf("%d %s", c.Status, c.Location)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s %s | %d %s | %s | %.1f ms | %s |\n",
+				icon, markdownCell(c.Agent), markdownCell(countryLabel(c.Country)),
+				c.Method, markdownCell(c.URL),
+				c.ExpectedStatus, markdownCell(c.ExpectedLocation),
+				markdownCell(actual), c.Duration, markdownCell(c.Reason))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdown summarizes the counts on one line
+func (s ReportSummary) markdown() string {
+	text := fmt.Sprintf("%d passed, %d failed, %d errors", s.Passed, s.Failed, s.Errors)
+	if s.Skipped > 0 {
+		text += fmt.Sprintf(", %d skipped", s.Skipped)
+	}
+	return text
+}
+
+// markdownCell escapes a value for a Markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reportFixture returns three tests of which the first passed, the second
+// failed and the third did not run
+func reportFixture() ([]LinkTest, []LinkTestResult) {
+	tests := []LinkTest{
+		{Line: 2, Agent: "Browser", Country: "US", URL: "http://example.com/", ExpectedStatus: 200, ExpectedResult: "No redirect"},
+		{Line: 3, Name: "German home", Agent: "Googlebot", Country: "DE", URL: "http://example.com/", ExpectedStatus: 302, ExpectedResult: "/de/"},
+		{Line: 4, Agent: "Browser", Country: "DE", URL: "http://example.com/a|b", ExpectedStatus: 200, ExpectedResult: "No redirect"},
+	}
+	results := []LinkTestResult{
+		{Test: tests[0], Status: 200, Result: "No redirect", Success: true, Duration: 1500 * time.Microsecond},
+		{Test: tests[1], Status: 302, Result: "/uk/", Reason: `contains: Location "/uk/" does not contain "/de/"`, Duration: 2 * time.Millisecond},
+	}
+	return tests, results
+}
+
+// TestReportTargetsSet tests parsing of -report flags
+func TestReportTargetsSet(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    reportTarget
+		wantErr bool
+	}{
+		{"junit=out.xml", reportTarget{reportJUnit, "out.xml"}, false},
+		{"Markdown=-", reportTarget{reportMarkdown, "-"}, false},
+		{"json=a=b.json", reportTarget{reportJSON, "a=b.json"}, false},
+		{"junit", reportTarget{}, true},
+		{"junit=", reportTarget{}, true},
+		{"html=out.html", reportTarget{reportHTML, "out.html"}, false},
+		{"xml=out.xml", reportTarget{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var targets reportTargets
+			err := targets.Set(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && targets[0] != tt.want {
+				t.Errorf("Set(%q) = %+v, want %+v", tt.value, targets[0], tt.want)
+			}
+		})
+	}
+}
+
+// TestNewReport tests case outcomes and suite grouping
+func TestNewReport(t *testing.T) {
+	tests, results := reportFixture()
+
+	groups := []struct {
+		group  string
+		suites []string
+	}{
+		{groupByAgent, []string{"Browser", "Googlebot"}},
+		{groupByCountry, []string{"US", "DE"}},
+	}
+
+	for _, g := range groups {
+		t.Run(g.group, func(t *testing.T) {
+			reportGroup = g.group
+			defer func() { reportGroup = groupByAgent }()
+
+			report := newReport("links.testing", tests, results, time.Now())
+			var names []string
+			for _, suite := range report.Suites {
+				names = append(names, suite.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(g.suites, ",") {
+				t.Errorf("suites = %v, want %v", names, g.suites)
+			}
+			want := ReportSummary{Total: 3, Passed: 1, Failed: 1, Skipped: 1}
+			if report.Summary != want {
+				t.Errorf("summary = %+v, want %+v", report.Summary, want)
+			}
+		})
+	}
+
+	report := newReport("links.testing", tests, results, time.Now())
+	c := report.Suites[1].Cases[0]
+	if c.Name != "German home" || c.Outcome != "fail" || c.Status != 302 || c.Location != "/uk/" || c.ExpectedLocation != "/de/" || c.Duration != 2 {
+		t.Errorf("failing case = %+v", c)
+	}
+	if c := report.Suites[0].Cases[0]; c.Name != "GET http://example.com/ [US]" || c.Duration != 1.5 {
+		t.Errorf("passing case = %+v", c)
+	}
+}
+
+// TestReportFormats tests the JUnit, TAP, JSON and Markdown renderings
+func TestReportFormats(t *testing.T) {
+	tests, results := reportFixture()
+	report := newReport("links.testing", tests, results, time.Now())
+
+	t.Run("junit", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := report.write(&out, reportJUnit); err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+		var doc junitTestSuites
+		if err := xml.Unmarshal(out.Bytes(), &doc); err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, out.String())
+		}
+		if doc.Tests != 3 || doc.Failures != 1 || doc.Skipped != 1 || len(doc.Suites) != 2 {
+			t.Errorf("testsuites = %+v", doc)
+		}
+		failed := doc.Suites[1].Cases[0]
+		if failed.Failure == nil || !strings.Contains(failed.Failure.Message, "does not contain") || failed.Line != 3 || failed.Time != "0.002" {
+			t.Errorf("failing testcase = %+v", failed)
+		}
+		if doc.Suites[0].Cases[1].Skipped == nil {
+			t.Errorf("not run testcase is not skipped: %+v", doc.Suites[0].Cases[1])
+		}
+	})
+
+	t.Run("tap", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := report.write(&out, reportTAP); err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+		for _, want := range []string{
+			"TAP version 13\n1..3\n",
+			"ok 1 - GET http://example.com/ [US]\n",
+			"ok 2 - GET http://example.com/a|b [DE] # SKIP",
+			"not ok 3 - German home\n  ---\n",
+			`  message: "contains: Location \"/uk/\" does not contain \"/de/\""`,
+			`  at: "links.testing:3"`,
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("TAP output missing %q:\n%s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := report.write(&out, reportJSON); err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+		var decoded Report
+		if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if decoded.Summary != report.Summary || decoded.Suites[1].Cases[0].Reason != results[1].Reason {
+			t.Errorf("decoded report = %+v", decoded)
+		}
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := report.write(&out, reportMarkdown); err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+		for _, want := range []string{"## Browser", "## Googlebot", `a\|b`, "| ❌ | Googlebot | 🇩🇪 DE |", "1 passed, 1 failed, 0 errors, 1 skipped"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Markdown output missing %q:\n%s", want, out.String())
+			}
+		}
+	})
+}
+
+// TestRunCIReports tests that CI mode writes every requested report
+func TestRunCIReports(t *testing.T) {
+	server := newCIServer(t)
+	path := writeTestFile(t, "links.testing", "Agent,Country,URL,Expected status,Expected result\nBrowser,US,"+server.URL+"/,200,No redirect\n")
+	dir := t.TempDir()
+
+	reports = reportTargets{
+		{reportJUnit, filepath.Join(dir, "report.xml")},
+		{reportTAP, filepath.Join(dir, "report.tap")},
+	}
+	defer func() { reports = nil }()
+
+	var out bytes.Buffer
+	if code := runCI(&out, path, ciOptions{}); code != exitPass {
+		t.Fatalf("runCI() = %d, want %d\n%s", code, exitPass, out.String())
+	}
+	for _, target := range reports {
+		data, err := os.ReadFile(target.Path)
+		if err != nil {
+			t.Fatalf("report %s not written: %v", target.Format, err)
+		}
+		if !strings.Contains(string(data), "GET "+server.URL+"/ [US]") {
+			t.Errorf("%s report does not contain the test case:\n%s", target.Format, data)
+		}
+	}
+
+	reports = reportTargets{{reportJSON, filepath.Join(dir, "missing", "report.json")}}
+	if code := runCI(&out, path, ciOptions{}); code != exitError {
+		t.Errorf("runCI() with unwritable report = %d, want %d", code, exitError)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// reverseDNSTimeout bounds the lookups behind one reverse-DNS check
+const reverseDNSTimeout = 3 * time.Second
+
+// hostResolver is the part of *net.Resolver the reverse-DNS checks use, so
+// that a stub can answer them without network
+type hostResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// reverseDNS resolves %{REMOTE_HOST} for the offline backend and verifies
+// crawler addresses; nil disables lookups, as HostnameLookups Off does
+var reverseDNS hostResolver
+
+// hostsResolver is a stub resolver answering from a hosts file
+type hostsResolver struct {
+	names map[string][]string // IP → host names
+	addrs map[string][]string // host name → IPs
+}
+
+// parseHostsResolver reads "IP name [name ...]" lines; the first name of an
+// address is its reverse (PTR) name
+func parseHostsResolver(r io.Reader) (*hostsResolver, error) {
+	resolver := &hostsResolver{names: map[string][]string{}, addrs: map[string][]string{}}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil || len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: want IP NAME..., got %q", line, strings.TrimSpace(text))
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			resolver.names[ip.String()] = append(resolver.names[ip.String()], name+".")
+			resolver.addrs[name] = append(resolver.addrs[name], ip.String())
+		}
+	}
+	return resolver, scanner.Err()
+}
+
+// LookupAddr implements hostResolver
+func (h *hostsResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	if ip := net.ParseIP(addr); ip != nil {
+		if names, ok := h.names[ip.String()]; ok {
+			return names, nil
+		}
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+}
+
+// LookupHost implements hostResolver
+func (h *hostsResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addrs, ok := h.addrs[strings.ToLower(strings.TrimSuffix(host, "."))]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// openReverseDNS returns the resolver for -rdns: "system" uses DNS, any
+// other value is read as a hosts file
+func openReverseDNS(value string) (hostResolver, error) {
+	if value == "system" {
+		return net.DefaultResolver, nil
+	}
+	file, err := os.Open(value)
+	if err != nil {
+		return nil, fmt.Errorf("error reading -rdns hosts file: %w", err)
+	}
+	defer file.Close()
+	resolver, err := parseHostsResolver(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", value, err)
+	}
+	return resolver, nil
+}
+
+// confirmedHost returns the reverse DNS name of ip when that name resolves
+// back to ip (forward-confirmed reverse DNS), as HostnameLookups Double does
+func confirmedHost(ctx context.Context, resolver hostResolver, ip string) string {
+	ctx, cancel := context.WithTimeout(ctx, reverseDNSTimeout)
+	defer cancel()
+	names, err := resolver.LookupAddr(ctx, ip)
+	if err != nil {
+		return ""
+	}
+	for _, name := range names {
+		addrs, err := resolver.LookupHost(ctx, name)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if other := net.ParseIP(addr); other != nil && other.Equal(net.ParseIP(ip)) {
+				return strings.TrimSuffix(name, ".")
+			}
+		}
+	}
+	return ""
+}
+
+// crawlerHost returns the confirmed host name of ip and whether it lies in
+// one of the crawler's domains, the check search engines document for
+// verifying their bots
+func crawlerHost(ctx context.Context, resolver hostResolver, ip string, domains []string) (string, bool) {
+	host := confirmedHost(ctx, resolver, ip)
+	if host == "" {
+		return "", false
+	}
+	for _, domain := range domains {
+		if strings.EqualFold(host, domain) || strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(domain)) {
+			return host, true
+		}
+	}
+	return host, false
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// testHosts answers like real DNS for a Googlebot address, a lookalike
+// domain and a host outside the crawler domains
+const testHosts = `# stub resolver
+66.249.66.1    crawl-66-249-66-1.googlebot.com
+203.0.113.7    crawl-203-0-113-7.googlebot.com.evil.example
+192.0.2.1      fake.googlebot.com
+2001:db8::5    www.example.com
+`
+
+// useReverseDNS points the reverse-DNS checks at a stub resolver for a test;
+// 198.51.100.4 gets a PTR record claiming fake.googlebot.com, which
+// resolves to another address, as anyone controlling their PTR zone can do
+func useReverseDNS(t *testing.T, hosts string) *hostsResolver {
+	t.Helper()
+	resolver, err := parseHostsResolver(strings.NewReader(hosts))
+	if err != nil {
+		t.Fatalf("parseHostsResolver() error = %v", err)
+	}
+	resolver.names["198.51.100.4"] = []string{"fake.googlebot.com."}
+	old := reverseDNS
+	reverseDNS = resolver
+	t.Cleanup(func() { reverseDNS = old })
+	return resolver
+}
+
+// TestParseHostsResolver tests the hosts file format
+func TestParseHostsResolver(t *testing.T) {
+	resolver := useReverseDNS(t, testHosts)
+	names, err := resolver.LookupAddr(context.Background(), "2001:0db8::5")
+	if err != nil || len(names) != 1 || names[0] != "www.example.com." {
+		t.Errorf("LookupAddr(2001:0db8::5) = %v, %v", names, err)
+	}
+	addrs, err := resolver.LookupHost(context.Background(), "FAKE.googlebot.com.")
+	if err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.1" {
+		t.Errorf("LookupHost(fake.googlebot.com) = %v, %v", addrs, err)
+	}
+	if _, err := resolver.LookupAddr(context.Background(), "10.0.0.1"); err == nil {
+		t.Error("LookupAddr() of an unknown address expected error")
+	}
+
+	for _, invalid := range []string{"66.249.66.1\n", "not-an-ip host\n"} {
+		if _, err := parseHostsResolver(strings.NewReader(invalid)); err == nil {
+			t.Errorf("parseHostsResolver(%q) expected error", invalid)
+		}
+	}
+}
+
+// TestCrawlerHost tests forward-confirmed reverse DNS and the domain check
+func TestCrawlerHost(t *testing.T) {
+	resolver := useReverseDNS(t, testHosts)
+	tests := []struct {
+		name     string
+		ip       string
+		wantHost string
+		wantOK   bool
+	}{
+		{"genuine crawler", "66.249.66.1", "crawl-66-249-66-1.googlebot.com", true},
+		{"lookalike domain", "203.0.113.7", "crawl-203-0-113-7.googlebot.com.evil.example", false},
+		{"PTR not resolving back", "198.51.100.4", "", false},
+		{"other domain", "2001:db8::5", "www.example.com", false},
+		{"no PTR record", "10.0.0.1", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, ok := crawlerHost(context.Background(), resolver, tt.ip, googleDomains)
+			if host != tt.wantHost || ok != tt.wantOK {
+				t.Errorf("crawlerHost(%s) = %q, %v, want %q, %v", tt.ip, host, ok, tt.wantHost, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestRemoteHostOffline tests that the offline engine sees the client
+// address of X-Forwarded-For and its confirmed host name in REMOTE_HOST
+func TestRemoteHostOffline(t *testing.T) {
+	backend := newRewriteBackend(writeTestFile(t, ".htaccess", `RewriteEngine On
+RewriteCond %{REMOTE_HOST} \.googlebot\.com$
+RewriteRule ^$ /crawler/ [R=302,L]
+RewriteCond %{REMOTE_ADDR} ^203\.0\.113\.
+RewriteRule ^$ /documentation/ [R=302,L]
+`), offlineDocRoot)
+
+	request := func(ip string) string {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		resp, err := backend.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		return resp.Header.Get("Location")
+	}
+
+	if got := request("66.249.66.1"); got != "" {
+		t.Errorf("without -rdns REMOTE_HOST matched: Location = %q", got)
+	}
+	if got := request("203.0.113.7"); !strings.HasSuffix(got, "/documentation/") {
+		t.Errorf("REMOTE_ADDR does not follow X-Forwarded-For: Location = %q", got)
+	}
+
+	useReverseDNS(t, testHosts)
+	if got := request("66.249.66.1"); !strings.HasSuffix(got, "/crawler/") {
+		t.Errorf("genuine crawler: Location = %q, want /crawler/", got)
+	}
+	if got := request("198.51.100.4"); got != "" {
+		t.Errorf("unconfirmed PTR record: Location = %q, want none", got)
+	}
+}
@@ -0,0 +1,1196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// geoCountryEnv is the environment variable the GeoIP module (or the Docker
+// vhost mock) exposes to the .htaccess rules
+const geoCountryEnv = "GEOIP_COUNTRY_CODE"
+
+// maxInternalRedirects mirrors Apache's LimitInternalRecursion default
+const maxInternalRedirects = 10
+
+// enabledModules lists the modules loaded by the Docker test image, used to
+// decide which <IfModule> sections are active
+var enabledModules = map[string]bool{
+	"mod_rewrite.c":  true,
+	"rewrite_module": true,
+	"mod_headers.c":  true,
+	"headers_module": true,
+	"mod_env.c":      true,
+	"env_module":     true,
+	"mod_setenvif.c": true,
+	"mod_dir.c":      true,
+	"dir_module":     true,
+	"mod_mime.c":     true,
+	"mod_php.c":      true,
+	"mod_php7.c":     true,
+	"mod_expires.c":  false,
+	"LiteSpeed":      false,
+}
+
+// RewriteCond represents a parsed RewriteCond directive
+type RewriteCond struct {
+	Line       int
+	TestString string
+	Pattern    string
+	Negate     bool
+	NoCase     bool
+	OrNext     bool
+	Err        error
+	re         *regexp.Regexp
+}
+
+// RuleFlags represents the [flags] of a RewriteRule
+type RuleFlags struct {
+	Raw       string
+	Last      bool
+	End       bool
+	NoCase    bool
+	Redirect  int
+	QSA       bool
+	QSD       bool
+	Forbidden bool
+	Gone      bool
+	Chain     bool
+	Skip      int
+	Env       []string
+	Unknown   []string
+}
+
+// RewriteRule represents a parsed RewriteRule and the conditions guarding it
+type RewriteRule struct {
+	Line         int
+	Pattern      string
+	Negate       bool
+	Substitution string
+	Flags        RuleFlags
+	Conds        []RewriteCond
+	Disabled     bool // inside an <IfModule> for a module that is not loaded
+	Err          error
+	re           *regexp.Regexp
+}
+
+// ParseProblem represents a syntax error found while parsing a .htaccess file
+type ParseProblem struct {
+	Line     int
+	Message  string
+	Disabled bool
+}
+
+// RuleSet represents a parsed .htaccess file
+type RuleSet struct {
+	Path     string
+	Dir      string // URL directory the file applies to
+	Engine   bool
+	Base     string
+	Rules    []RewriteRule
+	Problems []ParseProblem
+}
+
+// RewriteRequest describes the request being evaluated against a RuleSet
+type RewriteRequest struct {
+	Method     string
+	Scheme     string
+	Host       string
+	Path       string
+	Query      string
+	Header     http.Header
+	RemoteAddr string
+	RemoteHost string // confirmed reverse DNS name, empty without lookups
+	Env        map[string]string
+}
+
+// RewriteResult represents the outcome of evaluating a RuleSet
+type RewriteResult struct {
+	Status   int
+	Location string
+	URI      string
+	Query    string
+	Env      map[string]string
+}
+
+// DocRoot answers the filesystem questions asked by -f/-d conditions and by
+// the final response lookup
+type DocRoot interface {
+	Root() string
+	Stat(urlPath string) (exists, isDir bool)
+}
+
+// dirDocRoot is a DocRoot backed by a real directory
+type dirDocRoot string
+
+func (d dirDocRoot) Root() string {
+	return string(d)
+}
+
+func (d dirDocRoot) Stat(urlPath string) (bool, bool) {
+	info, err := os.Stat(filepath.Join(string(d), filepath.FromSlash(urlPath)))
+	if err != nil {
+		return false, false
+	}
+	return true, info.IsDir()
+}
+
+// virtualDocRoot is an in-memory DocRoot listing directories and files
+type virtualDocRoot struct {
+	root  string
+	dirs  map[string]bool
+	files map[string]bool
+}
+
+func (v virtualDocRoot) Root() string {
+	return v.root
+}
+
+func (v virtualDocRoot) Stat(urlPath string) (bool, bool) {
+	p := path.Clean("/" + urlPath)
+	if v.dirs[p] {
+		return true, true
+	}
+	return v.files[p], false
+}
+
+// newVirtualDocRoot creates a virtual DocRoot from a list of URL paths;
+// entries ending in "/" are directories
+func newVirtualDocRoot(root string, entries []string) virtualDocRoot {
+	v := virtualDocRoot{root: root, dirs: map[string]bool{"/": true}, files: map[string]bool{}}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, "/") {
+			v.dirs[path.Clean(entry)] = true
+		} else {
+			v.files[path.Clean(entry)] = true
+		}
+	}
+	return v
+}
+
+// dockerDocRoot mirrors the document root built by apps/docker-setup/Dockerfile
+var dockerDocRoot = newVirtualDocRoot("/var/www/html", []string{
+	"/uk/", "/de/", "/fr/", "/au/", "/at/", "/ca/", "/ie/", "/it/", "/ch/", "/es/", "/lu/", "/li/",
+	"/ch-fr/", "/ch-it/", "/ca-fr/", "/wp-admin/", "/wp-content/", "/wp-includes/", "/wp-json/",
+	"/index.php", "/index.html", "/test-content.html", "/geoip-mock.php",
+	"/uk/index.html", "/de/index.html", "/fr/index.html", "/au/index.html", "/at/index.html",
+	"/ca/index.html", "/ie/index.html", "/it/index.html", "/ch/index.html", "/es/index.html",
+	"/uk/test-content.html", "/de/test-content.html", "/fr/test-content.html", "/au/test-content.html",
+	"/at/test-content.html", "/ca/test-content.html", "/ie/test-content.html", "/it/test-content.html",
+	"/ch/test-content.html", "/es/test-content.html",
+	"/wp-admin/index.php", "/wp-json/index.php", "/robots.txt", "/sitemap_index.xml",
+})
+
+// parseHtaccessFile reads and parses the rewrite directives of a .htaccess file
+func parseHtaccessFile(filename string) (*RuleSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	rs := parseHtaccess(bytes.NewReader(data))
+	rs.Path = filename
+	return rs, nil
+}
+
+// parseHtaccess parses rewrite directives; unrelated directives are ignored
+func parseHtaccess(r io.Reader) *RuleSet {
+	rs := &RuleSet{Dir: "/"}
+	var pending []RewriteCond
+	var sections []bool // active state of each open <IfModule>
+
+	active := func() bool {
+		for _, on := range sections {
+			if !on {
+				return false
+			}
+		}
+		return true
+	}
+	problem := func(line int, format string, args ...any) {
+		rs.Problems = append(rs.Problems, ParseProblem{Line: line, Message: fmt.Sprintf(format, args...), Disabled: !active()})
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNo, startLine := 0, 0
+	var continued string
+	for scanner.Scan() {
+		lineNo++
+		text := strings.TrimSpace(scanner.Text())
+		if continued == "" {
+			startLine = lineNo
+		}
+		if strings.HasSuffix(text, "\\") {
+			continued += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		text = continued + text
+		continued = ""
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "</") {
+			if len(sections) > 0 {
+				sections = sections[:len(sections)-1]
+			}
+			continue
+		}
+		if strings.HasPrefix(text, "<") {
+			fields := splitDirectiveArgs(strings.Trim(text, "<>"))
+			on := true
+			if len(fields) > 0 && strings.EqualFold(fields[0], "IfModule") && len(fields) > 1 {
+				module := fields[1]
+				negate := strings.HasPrefix(module, "!")
+				on = enabledModules[strings.TrimPrefix(module, "!")] != negate
+			}
+			sections = append(sections, on)
+			continue
+		}
+
+		args := splitDirectiveArgs(text)
+		directive, args := strings.ToLower(args[0]), args[1:]
+		switch directive {
+		case "rewriteengine":
+			if len(args) != 1 {
+				problem(startLine, "RewriteEngine takes one argument")
+				continue
+			}
+			if active() {
+				rs.Engine = strings.EqualFold(args[0], "on")
+			}
+		case "rewritebase":
+			if len(args) != 1 || !strings.HasPrefix(args[0], "/") {
+				problem(startLine, "RewriteBase takes one absolute URL path")
+				continue
+			}
+			if active() {
+				rs.Base = args[0]
+			}
+		case "rewritecond":
+			cond, err := parseRewriteCond(startLine, args)
+			if err != nil {
+				problem(startLine, "%v", err)
+				continue
+			}
+			pending = append(pending, cond)
+		case "rewriterule":
+			rule, err := parseRewriteRule(startLine, args)
+			if err != nil {
+				problem(startLine, "%v", err)
+				pending = nil
+				continue
+			}
+			rule.Conds = pending
+			rule.Disabled = !active()
+			pending = nil
+			rs.Rules = append(rs.Rules, rule)
+		}
+	}
+
+	if len(pending) > 0 {
+		problem(pending[0].Line, "RewriteCond without a following RewriteRule")
+	}
+	return rs
+}
+
+// splitDirectiveArgs splits a config line into words, honouring double quotes
+func splitDirectiveArgs(line string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes, hasWord := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && inQuotes && i+1 < len(line) && line[i+1] == '"':
+			current.WriteByte('"')
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+			hasWord = true
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if hasWord {
+				args = append(args, current.String())
+				current.Reset()
+				hasWord = false
+			}
+		default:
+			current.WriteByte(c)
+			hasWord = true
+		}
+	}
+	if hasWord {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// parseRewriteCond builds a RewriteCond from its directive arguments
+func parseRewriteCond(line int, args []string) (RewriteCond, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return RewriteCond{}, fmt.Errorf("RewriteCond takes 2 or 3 arguments, got %d", len(args))
+	}
+	cond := RewriteCond{Line: line, TestString: args[0], Pattern: args[1]}
+	if len(args) == 3 {
+		for _, flag := range strings.Split(strings.Trim(args[2], "[]"), ",") {
+			switch strings.ToLower(strings.TrimSpace(flag)) {
+			case "nc", "nocase":
+				cond.NoCase = true
+			case "or", "ornext":
+				cond.OrNext = true
+			case "nv", "novary":
+			default:
+				return RewriteCond{}, fmt.Errorf("unknown RewriteCond flag %q", flag)
+			}
+		}
+	}
+
+	pattern := cond.Pattern
+	if strings.HasPrefix(pattern, "!") {
+		cond.Negate = true
+		pattern = pattern[1:]
+	}
+	if !isCondOperator(pattern) {
+		cond.re, cond.Err = compileApacheRegex(pattern, cond.NoCase)
+	}
+	return cond, nil
+}
+
+// isCondOperator reports whether a CondPattern is a file test or comparison
+// rather than a regular expression
+func isCondOperator(pattern string) bool {
+	switch pattern {
+	case "-d", "-f", "-F", "-s", "-l", "-L", "-h", "-U", "-x":
+		return true
+	}
+	for _, op := range []string{"-eq", "-ne", "-lt", "-le", "-gt", "-ge"} {
+		if strings.HasPrefix(pattern, op) {
+			return true
+		}
+	}
+	return strings.HasPrefix(pattern, "=") || strings.HasPrefix(pattern, "<") || strings.HasPrefix(pattern, ">")
+}
+
+// parseRewriteRule builds a RewriteRule from its directive arguments
+func parseRewriteRule(line int, args []string) (RewriteRule, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return RewriteRule{}, fmt.Errorf("RewriteRule takes 2 or 3 arguments, got %d", len(args))
+	}
+	rule := RewriteRule{Line: line, Pattern: args[0], Substitution: args[1]}
+	if len(args) == 3 {
+		flags, err := parseRuleFlags(args[2])
+		if err != nil {
+			return RewriteRule{}, err
+		}
+		rule.Flags = flags
+	}
+
+	pattern := rule.Pattern
+	if strings.HasPrefix(pattern, "!") {
+		rule.Negate = true
+		pattern = pattern[1:]
+	}
+	rule.re, rule.Err = compileApacheRegex(pattern, rule.Flags.NoCase)
+	return rule, nil
+}
+
+// parseRuleFlags parses a "[R=302,L,QSA]" flag list
+func parseRuleFlags(raw string) (RuleFlags, error) {
+	flags := RuleFlags{Raw: raw}
+	if !strings.HasPrefix(raw, "[") || !strings.HasSuffix(raw, "]") {
+		return flags, fmt.Errorf("RewriteRule flags must be enclosed in brackets: %s", raw)
+	}
+	for _, flag := range strings.Split(raw[1:len(raw)-1], ",") {
+		flag = strings.TrimSpace(flag)
+		key, value, _ := strings.Cut(flag, "=")
+		switch strings.ToLower(key) {
+		case "l", "last":
+			flags.Last = true
+		case "end":
+			flags.End = true
+		case "nc", "nocase":
+			flags.NoCase = true
+		case "r", "redirect":
+			code, err := parseRedirectCode(value)
+			if err != nil {
+				return flags, err
+			}
+			flags.Redirect = code
+		case "qsa", "qsappend":
+			flags.QSA = true
+		case "qsd", "qsdiscard":
+			flags.QSD = true
+		case "f", "forbidden":
+			flags.Forbidden = true
+		case "g", "gone":
+			flags.Gone = true
+		case "c", "chain":
+			flags.Chain = true
+		case "s", "skip":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return flags, fmt.Errorf("invalid skip count %q", value)
+			}
+			flags.Skip = n
+		case "e", "env":
+			if value == "" {
+				return flags, fmt.Errorf("E flag requires VAR[:VALUE]")
+			}
+			flags.Env = append(flags.Env, value)
+		case "ne", "noescape", "pt", "passthrough", "ns", "nosubreq", "t", "type", "co", "cookie",
+			"h", "handler", "dpi", "discardpath", "b", "bnp", "backrefnoplus", "p", "proxy", "qsl", "qslast", "unsafeallow3f":
+			// Accepted but not emulated
+		case "":
+		default:
+			flags.Unknown = append(flags.Unknown, flag)
+		}
+	}
+	if len(flags.Unknown) > 0 {
+		return flags, fmt.Errorf("unknown RewriteRule flag %q", flags.Unknown[0])
+	}
+	return flags, nil
+}
+
+// parseRedirectCode converts the value of an R flag into a status code
+func parseRedirectCode(value string) (int, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return 302, nil
+	case "permanent":
+		return 301, nil
+	case "temp":
+		return 302, nil
+	case "seeother":
+		return 303, nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 300 || code > 599 {
+		return 0, fmt.Errorf("invalid redirect status %q", value)
+	}
+	return code, nil
+}
+
+// compileApacheRegex compiles a PCRE-style pattern with Go's regexp engine
+func compileApacheRegex(pattern string, noCase bool) (*regexp.Regexp, error) {
+	if noCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// rewriteContext holds the per-request state while rules are applied
+type rewriteContext struct {
+	rs      *RuleSet
+	req     *RewriteRequest
+	docRoot DocRoot
+	uri     string
+	query   string
+	env     map[string]string
+	trace   *RewriteTrace
+}
+
+// RewriteTrace records every rule, condition and decision taken while
+// evaluating a request, for the -explain mode
+type RewriteTrace struct {
+	Rounds   []TraceRound
+	Terminal *TraceRule // rule that ended processing, nil if none did
+	Outcome  string
+	Result   RewriteResult
+}
+
+// TraceRound represents one pass over the rules; later rounds follow
+// internal redirects
+type TraceRound struct {
+	URI   string
+	Rules []TraceRule
+}
+
+// TraceRule records how a single RewriteRule was evaluated
+type TraceRule struct {
+	Rule     *RewriteRule
+	Subject  string
+	Matched  bool
+	Captures []string
+	Conds    []TraceCond
+	Skipped  string // reason the rule was not evaluated
+	Applied  bool
+	EnvSet   []string
+	Action   string
+}
+
+// TraceCond records how a single RewriteCond was evaluated
+type TraceCond struct {
+	Cond     *RewriteCond
+	Value    string
+	Matched  bool
+	Captures []string
+}
+
+// Evaluate computes the response Apache would give for the request
+func (rs *RuleSet) Evaluate(req *RewriteRequest, docRoot DocRoot) RewriteResult {
+	return rs.evaluate(req, docRoot, nil)
+}
+
+// EvaluateTrace is like Evaluate but also records a RewriteTrace
+func (rs *RuleSet) EvaluateTrace(req *RewriteRequest, docRoot DocRoot) (RewriteResult, *RewriteTrace) {
+	trace := &RewriteTrace{}
+	result := rs.evaluate(req, docRoot, trace)
+	trace.Result = result
+	return result, trace
+}
+
+func (rs *RuleSet) evaluate(req *RewriteRequest, docRoot DocRoot, trace *RewriteTrace) RewriteResult {
+	ctx := &rewriteContext{
+		rs:      rs,
+		req:     req,
+		docRoot: docRoot,
+		uri:     req.Path,
+		query:   req.Query,
+		env:     map[string]string{},
+		trace:   trace,
+	}
+	for k, v := range req.Env {
+		ctx.env[k] = v
+	}
+	if ctx.uri == "" {
+		ctx.uri = "/"
+	}
+
+	if rs.hasErrors() {
+		ctx.outcome("the file contains invalid rewrite directives")
+		return ctx.result(http.StatusInternalServerError, "")
+	}
+	if !rs.Engine {
+		ctx.outcome("RewriteEngine is off")
+	}
+
+	for round := 0; rs.Engine; round++ {
+		if round == maxInternalRedirects {
+			ctx.outcome(fmt.Sprintf("more than %d internal redirects", maxInternalRedirects))
+			return ctx.result(http.StatusInternalServerError, "")
+		}
+		if round > 0 {
+			ctx.internalRedirect()
+		}
+		if ctx.trace != nil {
+			ctx.trace.Rounds = append(ctx.trace.Rounds, TraceRound{URI: withQuery(ctx.uri, ctx.query)})
+			ctx.trace.Terminal = nil
+		}
+		prevURI, prevQuery := ctx.uri, ctx.query
+		status, location, end := ctx.applyRules()
+		if status != 0 {
+			return ctx.result(status, location)
+		}
+		if end || (ctx.uri == prevURI && ctx.query == prevQuery) {
+			break
+		}
+	}
+
+	exists, isDir := docRoot.Stat(ctx.uri)
+	switch {
+	case !exists:
+		ctx.outcome(ctx.uri + " does not exist in the document root")
+		return ctx.result(http.StatusNotFound, "")
+	case isDir && !strings.HasSuffix(ctx.uri, "/"):
+		ctx.outcome(ctx.uri + " is a directory (DirectorySlash)")
+		return ctx.result(http.StatusMovedPermanently, ctx.absoluteURL(ctx.uri+"/", ctx.query))
+	}
+	ctx.outcome("served " + ctx.uri)
+	return ctx.result(http.StatusOK, "")
+}
+
+// hasErrors reports whether Apache would reject the file with a 500
+func (rs *RuleSet) hasErrors() bool {
+	for _, p := range rs.Problems {
+		if !p.Disabled {
+			return true
+		}
+	}
+	for _, rule := range rs.Rules {
+		if rule.Disabled {
+			continue
+		}
+		if rule.Err != nil {
+			return true
+		}
+		for _, cond := range rule.Conds {
+			if cond.Err != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// internalRedirect copies the environment the way Apache does when it
+// re-injects a rewritten per-directory request
+func (ctx *rewriteContext) internalRedirect() {
+	env := map[string]string{"REDIRECT_STATUS": "200"}
+	for k, v := range ctx.env {
+		if !strings.HasPrefix(k, "REDIRECT_") {
+			env["REDIRECT_"+k] = v
+		}
+		env[k] = v
+	}
+	ctx.env = env
+}
+
+func (ctx *rewriteContext) result(status int, location string) RewriteResult {
+	return RewriteResult{Status: status, Location: location, URI: ctx.uri, Query: ctx.query, Env: ctx.env}
+}
+
+// outcome records the final decision when tracing
+func (ctx *rewriteContext) outcome(text string) {
+	if ctx.trace != nil && ctx.trace.Outcome == "" {
+		ctx.trace.Outcome = text
+	}
+}
+
+// record appends a rule step to the current trace round
+func (ctx *rewriteContext) record(step TraceRule, terminal bool) {
+	if ctx.trace == nil {
+		return
+	}
+	round := &ctx.trace.Rounds[len(ctx.trace.Rounds)-1]
+	round.Rules = append(round.Rules, step)
+	if terminal {
+		ctx.trace.Terminal = &round.Rules[len(round.Rules)-1]
+	}
+}
+
+// applyRules runs one pass over the rules; it returns a non-zero status when
+// processing produced a final response and end=true when [END] was hit
+func (ctx *rewriteContext) applyRules() (status int, location string, end bool) {
+	skip := 0
+	chainFailed := false
+	for i := range ctx.rs.Rules {
+		rule := &ctx.rs.Rules[i]
+		step := TraceRule{Rule: rule, Subject: strings.TrimPrefix(ctx.uri, ctx.rs.Dir)}
+		switch {
+		case rule.Disabled:
+			step.Skipped = "inside an inactive <IfModule>"
+		case skip > 0:
+			step.Skipped = "skipped by [S]"
+			skip--
+		case chainFailed:
+			step.Skipped = "previous chained rule did not match"
+			chainFailed = rule.Flags.Chain
+		}
+		if step.Skipped != "" {
+			ctx.record(step, false)
+			continue
+		}
+
+		ruleRefs, ok := ctx.matchRule(rule)
+		step.Matched, step.Captures = ok, ruleRefs
+		if !ok {
+			chainFailed = rule.Flags.Chain
+			ctx.record(step, false)
+			continue
+		}
+		condRefs, ok, condSteps := ctx.matchConds(rule.Conds, ruleRefs)
+		step.Conds = condSteps
+		if !ok {
+			chainFailed = rule.Flags.Chain
+			ctx.record(step, false)
+			continue
+		}
+
+		step.Applied = true
+		for _, assignment := range rule.Flags.Env {
+			expanded := ctx.expand(assignment, ruleRefs, condRefs)
+			ctx.setEnv(expanded)
+			step.EnvSet = append(step.EnvSet, expanded)
+		}
+
+		switch {
+		case rule.Flags.Forbidden:
+			step.Action = "forbidden [F]"
+			ctx.record(step, true)
+			return http.StatusForbidden, "", true
+		case rule.Flags.Gone:
+			step.Action = "gone [G]"
+			ctx.record(step, true)
+			return http.StatusGone, "", true
+		}
+
+		if rule.Substitution != "-" {
+			target := ctx.expand(rule.Substitution, ruleRefs, condRefs)
+			absolute := strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+			if !absolute && !strings.HasPrefix(target, "/") {
+				target = ctx.base() + target
+			}
+			ctx.setTarget(target, rule.Flags)
+			if rule.Flags.Redirect != 0 || absolute {
+				code := rule.Flags.Redirect
+				if code == 0 {
+					code = http.StatusFound
+				}
+				location := ctx.absoluteURL(ctx.uri, ctx.query)
+				if absolute {
+					location = withQuery(ctx.uri, ctx.query)
+				}
+				step.Action = fmt.Sprintf("redirect %d → %s", code, location)
+				ctx.record(step, true)
+				return code, location, true
+			}
+			step.Action = "rewrite → " + withQuery(ctx.uri, ctx.query)
+		} else if rule.Flags.Redirect >= 400 {
+			step.Action = fmt.Sprintf("respond %d", rule.Flags.Redirect)
+			ctx.record(step, true)
+			return rule.Flags.Redirect, "", true
+		}
+
+		if rule.Flags.End {
+			step.Action = strings.TrimPrefix(step.Action+", stop [END]", ", ")
+			ctx.record(step, true)
+			return 0, "", true
+		}
+		if rule.Flags.Last {
+			step.Action = strings.TrimPrefix(step.Action+", stop [L]", ", ")
+			ctx.record(step, true)
+			return 0, "", false
+		}
+		skip = rule.Flags.Skip
+		ctx.record(step, false)
+	}
+	return 0, "", false
+}
+
+// matchRule matches the rule pattern against the per-directory path
+func (ctx *rewriteContext) matchRule(rule *RewriteRule) ([]string, bool) {
+	subject := strings.TrimPrefix(ctx.uri, ctx.rs.Dir)
+	if rule.re == nil {
+		return nil, false
+	}
+	m := rule.re.FindStringSubmatch(subject)
+	if rule.Negate {
+		return nil, m == nil
+	}
+	return m, m != nil
+}
+
+// matchConds evaluates the conditions with Apache's [OR] chaining semantics
+// and returns the backreferences of the last matching condition
+func (ctx *rewriteContext) matchConds(conds []RewriteCond, ruleRefs []string) ([]string, bool, []TraceCond) {
+	var condRefs []string
+	var steps []TraceCond
+	for i := 0; i < len(conds); i++ {
+		cond := &conds[i]
+		value, refs, ok := ctx.matchCond(cond, ruleRefs, condRefs)
+		steps = append(steps, TraceCond{Cond: cond, Value: value, Matched: ok, Captures: refs})
+		if cond.OrNext {
+			if !ok {
+				continue
+			}
+			for i < len(conds) && conds[i].OrNext {
+				i++
+			}
+		} else if !ok {
+			return nil, false, steps
+		}
+		if refs != nil {
+			condRefs = refs
+		}
+	}
+	return condRefs, true, steps
+}
+
+// matchCond evaluates a single RewriteCond and returns the expanded test string
+func (ctx *rewriteContext) matchCond(cond *RewriteCond, ruleRefs, condRefs []string) (string, []string, bool) {
+	value := ctx.expand(cond.TestString, ruleRefs, condRefs)
+	pattern := strings.TrimPrefix(cond.Pattern, "!")
+
+	if cond.re != nil {
+		m := cond.re.FindStringSubmatch(value)
+		if cond.Negate {
+			return value, nil, m == nil
+		}
+		return value, m, m != nil
+	}
+
+	ok := ctx.compareCond(value, pattern, cond.NoCase)
+	return value, nil, ok != cond.Negate
+}
+
+// compareCond evaluates file tests and string/integer comparisons
+func (ctx *rewriteContext) compareCond(value, pattern string, noCase bool) bool {
+	switch pattern {
+	case "-f", "-F", "-s", "-x":
+		exists, isDir := ctx.docRoot.Stat(ctx.docRootPath(value))
+		return exists && !isDir
+	case "-d":
+		exists, isDir := ctx.docRoot.Stat(ctx.docRootPath(value))
+		return exists && isDir
+	case "-U":
+		exists, _ := ctx.docRoot.Stat(ctx.docRootPath(value))
+		return exists
+	case "-l", "-L", "-h":
+		return false
+	}
+
+	for _, op := range []string{"-eq", "-ne", "-lt", "-le", "-gt", "-ge"} {
+		if !strings.HasPrefix(pattern, op) {
+			continue
+		}
+		a, errA := strconv.Atoi(value)
+		b, errB := strconv.Atoi(strings.TrimPrefix(pattern, op))
+		if errA != nil || errB != nil {
+			return false
+		}
+		switch op {
+		case "-eq":
+			return a == b
+		case "-ne":
+			return a != b
+		case "-lt":
+			return a < b
+		case "-le":
+			return a <= b
+		case "-gt":
+			return a > b
+		default:
+			return a >= b
+		}
+	}
+
+	if noCase {
+		value, pattern = strings.ToLower(value), strings.ToLower(pattern)
+	}
+	switch {
+	case strings.HasPrefix(pattern, "<="):
+		return value <= pattern[2:]
+	case strings.HasPrefix(pattern, ">="):
+		return value >= pattern[2:]
+	case strings.HasPrefix(pattern, "<"):
+		return value < pattern[1:]
+	case strings.HasPrefix(pattern, ">"):
+		return value > pattern[1:]
+	case strings.HasPrefix(pattern, "="):
+		return value == pattern[1:]
+	}
+	return false
+}
+
+// docRootPath maps a filesystem path from %{REQUEST_FILENAME} back to a URL path
+func (ctx *rewriteContext) docRootPath(value string) string {
+	return "/" + strings.TrimPrefix(strings.TrimPrefix(value, ctx.docRoot.Root()), "/")
+}
+
+// expand substitutes $N, %N and %{VAR} references
+func (ctx *rewriteContext) expand(s string, ruleRefs, condRefs []string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && (s[i+1] == '$' || s[i+1] == '%') {
+			out.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if (c == '$' || c == '%') && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9' {
+			refs := ruleRefs
+			if c == '%' {
+				refs = condRefs
+			}
+			if n := int(s[i+1] - '0'); n < len(refs) {
+				out.WriteString(refs[n])
+			}
+			i++
+			continue
+		}
+		if c == '%' && i+1 < len(s) && s[i+1] == '{' {
+			if end := strings.IndexByte(s[i:], '}'); end > 0 {
+				out.WriteString(ctx.variable(s[i+2 : i+end]))
+				i += end
+				continue
+			}
+		}
+		out.WriteByte(c)
+	}
+	return out.String()
+}
+
+// variable resolves a %{NAME} server variable
+func (ctx *rewriteContext) variable(name string) string {
+	if prefix, key, ok := strings.Cut(name, ":"); ok {
+		switch strings.ToUpper(prefix) {
+		case "ENV":
+			return ctx.env[key]
+		case "HTTP":
+			return ctx.req.Header.Get(key)
+		}
+		return ""
+	}
+
+	switch strings.ToUpper(name) {
+	case "REQUEST_URI":
+		return ctx.uri
+	case "QUERY_STRING":
+		return ctx.query
+	case "REQUEST_FILENAME", "SCRIPT_FILENAME":
+		return strings.TrimSuffix(ctx.docRoot.Root(), "/") + ctx.uri
+	case "DOCUMENT_ROOT":
+		return ctx.docRoot.Root()
+	case "REQUEST_METHOD":
+		return ctx.req.Method
+	case "REQUEST_SCHEME":
+		return ctx.scheme()
+	case "HTTPS":
+		if ctx.scheme() == "https" {
+			return "on"
+		}
+		return "off"
+	case "HTTP_HOST", "SERVER_NAME":
+		return ctx.req.Host
+	case "REMOTE_ADDR":
+		return ctx.req.RemoteAddr
+	case "REMOTE_HOST":
+		if ctx.req.RemoteHost != "" {
+			return ctx.req.RemoteHost
+		}
+		return ctx.req.RemoteAddr
+	case "SERVER_PROTOCOL":
+		return "HTTP/1.1"
+	case "THE_REQUEST":
+		return fmt.Sprintf("%s %s HTTP/1.1", ctx.req.Method, withQuery(ctx.req.Path, ctx.req.Query))
+	case "TIME":
+		return time.Now().Format("20060102150405")
+	}
+
+	if header, ok := strings.CutPrefix(strings.ToUpper(name), "HTTP_"); ok {
+		return ctx.req.Header.Get(strings.ReplaceAll(header, "_", "-"))
+	}
+	return ""
+}
+
+// setEnv applies an E=VAR:VALUE (or E=!VAR) assignment
+func (ctx *rewriteContext) setEnv(assignment string) {
+	if name, ok := strings.CutPrefix(assignment, "!"); ok {
+		delete(ctx.env, name)
+		return
+	}
+	name, value, _ := strings.Cut(assignment, ":")
+	ctx.env[name] = value
+}
+
+// setTarget updates the current URI and query string from a substitution
+func (ctx *rewriteContext) setTarget(target string, flags RuleFlags) {
+	target, newQuery, hasQuery := strings.Cut(target, "?")
+	switch {
+	case flags.QSD && !hasQuery:
+		ctx.query = ""
+	case hasQuery && flags.QSA && ctx.query != "":
+		ctx.query = newQuery + "&" + ctx.query
+	case hasQuery:
+		ctx.query = newQuery
+	}
+	ctx.uri = target
+}
+
+// base returns the URL prefix for relative substitutions
+func (ctx *rewriteContext) base() string {
+	base := ctx.rs.Base
+	if base == "" {
+		base = ctx.rs.Dir
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return base
+}
+
+func (ctx *rewriteContext) scheme() string {
+	if ctx.req.Scheme != "" {
+		return ctx.req.Scheme
+	}
+	return "http"
+}
+
+// absoluteURL builds the Location Apache sends for a local redirect
+func (ctx *rewriteContext) absoluteURL(uri, query string) string {
+	return withQuery(ctx.scheme()+"://"+ctx.req.Host+uri, query)
+}
+
+func withQuery(uri, query string) string {
+	if query == "" {
+		return uri
+	}
+	return uri + "?" + query
+}
+
+// vhostQueryCountryRe and vhostHeaderCountryRe mirror the GeoIP mock rules of apps/docker-setup/apache-vhost.conf
+var (
+	vhostQueryCountryRe  = regexp.MustCompile(`(?i)country=([A-Z]{2})`)
+	vhostHeaderCountryRe = regexp.MustCompile(`(?i)^([A-Z]{2})$`)
+)
+
+// vhostCountry derives GEOIP_COUNTRY_CODE the same way the Docker vhost does
+func vhostCountry(req *http.Request) string {
+	country := ""
+	if m := vhostQueryCountryRe.FindStringSubmatch(req.URL.RawQuery); m != nil {
+		country = m[1]
+	}
+	if m := vhostHeaderCountryRe.FindStringSubmatch(req.Header.Get("X-Test-Country")); m != nil {
+		country = m[1]
+	}
+	return country
+}
+
+// rewriteBackend answers HTTP requests by evaluating a .htaccess file
+// in-process, acting as a virtual replacement for the Docker Apache stack
+type rewriteBackend struct {
+	path    string
+	docRoot DocRoot
+
+	mu       sync.Mutex
+	rules    *RuleSet
+	modTime  time.Time
+	size     int64
+	onReload func(*RuleSet) // called when a changed file was parsed again
+}
+
+// newRewriteBackend creates a backend for the given .htaccess file
+func newRewriteBackend(htaccessPath string, docRoot DocRoot) *rewriteBackend {
+	if docRoot == nil {
+		docRoot = dockerDocRoot
+	}
+	return &rewriteBackend{path: htaccessPath, docRoot: docRoot}
+}
+
+// ruleSet returns the parsed rules, re-reading the file when it changed
+func (b *rewriteBackend) ruleSet() (*RuleSet, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return nil, err
+	}
+	if b.rules != nil && info.ModTime().Equal(b.modTime) && info.Size() == b.size {
+		return b.rules, nil
+	}
+
+	rules, err := parseHtaccessFile(b.path)
+	if err != nil {
+		return nil, err
+	}
+	reloaded := b.rules != nil
+	b.rules, b.modTime, b.size = rules, info.ModTime(), info.Size()
+	if reloaded && b.onReload != nil {
+		b.onReload(rules)
+	}
+	return rules, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (b *rewriteBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	rules, err := b.ruleSet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load .htaccess: %w", err)
+	}
+
+	result := rules.Evaluate(newRewriteRequest(req), b.docRoot)
+	return newRewriteResponse(req, result), nil
+}
+
+// newRewriteRequest converts an outgoing HTTP request into a RewriteRequest;
+// the client address honours X-Forwarded-For and X-Real-IP as mod_remoteip
+// behind a trusted proxy does, and REMOTE_HOST is looked up when -rdns is set
+func newRewriteRequest(req *http.Request) *RewriteRequest {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	scheme := req.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	remoteAddr, remoteHost := "127.0.0.1", ""
+	if ip := clientIP(req); ip != nil {
+		remoteAddr = ip.String()
+	}
+	if reverseDNS != nil {
+		remoteHost = confirmedHost(req.Context(), reverseDNS, remoteAddr)
+	}
+	env := map[string]string{geoCountryEnv: ""}
+	geoEnv(req, env)
+	if country := vhostCountry(req); country != "" {
+		env[geoCountryEnv] = country
+	}
+	return &RewriteRequest{
+		Method:     req.Method,
+		Scheme:     scheme,
+		Host:       host,
+		Path:       req.URL.Path,
+		Query:      req.URL.RawQuery,
+		Header:     req.Header,
+		RemoteAddr: remoteAddr,
+		RemoteHost: remoteHost,
+		Env:        env,
+	}
+}
+
+// newRewriteResponse renders a RewriteResult as an HTTP response
+func newRewriteResponse(req *http.Request, result RewriteResult) *http.Response {
+	body := http.StatusText(result.Status)
+	header := http.Header{}
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("X-Debug-Country", result.Env[geoCountryEnv])
+	if result.Location != "" {
+		header.Set("Location", result.Location)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", result.Status, http.StatusText(result.Status)),
+		StatusCode:    result.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// offlineDocRoot is the document root used for in-process evaluation;
+// nil selects the Docker test image layout
+var offlineDocRoot DocRoot
+
+// configureBackend selects the backend used by testURL and testSpecialURL
+func configureBackend(name, docRoot string) error {
+	offlineDocRoot = nil
+	if docRoot != "" {
+		offlineDocRoot = dirDocRoot(docRoot)
+	}
+
+	switch name {
+	case "", "http":
+		backendTransport = nil
+	case "offline":
+		if _, err := os.Stat(htaccessPath); err != nil {
+			return fmt.Errorf("offline backend needs a readable .htaccess: %w", err)
+		}
+		backendTransport = newRewriteBackend(htaccessPath, offlineDocRoot)
+	default:
+		return fmt.Errorf("unknown backend %q (expected http or offline)", name)
+	}
+	return nil
+}
+
+// backendName describes the active backend for status lines
+func backendName() string {
+	if b, ok := backendTransport.(*rewriteBackend); ok {
+		return "offline (" + b.path + ")"
+	}
+	return "http"
+}
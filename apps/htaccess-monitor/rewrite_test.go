@@ -0,0 +1,241 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testHtaccess = `# Geo rules
+RewriteCond %{REQUEST_URI} ^/robots\.txt$ [NC]
+RewriteRule ^ - [L]
+
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^US$
+RewriteRule ^ - [L]
+
+RewriteCond %{QUERY_STRING} ^lang=([a-z]{2})$
+RewriteRule ^(.*)$ /%1/$1? [R=301,L]
+
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^DE$
+RewriteCond %{REQUEST_URI} !^/(de|uk|index\.php)(/|$) [NC]
+RewriteRule ^(.*)$ /de/$1 [R=302,L,QSA]
+
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^GB$ [OR]
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} !^(DE|GB|US)$
+RewriteCond %{REQUEST_URI} !^/(de|uk|index\.php)(/|$) [NC]
+RewriteRule ^(.*)$ /uk/$1 [R=302,L,QSA]
+
+<IfModule LiteSpeed>
+RewriteRule .* - [F]
+</IfModule>
+
+<IfModule mod_rewrite.c>
+RewriteEngine On
+RewriteRule .* - [E=SEEN_UA:%{HTTP_USER_AGENT}]
+RewriteBase /
+RewriteRule ^index\.php$ - [L]
+RewriteCond %{REQUEST_FILENAME} !-f
+RewriteCond %{REQUEST_FILENAME} !-d
+RewriteRule . /index.php [L]
+</IfModule>
+`
+
+// TestParseHtaccess tests directive parsing
+func TestParseHtaccess(t *testing.T) {
+	rs := parseHtaccess(strings.NewReader(testHtaccess))
+
+	if !rs.Engine {
+		t.Error("RewriteEngine On inside <IfModule mod_rewrite.c> should enable the engine")
+	}
+	if rs.Base != "/" {
+		t.Errorf("Base = %q, want /", rs.Base)
+	}
+	if len(rs.Problems) != 0 {
+		t.Errorf("Problems = %v, want none", rs.Problems)
+	}
+	if len(rs.Rules) != 9 {
+		t.Fatalf("parsed %d rules, want 9", len(rs.Rules))
+	}
+
+	geo := rs.Rules[3]
+	if geo.Line != 13 {
+		t.Errorf("rule line = %d, want 13", geo.Line)
+	}
+	if len(geo.Conds) != 2 || !geo.Conds[1].Negate || !geo.Conds[1].NoCase {
+		t.Errorf("conditions not parsed correctly: %+v", geo.Conds)
+	}
+	if geo.Flags.Redirect != 302 || !geo.Flags.Last || !geo.Flags.QSA {
+		t.Errorf("flags = %+v, want R=302,L,QSA", geo.Flags)
+	}
+	if !rs.Rules[5].Disabled {
+		t.Error("rule inside <IfModule LiteSpeed> should be disabled")
+	}
+	if rs.Rules[6].Flags.Env[0] != "SEEN_UA:%{HTTP_USER_AGENT}" {
+		t.Errorf("E flag = %v", rs.Rules[6].Flags.Env)
+	}
+}
+
+// TestParseHtaccessProblems tests syntax error reporting
+func TestParseHtaccessProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"unknown flag", "RewriteRule ^ - [Q]"},
+		{"bad redirect", "RewriteRule ^ /x [R=200]"},
+		{"missing argument", "RewriteRule ^"},
+		{"dangling cond", "RewriteCond %{REQUEST_URI} ^/x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := parseHtaccess(strings.NewReader(tt.content))
+			if len(rs.Problems) != 1 || rs.Problems[0].Line != 1 {
+				t.Errorf("Problems = %v, want one problem on line 1", rs.Problems)
+			}
+		})
+	}
+
+	rs := parseHtaccess(strings.NewReader("RewriteEngine On\nRewriteRule (?<=x)y /z"))
+	if rs.Rules[0].Err == nil {
+		t.Error("expected regex compile error for lookbehind")
+	}
+	if got := rs.Evaluate(&RewriteRequest{Path: "/"}, dockerDocRoot); got.Status != 500 {
+		t.Errorf("invalid regex status = %d, want 500", got.Status)
+	}
+}
+
+// TestRuleSetEvaluate tests rule evaluation against the Docker document root
+func TestRuleSetEvaluate(t *testing.T) {
+	rs := parseHtaccess(strings.NewReader(testHtaccess))
+
+	tests := []struct {
+		name     string
+		path     string
+		query    string
+		country  string
+		status   int
+		location string
+	}{
+		{"US passes through", "/", "", "US", 200, ""},
+		{"US missing page", "/missing", "", "US", 404, ""},
+		{"DE home", "/", "", "DE", 302, "http://localhost:8080/de/"},
+		{"DE backreference", "/test-content", "", "DE", 302, "http://localhost:8080/de/test-content"},
+		{"condition backreference drops query", "/page", "lang=fr", "FR", 301, "http://localhost:8080/fr/page"},
+		{"DE query string appended", "/page", "a=1", "DE", 302, "http://localhost:8080/de/page?a=1"},
+		{"DE already prefixed", "/de/", "", "DE", 200, ""},
+		{"GB via OR", "/", "", "GB", 302, "http://localhost:8080/uk/"},
+		{"unknown via OR", "/", "", "JP", 302, "http://localhost:8080/uk/"},
+		{"robots excluded", "/robots.txt", "", "DE", 200, ""},
+		{"directory slash", "/uk", "", "US", 301, "http://localhost:8080/uk/"},
+		{"front controller", "/de/some-post", "", "DE", 200, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rs.Evaluate(&RewriteRequest{
+				Method: "GET",
+				Host:   "localhost:8080",
+				Path:   tt.path,
+				Query:  tt.query,
+				Header: http.Header{},
+				Env:    map[string]string{geoCountryEnv: tt.country},
+			}, dockerDocRoot)
+
+			if got.Status != tt.status {
+				t.Errorf("status = %d, want %d", got.Status, tt.status)
+			}
+			if got.Location != tt.location {
+				t.Errorf("location = %q, want %q", got.Location, tt.location)
+			}
+		})
+	}
+}
+
+// TestRuleSetEvaluateEnv tests [E=] assignments and internal redirects
+func TestRuleSetEvaluateEnv(t *testing.T) {
+	rs := parseHtaccess(strings.NewReader(testHtaccess))
+	header := http.Header{}
+	header.Set("User-Agent", "TestBot/1.0")
+
+	got := rs.Evaluate(&RewriteRequest{
+		Method: "GET",
+		Host:   "localhost:8080",
+		Path:   "/de/some-post",
+		Header: header,
+		Env:    map[string]string{geoCountryEnv: "DE"},
+	}, dockerDocRoot)
+
+	if got.Env["SEEN_UA"] != "TestBot/1.0" {
+		t.Errorf("SEEN_UA = %q, want TestBot/1.0", got.Env["SEEN_UA"])
+	}
+	if got.URI != "/index.php" {
+		t.Errorf("URI = %q, want /index.php", got.URI)
+	}
+	if got.Env["REDIRECT_STATUS"] != "200" {
+		t.Errorf("REDIRECT_STATUS = %q, want 200 after internal redirect", got.Env["REDIRECT_STATUS"])
+	}
+}
+
+// TestVhostCountry tests the GeoIP mock header and query mapping
+func TestVhostCountry(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		header   string
+		expected string
+	}{
+		{"header", "http://localhost/", "DE", "DE"},
+		{"query", "http://localhost/?country=FR", "", "FR"},
+		{"header wins", "http://localhost/?country=FR", "IT", "IT"},
+		{"invalid header", "http://localhost/", "DEU", ""},
+		{"none", "http://localhost/", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			req.Header.Set("X-Test-Country", tt.header)
+			if got := vhostCountry(req); got != tt.expected {
+				t.Errorf("vhostCountry() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestOfflineBackend tests testURL against the in-process backend
+func TestOfflineBackend(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, ".htaccess")
+	if err := os.WriteFile(path, []byte(testHtaccess), 0644); err != nil {
+		t.Fatalf("Failed to create .htaccess: %v", err)
+	}
+
+	oldPath, oldTransport := htaccessPath, backendTransport
+	defer func() { htaccessPath, backendTransport = oldPath, oldTransport }()
+
+	htaccessPath = path
+	if err := configureBackend("offline", ""); err != nil {
+		t.Fatalf("configureBackend() error = %v", err)
+	}
+
+	result := testURL("http://localhost:8080/test-content", "de", "")
+	if result.Status != 302 || result.Result != "http://localhost:8080/de/test-content" {
+		t.Errorf("testURL() = %d %s, want 302 http://localhost:8080/de/test-content", result.Status, result.Result)
+	}
+
+	// Rules are re-read when the file changes
+	if err := os.WriteFile(path, []byte("RewriteEngine On\nRewriteRule ^ - [F]\n"), 0644); err != nil {
+		t.Fatalf("Failed to update .htaccess: %v", err)
+	}
+	result = testURL("http://localhost:8080/", "DE", "")
+	if result.Status != 403 {
+		t.Errorf("testURL() after change = %d, want 403", result.Status)
+	}
+
+	if err := configureBackend("carrier-pigeon", ""); err == nil {
+		t.Error("configureBackend() expected error for unknown backend")
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// defaultServeAddr is where serve listens, the port the Docker stack exposes
+const defaultServeAddr = ":8080"
+
+// debugPagePath is the GeoIP mock debug page of the Docker image
+const debugPagePath = "/geoip-mock.php"
+
+// geoServer emulates the Docker vhost: it maps X-Test-Country and ?country=
+// to GEOIP_COUNTRY_CODE, evaluates the .htaccess and answers like Apache
+type geoServer struct {
+	backend *rewriteBackend
+	logger  *log.Logger
+}
+
+// newGeoServer creates a server for a .htaccess file; a nil docRoot selects
+// the Docker test image layout
+func newGeoServer(htaccess string, docRoot DocRoot, logger *log.Logger) *geoServer {
+	s := &geoServer{backend: newRewriteBackend(htaccess, docRoot), logger: logger}
+	s.backend.onReload = func(rules *RuleSet) {
+		s.logger.Printf("🔄 Reloaded %s (%d rules)", htaccess, len(rules.Rules))
+	}
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *geoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.backend.ruleSet()
+	if err != nil {
+		s.logger.Printf("❌ %v", err)
+		http.Error(w, "failed to load .htaccess: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	req := newRewriteRequest(r)
+	result := rules.Evaluate(req, s.backend.docRoot)
+	country := result.Env[geoCountryEnv]
+
+	w.Header().Set("X-Debug-Country", country)
+	w.Header().Set("X-Debug-Query", r.URL.RawQuery)
+
+	// The debug page bypasses the rules so it stays reachable when the
+	// .htaccess redirects every path
+	debug := r.URL.Path == debugPagePath
+	status := result.Status
+	if debug {
+		status = http.StatusOK
+	}
+	s.logger.Printf("%s \"%s %s\" %d \"%s\" \"Country:%s\"", req.RemoteAddr, r.Method, r.URL.RequestURI(), status, r.UserAgent(), country)
+
+	switch {
+	case debug:
+		s.serveDebugPage(w, r, req, country)
+	case status != http.StatusOK:
+		if result.Location != "" {
+			w.Header().Set("Location", result.Location)
+		}
+		http.Error(w, http.StatusText(status), status)
+	default:
+		s.serveContent(w, r, result, country)
+	}
+}
+
+// serveContent answers a request that was not redirected: files of a real
+// document root are served as they are, the virtual layout gets a stub page
+func (s *geoServer) serveContent(w http.ResponseWriter, r *http.Request, result RewriteResult, country string) {
+	if root, ok := s.backend.docRoot.(dirDocRoot); ok {
+		name := filepath.Join(string(root), filepath.FromSlash(path.Clean("/"+result.URI)))
+		if info, err := os.Stat(name); err == nil && info.IsDir() {
+			name = filepath.Join(name, "index.html")
+		}
+		http.ServeFile(w, r, name)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if country == "" {
+		country = "Not Set"
+	}
+	if err := stubPageTemplate.Execute(w, map[string]string{
+		"URI":       r.URL.RequestURI(),
+		"Served":    result.URI,
+		"Country":   country,
+		"UserAgent": r.UserAgent(),
+	}); err != nil {
+		s.logger.Printf("❌ Error rendering page: %v", err)
+	}
+}
+
+// serveDebugPage renders the equivalent of geoip-mock.php
+func (s *geoServer) serveDebugPage(w http.ResponseWriter, r *http.Request, req *RewriteRequest, country string) {
+	if country == "" {
+		country = "NOT_SET"
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := debugPageTemplate.Execute(w, map[string]any{
+		"Country":     country,
+		"Query":       r.URL.RawQuery,
+		"UserAgent":   r.UserAgent(),
+		"Header":      r.Header.Get("X-Test-Country"),
+		"Countries":   monitorConfig.Countries,
+		"Htaccess":    s.backend.path,
+		"RequestURI":  r.URL.RequestURI(),
+		"Host":        r.Host,
+		"RemoteAddr":  req.RemoteAddr,
+		"GeoAddr":     req.Env[geoAddrEnv],
+		"GoogleBotUA": googleBotUA,
+	}); err != nil {
+		s.logger.Printf("❌ Error rendering debug page: %v", err)
+	}
+}
+
+// runServeCommand starts the GeoIP mock server until interrupted
+func runServeCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor serve [flags]\n\n")
+		fs.PrintDefaults()
+	}
+	var addr = fs.String("addr", defaultServeAddr, "Address to listen on")
+	var quiet = fs.Bool("quiet", false, "Do not log requests")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	if _, err := parseHtaccessFile(htaccessPath); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading .htaccess: %v\n", err)
+		return exitError
+	}
+	var out io.Writer = os.Stdout
+	if *quiet {
+		out = io.Discard
+	}
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           newGeoServer(htaccessPath, offlineDocRoot, log.New(out, "", log.LstdFlags)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	fmt.Printf("🌍 GeoIP mock serving %s on http://%s (debug page: %s)\n", htaccessPath, displayAddr(listener.Addr()), debugPagePath)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdown); err != nil {
+			log.Printf("Error shutting down server: %v", err)
+		}
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	return exitPass
+}
+
+// displayAddr renders a listen address as a host:port that can be browsed
+func displayAddr(addr net.Addr) string {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// stubPageTemplate is served for pages of the virtual document root
+var stubPageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Served}}</title></head>
+<body>
+<h1>GeoIP Mock - {{.Served}}</h1>
+<p>Request URI: {{.URI}}</p>
+<p>Country Code: {{.Country}}</p>
+<p>User Agent: {{.UserAgent}}</p>
+</body></html>
+`))
+
+// debugPageTemplate mirrors apps/docker-setup/geoip-mock.php; its buttons
+// send ISO codes, as the .htaccess conditions expect
+var debugPageTemplate = template.Must(template.New("debug").Funcs(template.FuncMap{"iso": isoCountryCode}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>GeoIP Mock - Testing Interface</title>
+<style>
+body { font-family: Arial, sans-serif; margin: 40px; }
+.container { max-width: 800px; margin: 0 auto; }
+.info-box { background: #f5f5f5; padding: 20px; margin: 20px 0; border-radius: 5px; }
+.country-buttons { display: grid; grid-template-columns: repeat(4, 1fr); gap: 10px; margin: 20px 0; }
+.country-btn { padding: 10px; text-align: center; background: #007cba; color: white; text-decoration: none; border-radius: 3px; }
+.country-btn:hover { background: #005a87; }
+.debug { background: #fff3cd; border: 1px solid #ffeaa7; padding: 15px; margin: 10px 0; }
+</style>
+</head>
+<body>
+<div class="container">
+<h1>🌍 GeoIP Mock Testing Interface</h1>
+
+<div class="info-box">
+<h2>Current Status</h2>
+<p><strong>Detected Country:</strong> <code>{{.Country}}</code></p>
+<p><strong>Query String:</strong> <code>{{.Query}}</code></p>
+<p><strong>User Agent:</strong> <code>{{.UserAgent}}</code></p>
+<p><strong>Test Country Header:</strong> <code>{{.Header}}</code></p>
+<p><strong>.htaccess:</strong> <code>{{.Htaccess}}</code></p>
+</div>
+
+<div class="debug">
+<h3>🔧 How to Test</h3>
+<p><strong>Method 1 - Query Parameter:</strong> Add <code>?country=XX</code> to any URL</p>
+<p><strong>Method 2 - HTTP Header:</strong> Send <code>X-Test-Country: XX</code> header</p>
+<p><strong>Method 3 - Use buttons below</strong></p>
+</div>
+
+<h2>🌎 Test Different Countries</h2>
+<div class="country-buttons">
+{{- range .Countries}}
+<a href="/?country={{iso .Code}}" class="country-btn">{{.Flag}} {{.Name}}</a>
+{{- end}}
+<a href="/" class="country-btn">❓ No Country</a>
+</div>
+
+<h2>🤖 Test Google Bot</h2>
+<div class="info-box">
+<pre><code>curl -H "X-Test-Country: DE" -A "{{.GoogleBotUA}}" http://{{.Host}}/</code></pre>
+</div>
+
+<div class="debug">
+<h3>🔍 Debug Information</h3>
+<pre>REQUEST_URI: {{.RequestURI}}
+HTTP_HOST: {{.Host}}
+REMOTE_ADDR: {{.RemoteAddr}}
+QUERY_STRING: {{.Query}}
+HTTP_USER_AGENT: {{.UserAgent}}
+GEOIP_ADDR: {{.GeoAddr}}
+GEOIP_COUNTRY_CODE: {{.Country}}</pre>
+</div>
+</div>
+</body>
+</html>
+`))
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveHtaccess redirects German visitors and everyone else to /uk/
+const serveHtaccess = `RewriteEngine On
+RewriteCond %{REQUEST_URI} ^/$
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^DE$
+RewriteRule ^$ /de/ [R=302,L]
+RewriteCond %{REQUEST_URI} ^/$
+RewriteRule ^$ /uk/ [R=302,L]
+`
+
+// newServeServer starts the GeoIP mock for a .htaccess file and returns a
+// client that does not follow redirects
+func newServeServer(t *testing.T, htaccess string, docRoot DocRoot, logs io.Writer) (*httptest.Server, *http.Client) {
+	t.Helper()
+	server := httptest.NewServer(newGeoServer(htaccess, docRoot, log.New(logs, "", 0)))
+	t.Cleanup(server.Close)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	return server, client
+}
+
+// TestGeoServer tests the country mapping, the debug header and the
+// answers of the mock server
+func TestGeoServer(t *testing.T) {
+	var logs bytes.Buffer
+	server, client := newServeServer(t, writeTestFile(t, ".htaccess", serveHtaccess), nil, &logs)
+
+	tests := []struct {
+		name         string
+		path         string
+		header       string
+		wantStatus   int
+		wantLocation string
+		wantCountry  string
+		wantBody     string
+	}{
+		{"header", "/", "DE", 302, "/de/", "DE", ""},
+		{"query", "/?country=DE", "", 302, "/de/?country=DE", "DE", ""},
+		{"fallback", "/", "", 302, "/uk/", "", ""},
+		{"page", "/de/", "DE", 200, "", "DE", "Country Code: DE"},
+		{"debug page", "/geoip-mock.php?country=FR", "", 200, "", "FR", "<code>FR</code>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", server.URL+tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Test-Country", tt.header)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get("Location"); !strings.HasSuffix(got, tt.wantLocation) || (tt.wantLocation == "") != (got == "") {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+			if got := resp.Header.Get("X-Debug-Country"); !strings.EqualFold(got, tt.wantCountry) {
+				t.Errorf("X-Debug-Country = %q, want %q", got, tt.wantCountry)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body missing %q:\n%s", tt.wantBody, body)
+			}
+		})
+	}
+
+	if !strings.Contains(logs.String(), `"GET /?country=DE" 302`) || !strings.Contains(logs.String(), `"Country:DE"`) {
+		t.Errorf("access log missing request lines:\n%s", logs.String())
+	}
+}
+
+// TestGeoServerDebugButtons tests that the country buttons of the debug
+// page send ISO codes and redirect like the header does
+func TestGeoServerDebugButtons(t *testing.T) {
+	server, client := newServeServer(t, writeTestFile(t, ".htaccess", serveHtaccess), nil, io.Discard)
+	resp, err := client.Get(server.URL + debugPagePath)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{`href="/?country=DE"`, `href="/?country=GB"`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("debug page missing %s", want)
+		}
+	}
+	if strings.Contains(string(body), `href="/?country=de"`) {
+		t.Error("debug page links the lowercase prefix")
+	}
+
+	resp, err = client.Get(server.URL + "/?country=DE")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Location"); resp.StatusCode != 302 || !strings.HasSuffix(got, "/de/?country=DE") {
+		t.Errorf("DE button = %d %q, want 302 to /de/", resp.StatusCode, got)
+	}
+}
+
+// TestGeoServerDocRoot tests that files of a real document root are served
+func TestGeoServerDocRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "de"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "de", "index.html"), []byte("Hallo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server, client := newServeServer(t, writeTestFile(t, ".htaccess", serveHtaccess), dirDocRoot(root), io.Discard)
+
+	resp, err := client.Get(server.URL + "/de/")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "Hallo" {
+		t.Errorf("GET /de/ = %d %q, want 200 Hallo", resp.StatusCode, body)
+	}
+}
+
+// TestGeoServerReload tests that an edited .htaccess is used by the next
+// request without restarting the server
+func TestGeoServerReload(t *testing.T) {
+	var logs bytes.Buffer
+	path := writeTestFile(t, ".htaccess", serveHtaccess)
+	server, client := newServeServer(t, path, nil, &logs)
+
+	location := func() string {
+		resp, err := client.Get(server.URL + "/")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get("Location")
+	}
+
+	if got := location(); !strings.HasSuffix(got, "/uk/") {
+		t.Fatalf("Location = %q, want /uk/", got)
+	}
+
+	edited := strings.Replace(serveHtaccess, "/uk/", "/en/", 1)
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := location(); !strings.HasSuffix(got, "/en/") {
+		t.Errorf("Location after edit = %q, want /en/", got)
+	}
+	if !strings.Contains(logs.String(), "Reloaded") {
+		t.Errorf("reload was not logged:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Verdicts of a spoofing probe
+const (
+	spoofExploitable  = "exploitable"
+	spoofVerified     = "verified"
+	spoofNotObserved  = "not observed"
+	spoofInconclusive = "inconclusive"
+)
+
+// spoofBrowserAgent is the catalog entry the baseline requests are sent as
+const spoofBrowserAgent = "chrome"
+
+// crawlerExemption is a rule that singles out crawlers by User-Agent
+type crawlerExemption struct {
+	Line   int         // line of the User-Agent condition
+	Cond   string      // the condition as written
+	Bots   []UserAgent // catalog bots whose User-Agent it matches
+	Checks []string    // client conditions of the same rule, e.g. "REMOTE_HOST (line 4)"
+}
+
+// spoofProbe holds the outcomes of one page and country for a bot
+type spoofProbe struct {
+	Page    string
+	Country string
+	Browser string // a browser from the country's IP
+	Genuine string // the bot from its own IP, empty when the catalog has none
+	Spoofed string // the bot's User-Agent from the country's IP
+}
+
+// spoofResult is the probing of one bot
+type spoofResult struct {
+	Bot      UserAgent
+	Host     string // confirmed reverse DNS name of Bot.IP, with -rdns
+	Verified bool   // Host lies in one of the bot's domains
+	Probes   []spoofProbe
+}
+
+// verdict tells whether the exemption can be triggered without being the
+// crawler: the spoofed request is treated like the bot rather than like a
+// browser
+func (p spoofProbe) verdict() string {
+	switch {
+	case p.Spoofed == p.Browser && (p.Genuine == "" || p.Genuine == p.Browser):
+		return spoofNotObserved
+	case p.Spoofed == p.Browser:
+		return spoofVerified
+	case p.Genuine == "" || p.Spoofed == p.Genuine:
+		return spoofExploitable
+	}
+	return spoofInconclusive
+}
+
+// isUserAgentVar reports whether a test string is the User-Agent header
+func isUserAgentVar(testString string) bool {
+	switch strings.ToUpper(testString) {
+	case "%{HTTP_USER_AGENT}", "%{HTTP:USER-AGENT}":
+		return true
+	}
+	return false
+}
+
+// clientVar returns the variable of a test string that identifies the
+// client, or "" when it does not
+func clientVar(testString string) string {
+	for _, name := range []string{"REMOTE_ADDR", "REMOTE_HOST", "HTTP:X-FORWARDED-FOR", "HTTP_X_FORWARDED_FOR"} {
+		if strings.Contains(strings.ToUpper(testString), "%{"+name+"}") {
+			return name
+		}
+	}
+	return ""
+}
+
+// findCrawlerExemptions lists the User-Agent conditions that match one of
+// the bots, either letting them through or sparing them a redirect, with
+// the client checks of the same rule
+func findCrawlerExemptions(rs *RuleSet, agents []UserAgent) []crawlerExemption {
+	var exemptions []crawlerExemption
+	for _, rule := range rs.Rules {
+		if rule.Disabled {
+			continue
+		}
+		var checks []string
+		for _, cond := range rule.Conds {
+			if name := clientVar(cond.TestString); name != "" {
+				checks = append(checks, fmt.Sprintf("%s (line %d)", name, cond.Line))
+			}
+		}
+		for _, cond := range rule.Conds {
+			if !isUserAgentVar(cond.TestString) || cond.re == nil {
+				continue
+			}
+			exemption := crawlerExemption{Line: cond.Line, Cond: "RewriteCond " + cond.TestString + " " + cond.Pattern, Checks: checks}
+			if cond.NoCase {
+				exemption.Cond += " [NC]"
+			}
+			for _, agent := range agents {
+				if agent.Bot && cond.re.MatchString(agent.UserAgent) {
+					exemption.Bots = append(exemption.Bots, agent)
+				}
+			}
+			if len(exemption.Bots) > 0 {
+				exemptions = append(exemptions, exemption)
+			}
+		}
+	}
+	return exemptions
+}
+
+// exemptedBots returns every bot of the exemptions once, in order
+func exemptedBots(exemptions []crawlerExemption) []UserAgent {
+	var bots []UserAgent
+	for _, exemption := range exemptions {
+		for _, bot := range exemption.Bots {
+			if !containsUserAgent(bots, bot.Name) {
+				bots = append(bots, bot)
+			}
+		}
+	}
+	return bots
+}
+
+// spoofRequest requests target as userAgent from ip in the given country
+// and describes the response
+func spoofRequest(ctx context.Context, target, country, userAgent, ip string) string {
+	headers := map[string]string{"X-Test-Country": isoCountryCode(country), "User-Agent": userAgent}
+	if ip != "" {
+		headers["X-Forwarded-For"] = ip
+		headers["X-Real-IP"] = ip
+	}
+	u, _ := url.Parse(target)
+	return sweepOutcome(u, testRequest(ctx, "GET", target, headers, 0, false))
+}
+
+// runSpoofProbes requests every page from every country as a browser, as
+// the bot from its own IP and as the bot from the country's IP
+func runSpoofProbes(bot UserAgent, browserUA string, pages []PageConfig, countries []Country) []spoofProbe {
+	probes := make([]spoofProbe, len(pages)*len(countries))
+	ctx, cancel := runContext()
+	defer cancel()
+	runOrdered(ctx, len(probes), func(ctx context.Context, i int) spoofProbe {
+		page, country := pages[i/len(countries)], countries[i%len(countries)]
+		target := monitorConfig.pageURL(page.Path)
+		probe := spoofProbe{
+			Page:    page.Path,
+			Country: country.Code,
+			Browser: spoofRequest(ctx, target, country.Code, browserUA, country.IP),
+			Spoofed: spoofRequest(ctx, target, country.Code, bot.UserAgent, country.IP),
+		}
+		if bot.IP != "" {
+			probe.Genuine = spoofRequest(ctx, target, country.Code, bot.UserAgent, bot.IP)
+		}
+		return probe
+	}, func(i int, probe spoofProbe) bool {
+		probes[i] = probe
+		return true
+	})
+	return probes
+}
+
+// writeExemptions prints the exemptions and how their rules check the client
+func writeExemptions(w io.Writer, exemptions []crawlerExemption) {
+	for _, exemption := range exemptions {
+		names := make([]string, len(exemption.Bots))
+		for i, bot := range exemption.Bots {
+			names[i] = bot.Name
+		}
+		fmt.Fprintf(w, "Line %d: %s\n", exemption.Line, exemption.Cond)
+		fmt.Fprintf(w, "   matches %s\n", strings.Join(names, ", "))
+		if len(exemption.Checks) == 0 {
+			fmt.Fprintf(w, "   ⚠️  the rule checks no client address, the User-Agent alone decides\n")
+		} else {
+			fmt.Fprintf(w, "   the rule also checks %s\n", strings.Join(exemption.Checks, ", "))
+		}
+		if reverseDNS == nil && strings.Contains(strings.Join(exemption.Checks, " "), "REMOTE_HOST") {
+			fmt.Fprintf(w, "   REMOTE_HOST is the client address offline unless -rdns is set\n")
+		}
+	}
+}
+
+// writeSpoofResult prints the probes of a bot, listing every cell when
+// verbose and otherwise the first exploitable and inconclusive ones; it
+// returns the number of exploitable cells
+func writeSpoofResult(w io.Writer, result spoofResult, verbose bool) int {
+	switch {
+	case result.Bot.IP == "":
+		fmt.Fprintf(w, "%s %s: no known crawler address, spoofed requests only\n", result.Bot.Icon, result.Bot.Name)
+	case result.Verified:
+		fmt.Fprintf(w, "%s %s: genuine from %s (reverse DNS %s ✓)\n", result.Bot.Icon, result.Bot.Name, result.Bot.IP, result.Host)
+	case result.Host != "":
+		fmt.Fprintf(w, "%s %s: genuine from %s (reverse DNS %s is not a crawler domain)\n", result.Bot.Icon, result.Bot.Name, result.Bot.IP, result.Host)
+	default:
+		fmt.Fprintf(w, "%s %s: genuine from %s\n", result.Bot.Icon, result.Bot.Name, result.Bot.IP)
+	}
+
+	counts, listed, hidden := map[string]int{}, 0, 0
+	for _, probe := range result.Probes {
+		verdict := probe.verdict()
+		counts[verdict]++
+		if !verbose && verdict != spoofExploitable && verdict != spoofInconclusive {
+			continue
+		}
+		if !verbose && listed == sweepListLimit {
+			hidden++
+			continue
+		}
+		listed++
+		icon := map[string]string{spoofExploitable: "❌", spoofVerified: "✅", spoofNotObserved: "➖", spoofInconclusive: "❔"}[verdict]
+		line := fmt.Sprintf("   %s %s %s  browser: %s · spoofed: %s", icon, strings.ToUpper(probe.Country), probe.Page, probe.Browser, probe.Spoofed)
+		if probe.Genuine != "" {
+			line += " · genuine: " + probe.Genuine
+		}
+		fmt.Fprintln(w, line)
+	}
+	if hidden > 0 {
+		fmt.Fprintf(w, "   … %d more (-v lists them)\n", hidden)
+	}
+	fmt.Fprintf(w, "   %d %s, %d %s, %d %s, %d %s\n",
+		counts[spoofExploitable], spoofExploitable, counts[spoofVerified], spoofVerified,
+		counts[spoofNotObserved], spoofNotObserved, counts[spoofInconclusive], spoofInconclusive)
+	return counts[spoofExploitable]
+}
+
+// runSpoofCommand checks that crawler exemptions of the .htaccess cannot be
+// triggered by sending a crawler's User-Agent from another address
+func runSpoofCommand(args []string) int {
+	fs := flag.NewFlagSet("spoof", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor spoof [flags] [.htaccess]\n\n")
+		fmt.Fprintf(fs.Output(), "Finds the rules that treat crawlers differently by User-Agent and requests\nevery page and country as a browser, as the crawler from its own address and\nas the crawler's User-Agent from the country's address (X-Forwarded-For).\nExits 1 when a spoofed request gets the crawler's treatment.\n\n")
+		fs.PrintDefaults()
+	}
+	var agentName = fs.String("agent", "", "Only probe this catalog bot")
+	var verbose = fs.Bool("v", false, "List every page and country, not only the exploitable ones")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	path := htaccessPath
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	rs, err := parseHtaccessFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading .htaccess: %v\n", err)
+		return exitError
+	}
+	browser, ok := monitorConfig.userAgent(spoofBrowserAgent)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "❌ Unknown agent %q\n", spoofBrowserAgent)
+		return exitError
+	}
+
+	exemptions := findCrawlerExemptions(rs, monitorConfig.userAgents())
+	fmt.Printf("🛡️  Crawler exemptions in %s, probed via the %s backend\n\n", path, backendName())
+	if len(exemptions) == 0 {
+		fmt.Println("✅ No rule matches a crawler User-Agent")
+		return exitPass
+	}
+	writeExemptions(os.Stdout, exemptions)
+
+	bots := exemptedBots(exemptions)
+	if *agentName != "" {
+		bot, ok := monitorConfig.userAgent(*agentName)
+		if !ok || !containsUserAgent(bots, bot.Name) {
+			fmt.Fprintf(os.Stderr, "❌ No exemption matches agent %q\n", *agentName)
+			return exitError
+		}
+		bots = []UserAgent{bot}
+	}
+
+	exploitable := 0
+	for _, bot := range bots {
+		result := spoofResult{Bot: bot}
+		if reverseDNS != nil && bot.IP != "" {
+			result.Host, result.Verified = crawlerHost(context.Background(), reverseDNS, bot.IP, bot.Domains)
+		}
+		result.Probes = runSpoofProbes(bot, browser.UserAgent, monitorConfig.Pages, monitorConfig.Countries)
+		fmt.Println()
+		exploitable += writeSpoofResult(os.Stdout, result, *verbose)
+	}
+
+	fmt.Println()
+	if exploitable > 0 {
+		fmt.Printf("❌ A spoofed crawler User-Agent gets the exemption in %d cell(s)\n", exploitable)
+		return exitFail
+	}
+	fmt.Println("✅ No exemption could be triggered with a spoofed User-Agent")
+	return exitPass
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// spoofHtaccess lets crawlers skip the geo redirect by User-Agent alone
+// (Googlebot, Bingbot) or by User-Agent and reverse DNS (Applebot)
+const spoofHtaccess = `RewriteEngine On
+RewriteCond %{HTTP_USER_AGENT} (googlebot|bingbot) [NC]
+RewriteRule ^ - [L]
+
+RewriteCond %{HTTP_USER_AGENT} applebot [NC]
+RewriteCond %{REMOTE_HOST} \.applebot\.apple\.com$
+RewriteRule ^ - [L]
+
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^DE$
+RewriteRule ^(.*)$ /de/$1 [R=302,L]
+`
+
+// TestSpoofVerdict tests how the three outcomes of a cell are judged
+func TestSpoofVerdict(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe spoofProbe
+		want  string
+	}{
+		{"spoofed like genuine", spoofProbe{Browser: "→ /de/", Genuine: "no redirect", Spoofed: "no redirect"}, spoofExploitable},
+		{"spoofed like browser", spoofProbe{Browser: "→ /de/", Genuine: "no redirect", Spoofed: "→ /de/"}, spoofVerified},
+		{"no exemption", spoofProbe{Browser: "→ /de/", Genuine: "→ /de/", Spoofed: "→ /de/"}, spoofNotObserved},
+		{"spoofed only, exempt", spoofProbe{Browser: "→ /de/", Spoofed: "no redirect"}, spoofExploitable},
+		{"spoofed only, not exempt", spoofProbe{Browser: "→ /de/", Spoofed: "→ /de/"}, spoofNotObserved},
+		{"all different", spoofProbe{Browser: "→ /de/", Genuine: "no redirect", Spoofed: "403 Forbidden"}, spoofInconclusive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.probe.verdict(); got != tt.want {
+				t.Errorf("verdict() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFindCrawlerExemptions tests which conditions are found, which bots
+// they match and which client checks their rules make
+func TestFindCrawlerExemptions(t *testing.T) {
+	rs := parseHtaccess(strings.NewReader(spoofHtaccess + `
+RewriteCond %{HTTP_USER_AGENT} !(yandex|duckduckbot) [NC]
+RewriteCond %{ENV:GEOIP_COUNTRY_CODE} ^AT$
+RewriteRule ^(.*)$ /at/$1 [R=302,L]
+<IfModule LiteSpeed>
+RewriteCond %{HTTP_USER_AGENT} facebookexternalhit
+RewriteRule ^ - [L]
+</IfModule>
+`))
+	exemptions := findCrawlerExemptions(rs, defaultConfig().userAgents())
+	if len(exemptions) != 3 {
+		t.Fatalf("found %d exemptions, want 3: %+v", len(exemptions), exemptions)
+	}
+	var names []string
+	for _, bot := range exemptions[0].Bots {
+		names = append(names, bot.Name)
+	}
+	if got := strings.Join(names, ","); got != "googlebot,googlebot-smartphone,googlebot-image,bingbot" {
+		t.Errorf("line 2 matches %s", got)
+	}
+	if len(exemptions[0].Checks) != 0 || exemptions[0].Cond != "RewriteCond %{HTTP_USER_AGENT} (googlebot|bingbot) [NC]" {
+		t.Errorf("exemptions[0] = %+v", exemptions[0])
+	}
+	if exemptions[1].Line != 5 || len(exemptions[1].Checks) != 1 || exemptions[1].Checks[0] != "REMOTE_HOST (line 6)" {
+		t.Errorf("exemptions[1] = %+v", exemptions[1])
+	}
+	// A negated condition spares the bots it matches a redirect
+	if exemptions[2].Line != 12 || len(exemptions[2].Bots) != 2 || !containsUserAgent(exemptions[2].Bots, "duckduckbot") {
+		t.Errorf("exemptions[2] = %+v", exemptions[2])
+	}
+	if got := len(exemptedBots(exemptions)); got != 7 {
+		t.Errorf("exemptedBots() = %d bots, want 7", got)
+	}
+}
+
+// TestRunSpoofProbes tests the probes against the offline engine with and
+// without a crawler check
+func TestRunSpoofProbes(t *testing.T) {
+	oldTransport := backendTransport
+	defer func() { backendTransport = oldTransport }()
+	backendTransport = newRewriteBackend(writeTestFile(t, ".htaccess", spoofHtaccess), offlineDocRoot)
+	useReverseDNS(t, "17.58.98.1 17-58-98-1.applebot.apple.com\n")
+
+	cfg := defaultConfig()
+	browser, _ := cfg.userAgent(spoofBrowserAgent)
+	pages := []PageConfig{{Name: "Home", Path: "/"}}
+	countries := []Country{{Code: "de", IP: "85.214.132.117"}, {Code: "us", IP: "8.8.8.8"}}
+
+	tests := []struct {
+		bot  string
+		want []string
+	}{
+		{"googlebot", []string{spoofExploitable, spoofNotObserved}},
+		{"applebot", []string{spoofVerified, spoofNotObserved}},
+		{"yandexbot", []string{spoofNotObserved, spoofNotObserved}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bot, func(t *testing.T) {
+			bot, _ := cfg.userAgent(tt.bot)
+			probes := runSpoofProbes(bot, browser.UserAgent, pages, countries)
+			for i, probe := range probes {
+				if got := probe.verdict(); got != tt.want[i] {
+					t.Errorf("%s verdict = %q, want %q (%+v)", probe.Country, got, tt.want[i], probe)
+				}
+			}
+		})
+	}
+}
+
+// TestWriteSpoofResult tests the report of a bot and its exploitable count
+func TestWriteSpoofResult(t *testing.T) {
+	bot, _ := defaultConfig().userAgent("googlebot")
+	result := spoofResult{Bot: bot, Host: "crawl-66-249-66-1.googlebot.com", Verified: true, Probes: []spoofProbe{
+		{Page: "/", Country: "de", Browser: "→ /de/", Genuine: "no redirect", Spoofed: "no redirect"},
+		{Page: "/", Country: "us", Browser: "no redirect", Genuine: "no redirect", Spoofed: "no redirect"},
+	}}
+	var out bytes.Buffer
+	if got := writeSpoofResult(&out, result, false); got != 1 {
+		t.Errorf("writeSpoofResult() = %d exploitable, want 1", got)
+	}
+	for _, want := range []string{
+		"🤖 googlebot: genuine from 66.249.66.1 (reverse DNS crawl-66-249-66-1.googlebot.com ✓)\n",
+		"   ❌ DE /  browser: → /de/ · spoofed: no redirect · genuine: no redirect\n",
+		"   1 exploitable, 0 verified, 1 not observed, 0 inconclusive\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "US /") {
+		t.Errorf("cells without an exemption listed without -v:\n%s", out.String())
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// sweepExtraCodes are swept besides the ISO codes: no country, a code that
+// belongs to no country and the legacy GeoIP pseudo-codes for Europe and
+// anonymous proxies
+var sweepExtraCodes = []string{"", "XX", "EU", "A1"}
+
+// sweepNoCountry stands for the request without a country in output and
+// mapping files
+const sweepNoCountry = "(none)"
+
+// sweepDefaultKey is the mapping file entry for every code not listed
+const sweepDefaultKey = "*"
+
+// sweepListLimit is the largest group whose codes are listed without -v
+const sweepListLimit = 12
+
+// sweepResult is the outcome of the request for one country code
+type sweepResult struct {
+	Code    string // ISO code, or one of sweepExtraCodes
+	Outcome string // e.g. "→ /uk/", "no redirect", "403 Forbidden"
+}
+
+// sweepGroup is the set of codes that share an outcome
+type sweepGroup struct {
+	Outcome string
+	Codes   []string
+}
+
+// sweepCodes returns every ISO code in order followed by the extra codes
+func sweepCodes() []string {
+	codes := make([]string, 0, len(isoCountries)+len(sweepExtraCodes))
+	for code := range isoCountries {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return append(codes, sweepExtraCodes...)
+}
+
+// sweepLabel returns how a code is shown and written to mapping files
+func sweepLabel(code string) string {
+	if code == "" {
+		return sweepNoCountry
+	}
+	return code
+}
+
+// sweepOutcome describes a response; redirects to the swept host are shown
+// by path, 302 being implied
+func sweepOutcome(target *url.URL, result HTTPResult) string {
+	if result.Status == 0 {
+		return "error: " + result.Result
+	}
+	location := result.Header.Get("Location")
+	if result.Status >= 300 && result.Status < 400 && location != "" {
+		if u, err := target.Parse(location); err == nil && u.Host == target.Host {
+			location = u.RequestURI()
+		}
+		if result.Status == http.StatusFound {
+			return "→ " + location
+		}
+		return fmt.Sprintf("→ %s (%d)", location, result.Status)
+	}
+	if result.Status == http.StatusOK {
+		return "no redirect"
+	}
+	return fmt.Sprintf("%d %s", result.Status, http.StatusText(result.Status))
+}
+
+// runSweep requests target once per code with the given User-Agent
+func runSweep(target, userAgent string) ([]sweepResult, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q", target)
+	}
+
+	codes := sweepCodes()
+	results := make([]sweepResult, len(codes))
+	ctx, cancel := runContext()
+	defer cancel()
+	runOrdered(ctx, len(codes), func(ctx context.Context, i int) sweepResult {
+		headers := map[string]string{"X-Test-Country": codes[i]}
+		if userAgent != "" {
+			headers["User-Agent"] = userAgent
+		}
+		result := testRequest(ctx, "GET", target, headers, 0, false)
+		return sweepResult{Code: codes[i], Outcome: sweepOutcome(u, result)}
+	}, func(i int, result sweepResult) bool {
+		results[i] = result
+		return true
+	})
+	return results, nil
+}
+
+// groupSweep groups results by outcome, largest group first
+func groupSweep(results []sweepResult) []sweepGroup {
+	index := map[string]int{}
+	var groups []sweepGroup
+	for _, result := range results {
+		i, ok := index[result.Outcome]
+		if !ok {
+			i = len(groups)
+			index[result.Outcome] = i
+			groups = append(groups, sweepGroup{Outcome: result.Outcome})
+		}
+		groups[i].Codes = append(groups[i].Codes, sweepLabel(result.Code))
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].Codes) != len(groups[j].Codes) {
+			return len(groups[i].Codes) > len(groups[j].Codes)
+		}
+		return groups[i].Outcome < groups[j].Outcome
+	})
+	return groups
+}
+
+// writeSweep prints the groups; codes of large groups are only listed when
+// verbose
+func writeSweep(w io.Writer, groups []sweepGroup, verbose bool) {
+	for _, group := range groups {
+		switch {
+		case len(group.Codes) <= sweepListLimit:
+			fmt.Fprintf(w, "%s: %s\n", group.Outcome, strings.Join(group.Codes, " "))
+		case verbose:
+			fmt.Fprintf(w, "%s: %d countries\n", group.Outcome, len(group.Codes))
+			for start := 0; start < len(group.Codes); start += 20 {
+				end := min(start+20, len(group.Codes))
+				fmt.Fprintf(w, "    %s\n", strings.Join(group.Codes[start:end], " "))
+			}
+		default:
+			fmt.Fprintf(w, "%s: %d countries\n", group.Outcome, len(group.Codes))
+		}
+	}
+}
+
+// writeSweepMap writes results as a mapping file that -expect accepts
+func writeSweepMap(w io.Writer, target string, results []sweepResult) error {
+	fmt.Fprintf(w, "# htaccess-monitor sweep of %s\n", target)
+	for _, result := range results {
+		if _, err := fmt.Fprintf(w, "%s %s\n", sweepLabel(result.Code), result.Outcome); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSweepMap reads a mapping file of "CODE OUTCOME" lines; "*" sets the
+// outcome of every code not listed, other codes are not checked
+func parseSweepMap(r io.Reader) (map[string]string, error) {
+	expected := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		code, outcome, ok := strings.Cut(text, " ")
+		outcome = strings.TrimSpace(outcome)
+		if !ok || outcome == "" {
+			return nil, fmt.Errorf("line %d: want CODE OUTCOME, got %q", line, text)
+		}
+		if code != sweepDefaultKey && code != sweepNoCountry {
+			code = strings.ToUpper(code)
+		}
+		if _, dup := expected[code]; dup {
+			return nil, fmt.Errorf("line %d: duplicate code %s", line, code)
+		}
+		expected[code] = outcome
+	}
+	return expected, scanner.Err()
+}
+
+// diffSweep lists the results that differ from the expected mapping
+func diffSweep(expected map[string]string, results []sweepResult) []string {
+	var diffs []string
+	for _, result := range results {
+		label := sweepLabel(result.Code)
+		want, ok := expected[label]
+		if !ok {
+			want, ok = expected[sweepDefaultKey]
+		}
+		if ok && want != result.Outcome {
+			diffs = append(diffs, fmt.Sprintf("%s: expected %s, got %s", label, want, result.Outcome))
+		}
+	}
+	return diffs
+}
+
+// sweepTarget resolves the URL argument of sweep against base_url
+func sweepTarget(arg string) string {
+	switch {
+	case arg == "":
+		return monitorConfig.BaseURL + "/"
+	case strings.HasPrefix(arg, "/"):
+		return monitorConfig.BaseURL + arg
+	}
+	return arg
+}
+
+// sweepAgent returns the configured agent with the given name, or else the
+// catalog entry
+func sweepAgent(name string) (AgentConfig, bool) {
+	if name == "" {
+		return monitorConfig.Agents[0], true
+	}
+	for _, agent := range monitorConfig.Agents {
+		if strings.EqualFold(agent.Name, name) {
+			return agent, true
+		}
+	}
+	if entry, ok := lookupUserAgent(name); ok {
+		return entry.matrixAgent(AgentConfig{}), true
+	}
+	return AgentConfig{}, false
+}
+
+// runSweepCommand sweeps a URL across every country code
+func runSweepCommand(args []string) int {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: htaccess-monitor sweep [flags] [url or path]\n\n")
+		fmt.Fprintf(fs.Output(), "Requests the URL (default: base_url) once for every ISO 3166 country plus\nempty and invalid codes, and groups the codes by outcome.\n\n")
+		fs.PrintDefaults()
+	}
+	var agentName = fs.String("agent", "", "Agent of the test matrix or the catalog to send (default: the first matrix agent)")
+	var expectFile = fs.String("expect", "", "Mapping file to diff the outcomes against; exits 1 on differences")
+	var writeFile = fs.String("write", "", "Write the outcome of every code as a mapping file (- for stdout)")
+	var verbose = fs.Bool("v", false, "List the codes of every group")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	agent, ok := sweepAgent(*agentName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "❌ Unknown agent %q\n", *agentName)
+		return exitError
+	}
+	var expected map[string]string
+	if *expectFile != "" {
+		file, err := os.Open(*expectFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error reading mapping: %v\n", err)
+			return exitError
+		}
+		expected, err = parseSweepMap(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", *expectFile, err)
+			return exitError
+		}
+	}
+
+	target := sweepTarget(fs.Arg(0))
+	results, err := runSweep(target, agent.UserAgent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+
+	out := io.Writer(os.Stdout)
+	if *writeFile == "-" {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "🌍 Sweep of %s as %s (%d codes)\n\n", target, agent.Name, len(results))
+	writeSweep(out, groupSweep(results), *verbose)
+
+	if *writeFile != "" {
+		if err := writeOutputFile(*writeFile, func(w io.Writer) error {
+			return writeSweepMap(w, target, results)
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Error writing mapping: %v\n", err)
+			return exitError
+		}
+	}
+
+	if expected == nil {
+		return exitPass
+	}
+	diffs := diffSweep(expected, results)
+	if len(diffs) == 0 {
+		fmt.Fprintf(out, "\n✅ Matches %s\n", *expectFile)
+		return exitPass
+	}
+	fmt.Fprintf(out, "\n❌ %d code(s) differ from %s:\n", len(diffs), *expectFile)
+	for _, diff := range diffs {
+		fmt.Fprintf(out, "   %s\n", diff)
+	}
+	return exitFail
+}
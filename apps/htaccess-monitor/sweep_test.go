@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newSweepServer redirects DE and AT to their own prefix, answers US and
+// requests without a country directly and sends everyone else to /uk/
+func newSweepServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch country := r.Header.Get("X-Test-Country"); country {
+		case "US", "":
+			w.WriteHeader(http.StatusOK)
+		case "DE", "AT":
+			http.Redirect(w, r, "/"+strings.ToLower(country)+"/", http.StatusFound)
+		case "CH":
+			http.Redirect(w, r, "https://example.ch/", http.StatusMovedPermanently)
+		case "KP":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.Redirect(w, r, "http://"+r.Host+"/uk/", http.StatusFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestSweepOutcome tests how responses are summarised
+func TestSweepOutcome(t *testing.T) {
+	target, _ := url.Parse("http://localhost:8080/shop")
+	tests := []struct {
+		name   string
+		result HTTPResult
+		want   string
+	}{
+		{"same host redirect", HTTPResult{Status: 302, Header: http.Header{"Location": {"http://localhost:8080/uk/shop?a=1"}}}, "→ /uk/shop?a=1"},
+		{"relative redirect", HTTPResult{Status: 302, Header: http.Header{"Location": {"/de/"}}}, "→ /de/"},
+		{"permanent redirect", HTTPResult{Status: 301, Header: http.Header{"Location": {"/de/"}}}, "→ /de/ (301)"},
+		{"other host", HTTPResult{Status: 302, Header: http.Header{"Location": {"https://example.ch/"}}}, "→ https://example.ch/"},
+		{"no redirect", HTTPResult{Status: 200}, "no redirect"},
+		{"forbidden", HTTPResult{Status: 403}, "403 Forbidden"},
+		{"connection failed", HTTPResult{Status: 0, Result: "Connection failed"}, "error: Connection failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sweepOutcome(target, tt.result); got != tt.want {
+				t.Errorf("sweepOutcome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunSweep tests that every code is requested and grouped by outcome
+func TestRunSweep(t *testing.T) {
+	server := newSweepServer(t)
+	results, err := runSweep(server.URL+"/", "")
+	if err != nil {
+		t.Fatalf("runSweep() error = %v", err)
+	}
+	if len(results) != len(isoCountries)+len(sweepExtraCodes) {
+		t.Fatalf("got %d results, want one per ISO code plus the extra codes", len(results))
+	}
+
+	groups := groupSweep(results)
+	if groups[0].Outcome != "→ /uk/" || len(groups[0].Codes) != len(results)-6 {
+		t.Errorf("largest group = %s with %d codes", groups[0].Outcome, len(groups[0].Codes))
+	}
+	var out bytes.Buffer
+	writeSweep(&out, groups, false)
+	for _, want := range []string{
+		"→ /uk/: 247 countries\n",
+		"no redirect: US (none)\n",
+		"→ /at/: AT\n",
+		"→ https://example.ch/ (301): CH\n",
+		"403 Forbidden: KP\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	writeSweep(&out, groups, true)
+	if !strings.Contains(out.String(), "    AD AE AF") {
+		t.Errorf("verbose output does not list the codes:\n%s", out.String())
+	}
+}
+
+// TestSweepMap tests writing a mapping file and diffing results against it
+func TestSweepMap(t *testing.T) {
+	results := []sweepResult{
+		{"DE", "→ /de/"},
+		{"FR", "no redirect"},
+		{"US", "no redirect"},
+		{"", "→ /uk/"},
+	}
+
+	var out bytes.Buffer
+	if err := writeSweepMap(&out, "http://localhost:8080/", results); err != nil {
+		t.Fatalf("writeSweepMap() error = %v", err)
+	}
+	written, err := parseSweepMap(&out)
+	if err != nil {
+		t.Fatalf("parseSweepMap() error = %v", err)
+	}
+	if diffs := diffSweep(written, results); len(diffs) != 0 {
+		t.Errorf("results differ from their own mapping: %v", diffs)
+	}
+
+	expected, err := parseSweepMap(strings.NewReader("# expected\n* → /uk/\nde → /de/\nUS no redirect\n"))
+	if err != nil {
+		t.Fatalf("parseSweepMap() error = %v", err)
+	}
+	diffs := diffSweep(expected, results)
+	if len(diffs) != 1 || diffs[0] != "FR: expected → /uk/, got no redirect" {
+		t.Errorf("diffs = %v, want only FR", diffs)
+	}
+
+	for _, invalid := range []string{"DE\n", "DE → /de/\nde → /at/\n"} {
+		if _, err := parseSweepMap(strings.NewReader(invalid)); err == nil {
+			t.Errorf("parseSweepMap(%q) expected error", invalid)
+		}
+	}
+}
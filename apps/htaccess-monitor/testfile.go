@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// linkTestFileVersion is the version of the YAML/JSON test file format
+const linkTestFileVersion = 1
+
+// validMethods are the HTTP methods a test case may use
+var validMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// linkTestFile is the YAML/JSON form of a links.testing file
+type linkTestFile struct {
+	Version int            `yaml:"version"`
+	Tests   []linkTestSpec `yaml:"tests"`
+}
+
+// linkTestSpec is a test case in the YAML/JSON format
+type linkTestSpec struct {
+	Name    string            `yaml:"name"`
+	Agent   string            `yaml:"agent"`
+	Country string            `yaml:"country"`
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+	Cookies map[string]string `yaml:"cookies"`
+	Query   map[string]string `yaml:"query"`
+	Expect  linkTestExpect    `yaml:"expect"`
+}
+
+// linkTestExpect holds the assertions of a YAML/JSON test case
+type linkTestExpect struct {
+	Status        int               `yaml:"status"`
+	Result        string            `yaml:"result"`
+	Match         string            `yaml:"match"`
+	PreserveQuery bool              `yaml:"preserve_query"`
+	Final         string            `yaml:"final"`
+	Hops          int               `yaml:"hops"`
+	Headers       map[string]string `yaml:"headers"`
+	BodyContains  []string          `yaml:"body_contains"`
+	BodyRegex     string            `yaml:"body_regex"`
+}
+
+// parseLinkTestFile reads a links.testing file: YAML or JSON by extension,
+// CSV otherwise. Every malformed test case is reported with its line.
+func parseLinkTestFile(filename string) ([]LinkTest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml", ".json":
+		return parseLinkTestYAML(filename, data)
+	default:
+		return parseLinkTestCSV(filename, data)
+	}
+}
+
+// parseLinkTestYAML parses the versioned YAML/JSON format; JSON is read as YAML
+func parseLinkTestYAML(filename string, data []byte) ([]LinkTest, error) {
+	var file linkTestFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if file.Version != linkTestFileVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d (expected version: %d)", filename, file.Version, linkTestFileVersion)
+	}
+
+	// Decode again as a node tree for the line of every test case
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	lines := testCaseLines(&root)
+
+	var tests []LinkTest
+	var errs []error
+	for i, spec := range file.Tests {
+		line := 0
+		if i < len(lines) {
+			line = lines[i]
+		}
+		test := LinkTest{
+			Line:           line,
+			Name:           spec.Name,
+			Agent:          spec.Agent,
+			Country:        spec.Country,
+			URL:            spec.URL,
+			Method:         strings.ToUpper(spec.Method),
+			Headers:        spec.Headers,
+			Cookies:        spec.Cookies,
+			Query:          spec.Query,
+			ExpectedStatus: spec.Expect.Status,
+			ExpectedResult: defaultExpectedResult(spec.Expect.Status, spec.Expect.Result),
+			Match:          strings.ToLower(spec.Expect.Match),
+			PreserveQuery:  spec.Expect.PreserveQuery,
+			ExpectedFinal:  spec.Expect.Final,
+			ExpectedHops:   spec.Expect.Hops,
+			ExpectHeaders:  spec.Expect.Headers,
+			BodyContains:   spec.Expect.BodyContains,
+			BodyRegex:      spec.Expect.BodyRegex,
+		}
+		if err := validateLinkTest(test); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", filename, line, err))
+			continue
+		}
+		tests = append(tests, test)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return tests, nil
+}
+
+// testCaseLines returns the line of each entry of the top-level tests list
+func testCaseLines(root *yaml.Node) []int {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value != "tests" {
+			continue
+		}
+		var lines []int
+		for _, item := range doc.Content[i+1].Content {
+			lines = append(lines, item.Line)
+		}
+		return lines
+	}
+	return nil
+}
+
+// defaultExpectedResult is "No redirect" for non-redirect statuses without
+// an expected result, so any Location is accepted only for redirects
+func defaultExpectedResult(status int, result string) string {
+	if result == "" && (status < 300 || status >= 400) {
+		return "No redirect"
+	}
+	return result
+}
+
+// csvColumn is a column of the CSV format
+type csvColumn int
+
+const (
+	colAgent csvColumn = iota
+	colCountry
+	colURL
+	colStatus
+	colResult
+	colFinal
+	colHops
+	colName
+	colMethod
+	colHeaders
+	colCookies
+	colQuery
+	colExpectHeaders
+	colBodyContains
+	colBodyRegex
+	colMatch
+	colPreserveQuery
+)
+
+// csvColumnNames maps normalised header names to columns
+var csvColumnNames = map[string]csvColumn{
+	"agent":           colAgent,
+	"country":         colCountry,
+	"url":             colURL,
+	"expectedstatus":  colStatus,
+	"status":          colStatus,
+	"expectedresult":  colResult,
+	"result":          colResult,
+	"expectedfinal":   colFinal,
+	"final":           colFinal,
+	"expectedhops":    colHops,
+	"hops":            colHops,
+	"name":            colName,
+	"method":          colMethod,
+	"headers":         colHeaders,
+	"cookies":         colCookies,
+	"query":           colQuery,
+	"expectheaders":   colExpectHeaders,
+	"responseheaders": colExpectHeaders,
+	"bodycontains":    colBodyContains,
+	"bodyregex":       colBodyRegex,
+	"match":           colMatch,
+	"preservequery":   colPreserveQuery,
+}
+
+// csvHeaderColumns maps the header row to columns. Headers that are not all
+// known column names are read positionally as the original seven columns.
+func csvHeaderColumns(header []string) ([]csvColumn, error) {
+	columns := make([]csvColumn, 0, len(header))
+	seen := map[csvColumn]bool{}
+	for _, name := range header {
+		normalized := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		column, ok := csvColumnNames[normalized]
+		if !ok || seen[column] {
+			columns = nil
+			break
+		}
+		seen[column] = true
+		columns = append(columns, column)
+	}
+
+	if columns == nil {
+		if len(header) > int(colHops)+1 {
+			return nil, fmt.Errorf("unknown columns in header %q", strings.Join(header, ","))
+		}
+		for i := range header {
+			columns = append(columns, csvColumn(i))
+		}
+		return columns, nil
+	}
+	if !seen[colURL] || !seen[colStatus] {
+		return nil, fmt.Errorf("header must name the URL and Expected status columns")
+	}
+	return columns, nil
+}
+
+// parseLinkTestCSV parses the CSV format; the first row is the header
+func parseLinkTestCSV(filename string, data []byte) ([]LinkTest, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	columns, err := csvHeaderColumns(header)
+	if err != nil {
+		return nil, fmt.Errorf("%s:1: %w", filename, err)
+	}
+
+	var tests []LinkTest
+	var errs []error
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filename, err))
+			if errors.Is(err, csv.ErrFieldCount) {
+				continue
+			}
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+		test, err := parseLinkTestRecord(columns, record)
+		if err == nil {
+			err = validateLinkTest(test)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", filename, line, err))
+			continue
+		}
+		test.Line = line
+		tests = append(tests, test)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return tests, nil
+}
+
+// parseLinkTestRecord builds a LinkTest from a CSV record
+func parseLinkTestRecord(columns []csvColumn, record []string) (LinkTest, error) {
+	var test LinkTest
+	for i, column := range columns {
+		value := strings.TrimSpace(record[i])
+		var err error
+		switch column {
+		case colAgent:
+			test.Agent = value
+		case colCountry:
+			test.Country = value
+		case colURL:
+			test.URL = value
+		case colStatus:
+			test.ExpectedStatus, err = strconv.Atoi(value)
+			if err != nil {
+				return test, fmt.Errorf("invalid expected status %q", value)
+			}
+		case colResult:
+			test.ExpectedResult = value
+		case colFinal:
+			test.ExpectedFinal = value
+		case colHops:
+			if value != "" {
+				if test.ExpectedHops, err = strconv.Atoi(value); err != nil {
+					return test, fmt.Errorf("invalid expected hop count %q", value)
+				}
+			}
+		case colName:
+			test.Name = value
+		case colMethod:
+			test.Method = strings.ToUpper(value)
+		case colHeaders:
+			test.Headers, err = parseHeaderList(value)
+		case colCookies:
+			test.Cookies, err = parsePairList(value, "=", "cookie")
+		case colQuery:
+			test.Query, err = parseQueryCell(value)
+		case colExpectHeaders:
+			test.ExpectHeaders, err = parseHeaderList(value)
+		case colBodyContains:
+			test.BodyContains = splitList(value)
+		case colBodyRegex:
+			test.BodyRegex = value
+		case colMatch:
+			test.Match = strings.ToLower(value)
+		case colPreserveQuery:
+			if value != "" {
+				if test.PreserveQuery, err = strconv.ParseBool(value); err != nil {
+					return test, fmt.Errorf("invalid preserve query flag %q (want true or false)", value)
+				}
+			}
+		}
+		if err != nil {
+			return test, err
+		}
+	}
+	return test, nil
+}
+
+// splitList splits a semicolon-separated cell, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseHeaderList parses "Name: value; Name: value"
+func parseHeaderList(value string) (map[string]string, error) {
+	return parsePairList(value, ":", "header")
+}
+
+// parsePairList parses semicolon-separated name/value pairs
+func parsePairList(value, separator, kind string) (map[string]string, error) {
+	items := splitList(value)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	pairs := make(map[string]string, len(items))
+	for _, item := range items {
+		name, val, ok := strings.Cut(item, separator)
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid %s %q (want name%svalue)", kind, item, separator)
+		}
+		pairs[name] = strings.TrimSpace(val)
+	}
+	return pairs, nil
+}
+
+// parseQueryCell parses "a=1&b=2"
+func parseQueryCell(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	values, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", value, err)
+	}
+	query := make(map[string]string, len(values))
+	for name := range values {
+		query[name] = values.Get(name)
+	}
+	return query, nil
+}
+
+// validateLinkTest checks that a test case can be sent and judged
+func validateLinkTest(test LinkTest) error {
+	u, err := url.Parse(test.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q (want an absolute http:// or https:// URL)", test.URL)
+	}
+	if test.ExpectedStatus < 100 || test.ExpectedStatus > 599 {
+		return fmt.Errorf("expected status %d is not an HTTP status code", test.ExpectedStatus)
+	}
+	if test.Method != "" && !slices.Contains(validMethods, test.Method) {
+		return fmt.Errorf("unsupported method %q", test.Method)
+	}
+	if test.Country != "" && !countryCodeRe.MatchString(test.Country) {
+		return fmt.Errorf("invalid country %q (want a two-letter code)", test.Country)
+	}
+	if _, ok := lookupCountry(test.Country); test.Country != "" && !ok {
+		return fmt.Errorf("unknown country %q (want an ISO 3166 code or alias)", test.Country)
+	}
+	if _, ok := lookupUserAgent(test.Agent); test.Agent != "" && !ok {
+		return fmt.Errorf("unknown agent %q (see the agents command)", test.Agent)
+	}
+	if test.ExpectedHops < 0 {
+		return fmt.Errorf("expected hop count %d is negative", test.ExpectedHops)
+	}
+	if test.BodyRegex != "" {
+		if _, err := regexp.Compile(test.BodyRegex); err != nil {
+			return fmt.Errorf("invalid body regex: %w", err)
+		}
+	}
+	return validateMatcher(test)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTestFile writes a links.testing file into a temporary directory
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	return path
+}
+
+// TestParseLinkTestCSVColumns tests the named extended CSV columns
+func TestParseLinkTestCSVColumns(t *testing.T) {
+	path := writeTestFile(t, "links.testing", `Name,Agent,Country,URL,Method,Headers,Cookies,Query,Expected status,Expected result,Expect headers,Body contains,Body regex
+Override,Browser,DE,http://example.com/,head,Accept-Language: de; X-Forwarded-For: 1.2.3.4,country_override=uk,utm=x&b=2,302,/uk/,Vary: X-Test-Country; Cache-Control:,Welcome; Hallo,^<!doctype
+`)
+
+	tests, err := parseLinkTestFile(path)
+	if err != nil {
+		t.Fatalf("parseLinkTestFile() error = %v", err)
+	}
+	want := LinkTest{
+		Line:           2,
+		Name:           "Override",
+		Agent:          "Browser",
+		Country:        "DE",
+		URL:            "http://example.com/",
+		Method:         "HEAD",
+		Headers:        map[string]string{"Accept-Language": "de", "X-Forwarded-For": "1.2.3.4"},
+		Cookies:        map[string]string{"country_override": "uk"},
+		Query:          map[string]string{"utm": "x", "b": "2"},
+		ExpectedStatus: 302,
+		ExpectedResult: "/uk/",
+		ExpectHeaders:  map[string]string{"Vary": "X-Test-Country", "Cache-Control": ""},
+		BodyContains:   []string{"Welcome", "Hallo"},
+		BodyRegex:      "^<!doctype",
+	}
+	if len(tests) != 1 || !reflect.DeepEqual(tests[0], want) {
+		t.Errorf("parseLinkTestFile() = %+v, want %+v", tests, want)
+	}
+
+	// Unrecognised headers keep the original positional columns
+	path = writeTestFile(t, "legacy.csv", "Who,Where,What,Code,Target\nBrowser,US,http://example.com/,200,No redirect\n")
+	tests, err = parseLinkTestFile(path)
+	if err != nil || len(tests) != 1 || tests[0].URL != "http://example.com/" || tests[0].ExpectedStatus != 200 {
+		t.Errorf("positional CSV = %+v, %v", tests, err)
+	}
+}
+
+// TestParseLinkTestFileStrict tests that every malformed row is reported with its line
+func TestParseLinkTestFileStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		errors  []string
+	}{
+		{"csv rows", "links.testing", `Agent,Country,URL,Expected status,Expected result
+Browser,US,http://example.com/,200,No redirect
+Browser,US,http://example.com/,invalid,No redirect
+Browser,USA,http://example.com/,200,No redirect
+Browser,US,example.com,200,No redirect
+`, []string{`links.testing:3: invalid expected status "invalid"`, `links.testing:4: invalid country "USA"`, `links.testing:5: invalid URL "example.com"`}},
+		{"csv field count", "links.testing", "Agent,Country,URL,Expected status,Expected result\nBrowser,US\nBrowser,US,http://example.com/,999,x\n",
+			[]string{"line 2", "links.testing:3: expected status 999"}},
+		{"csv cells", "links.testing", "URL,Expected status,Method,Headers,Body regex\nhttp://x/,200,FETCH,,\nhttp://x/,200,GET,Accept,\nhttp://x/,200,GET,,(\n",
+			[]string{`:2: unsupported method "FETCH"`, `:3: invalid header "Accept"`, ":4: invalid body regex"}},
+		{"csv missing url column", "links.testing", "Agent,Country,Expected status\nBrowser,US,200\n", []string{":1: header must name"}},
+		{"yaml rows", "links.yaml", `version: 1
+tests:
+  - url: http://example.com/
+    expect: {status: 200}
+  - url: http://example.com/
+    method: fetch
+    expect: {status: 200}
+  - url: http://example.com/
+    expect: {status: 0}
+`, []string{`links.yaml:5: unsupported method "FETCH"`, "links.yaml:8: expected status 0"}},
+		{"yaml unknown field", "links.yaml", "version: 1\ntests:\n  - url: http://example.com/\n    expect: {stauts: 200}\n", []string{"line 4", "stauts"}},
+		{"yaml version", "links.yaml", "tests: []\n", []string{"unsupported version 0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLinkTestFile(writeTestFile(t, tt.file, tt.content))
+			if err == nil {
+				t.Fatalf("parseLinkTestFile() = %d tests, want error", len(got))
+			}
+			if got != nil {
+				t.Errorf("parseLinkTestFile() returned %d tests alongside the error", len(got))
+			}
+			for _, want := range tt.errors {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// TestParseLinkTestYAMLAndJSON tests the versioned structured formats
+func TestParseLinkTestYAMLAndJSON(t *testing.T) {
+	yamlPath := writeTestFile(t, "links.yaml", `version: 1
+tests:
+  - name: German cookie override
+    agent: Browser
+    country: DE
+    url: http://example.com/
+    cookies: {country_override: uk}
+    expect:
+      status: 302
+      result: /uk/
+      headers: {Vary: X-Test-Country}
+  - agent: Googlebot
+    url: http://example.com/robots.txt
+    expect:
+      status: 200
+      body_contains: [Sitemap]
+`)
+	jsonPath := writeTestFile(t, "links.json", `{
+	"version": 1,
+	"tests": [
+		{"name": "German cookie override", "agent": "Browser", "country": "DE", "url": "http://example.com/",
+		 "cookies": {"country_override": "uk"},
+		 "expect": {"status": 302, "result": "/uk/", "headers": {"Vary": "X-Test-Country"}}},
+		{"agent": "Googlebot", "url": "http://example.com/robots.txt",
+		 "expect": {"status": 200, "body_contains": ["Sitemap"]}}
+	]
+}`)
+
+	fromYAML, err := parseLinkTestFile(yamlPath)
+	if err != nil {
+		t.Fatalf("YAML error = %v", err)
+	}
+	fromJSON, err := parseLinkTestFile(jsonPath)
+	if err != nil {
+		t.Fatalf("JSON error = %v", err)
+	}
+	if len(fromYAML) != 2 || fromYAML[0].Line != 3 || fromYAML[1].Line != 12 {
+		t.Errorf("YAML tests = %+v, want 2 tests on lines 3 and 12", fromYAML)
+	}
+	if fromYAML[1].ExpectedResult != "No redirect" {
+		t.Errorf("ExpectedResult = %q, want No redirect for a 200 without result", fromYAML[1].ExpectedResult)
+	}
+	for i := range fromJSON {
+		fromJSON[i].Line = fromYAML[i].Line
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("JSON tests = %+v, want %+v", fromJSON, fromYAML)
+	}
+}
+
+// TestRunLinkTestsRequestOptions tests methods, headers, cookies, query strings and response assertions
+func TestRunLinkTestsRequestOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, _ := r.Cookie("country_override")
+		if r.Method != "HEAD" || r.Header.Get("Accept-Language") != "de" || cookie == nil || cookie.Value != "uk" ||
+			r.URL.Query().Get("utm") != "x" || r.Header.Get("X-Test-Country") != "DE" {
+			t.Errorf("unexpected request %s %s %v", r.Method, r.URL, r.Header)
+		}
+		w.Header().Set("Vary", "X-Test-Country, Cookie")
+		w.Header().Set("X-Robots-Tag", "noindex")
+		w.WriteHeader(200)
+		if _, err := w.Write([]byte("<p>Willkommen</p>")); err != nil {
+			t.Errorf("write: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	base := LinkTest{
+		Agent:          "Browser",
+		Country:        "DE",
+		URL:            server.URL + "/?a=1",
+		Method:         "HEAD",
+		Headers:        map[string]string{"accept-language": "de"},
+		Cookies:        map[string]string{"country_override": "uk"},
+		Query:          map[string]string{"utm": "x"},
+		ExpectedStatus: 200,
+		ExpectedResult: "No redirect",
+	}
+	if got := linkTestURL(base); got != server.URL+"/?a=1&utm=x" {
+		t.Errorf("linkTestURL() = %s", got)
+	}
+
+	withHeaders := base
+	withHeaders.ExpectHeaders = map[string]string{"Vary": "Cookie", "X-Robots-Tag": ""}
+	missingHeader := base
+	missingHeader.ExpectHeaders = map[string]string{"Cache-Control": ""}
+	wrongHeader := base
+	wrongHeader.ExpectHeaders = map[string]string{"X-Robots-Tag": "nofollow"}
+
+	results := runLinkTests([]LinkTest{base, withHeaders, missingHeader, wrongHeader})
+	want := []bool{true, true, false, false}
+	for i, result := range results {
+		if result.Success != want[i] {
+			t.Errorf("test %d success = %v, want %v", i, result.Success, want[i])
+		}
+	}
+}
+
+// TestRunLinkTestsBody tests body substring and regex checks
+func TestRunLinkTestsBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("<!doctype html><p>Willkommen</p>")); err != nil {
+			t.Errorf("write: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		contains []string
+		regex    string
+		success  bool
+	}{
+		{[]string{"Willkommen"}, "", true},
+		{[]string{"Willkommen", "Welcome"}, "", false},
+		{nil, "^<!doctype html>", true},
+		{nil, "^<html", false},
+	}
+	for i, tt := range tests {
+		test := LinkTest{URL: server.URL, ExpectedStatus: 200, ExpectedResult: "No redirect", BodyContains: tt.contains, BodyRegex: tt.regex}
+		if got := runLinkTests([]LinkTest{test})[0].Success; got != tt.success {
+			t.Errorf("case %d success = %v, want %v", i, got, tt.success)
+		}
+	}
+}
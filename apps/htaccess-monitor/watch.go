@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long a burst of events (an editor's save, a git
+// checkout) has to settle before the change is reported
+const watchDebounce = 200 * time.Millisecond
+
+// htaccessName is the file name looked for in watched directories
+const htaccessName = ".htaccess"
+
+// watchSkipDirs are not descended into when watching a directory tree
+var watchSkipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true}
+
+// watchPaths collects repeated -watch-path flags
+type watchPaths []string
+
+// String implements flag.Value
+func (w *watchPaths) String() string {
+	return strings.Join(*w, ",")
+}
+
+// Set implements flag.Value
+func (w *watchPaths) Set(value string) error {
+	if value == "" {
+		return fmt.Errorf("empty path")
+	}
+	*w = append(*w, value)
+	return nil
+}
+
+// extraWatchPaths are the files, globs and directories watched besides the
+// .htaccess and the test file
+var extraWatchPaths watchPaths
+
+// fileWatcher is a single long-lived watcher over files, glob patterns and
+// directory trees. It watches their directories rather than the files
+// themselves, so editors that save by writing a temporary file and renaming
+// it over the original keep being noticed
+type fileWatcher struct {
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+	files    map[string]bool // absolute paths
+	globs    []string        // absolute patterns
+	trees    []string        // directories whose .htaccess files are watched
+	changes  chan []string
+	done     chan struct{}
+	close    sync.Once
+}
+
+// newFileWatcher watches paths: files, glob patterns (e.g. sites/*/.htaccess)
+// or directories, in which every .htaccess below is watched
+func newFileWatcher(paths []string, debounce time.Duration) (*fileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	w := &fileWatcher{
+		watcher:  watcher,
+		debounce: debounce,
+		files:    map[string]bool{},
+		changes:  make(chan []string),
+		done:     make(chan struct{}),
+	}
+	for _, path := range paths {
+		if err := w.add(path); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+// add starts watching a file, glob pattern or directory
+func (w *fileWatcher) add(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if strings.ContainsAny(abs, "*?[") {
+		if _, err := filepath.Match(abs, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", path, err)
+		}
+		if err := w.addGlob(abs); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		w.globs = append(w.globs, abs)
+		return nil
+	}
+	if info, err := os.Stat(abs); err == nil && info.IsDir() {
+		w.trees = append(w.trees, abs)
+		return w.addTree(abs)
+	}
+	if err := w.watcher.Add(filepath.Dir(abs)); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	w.files[abs] = true
+	return nil
+}
+
+// globLevels splits the directory of a glob pattern into its deepest
+// directory without wildcards and the patterns below it, e.g. sites and
+// [sites/*] for sites/*/.htaccess
+func globLevels(pattern string) (string, []string) {
+	var levels []string
+	dir := filepath.Dir(pattern)
+	for ; strings.ContainsAny(dir, "*?["); dir = filepath.Dir(dir) {
+		levels = append([]string{dir}, levels...)
+	}
+	return dir, levels
+}
+
+// addGlob watches the deepest directory of a glob pattern without wildcards
+// and every directory below it that matches the pattern so far, so that
+// directories created later are noticed; nothing has to match yet
+func (w *fileWatcher) addGlob(pattern string) error {
+	base, levels := globLevels(pattern)
+	if err := w.watcher.Add(base); err != nil {
+		return err
+	}
+	for _, level := range levels {
+		dirs, _ := filepath.Glob(level)
+		for _, dir := range dirs {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				continue
+			}
+			if err := w.watcher.Add(dir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createdGlobDir watches a new directory that matches the directory part of
+// a glob pattern and returns the files in it the pattern already matches
+func (w *fileWatcher) createdGlobDir(dir string) []string {
+	var found []string
+	for _, pattern := range w.globs {
+		_, levels := globLevels(pattern)
+		if !slices.ContainsFunc(levels, func(level string) bool {
+			ok, _ := filepath.Match(level, dir)
+			return ok
+		}) {
+			continue
+		}
+		if err := w.addGlob(pattern); err != nil {
+			log.Printf("Watcher error: %v", err)
+		}
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			if strings.HasPrefix(match, dir+string(filepath.Separator)) {
+				found = append(found, match)
+			}
+		}
+	}
+	return found
+}
+
+// addTree watches a directory and its subdirectories
+func (w *fileWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != root && watchSkipDirs[entry.Name()] {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// inTree returns the watched tree a path lies in, or ""
+func (w *fileWatcher) inTree(path string) string {
+	for _, tree := range w.trees {
+		if path == tree || strings.HasPrefix(path, tree+string(filepath.Separator)) {
+			return tree
+		}
+	}
+	return ""
+}
+
+// matches reports whether a changed path is one of the watched files
+func (w *fileWatcher) matches(path string) bool {
+	if w.files[path] {
+		return true
+	}
+	for _, pattern := range w.globs {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return filepath.Base(path) == htaccessName && w.inTree(path) != ""
+}
+
+// Changes delivers the watched files changed by each settled burst of
+// events, sorted; it is closed when the watcher is
+func (w *fileWatcher) Changes() <-chan []string {
+	return w.changes
+}
+
+// Close stops watching
+func (w *fileWatcher) Close() error {
+	var err error
+	w.close.Do(func() {
+		close(w.done)
+		err = w.watcher.Close()
+	})
+	return err
+}
+
+// run collects the events of the watched files and reports them once no
+// event arrived for the debounce interval
+func (w *fileWatcher) run() {
+	defer close(w.changes)
+	pending := map[string]bool{}
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) && len(w.globs) > 0 {
+				// A new directory matching a glob, possibly moved in with
+				// matching files
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					for _, file := range w.createdGlobDir(event.Name) {
+						pending[file] = true
+						timer.Reset(w.debounce)
+					}
+				}
+			}
+			if event.Has(fsnotify.Create) && w.inTree(event.Name) != "" {
+				// A new subdirectory, possibly moved in with .htaccess files
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !watchSkipDirs[info.Name()] {
+					if err := w.addTree(event.Name); err != nil {
+						log.Printf("Watcher error: %v", err)
+					}
+					if _, err := os.Stat(filepath.Join(event.Name, htaccessName)); err == nil {
+						pending[filepath.Join(event.Name, htaccessName)] = true
+						timer.Reset(w.debounce)
+					}
+				}
+			}
+			if event.Op == fsnotify.Chmod || !w.matches(event.Name) {
+				continue
+			}
+			pending[event.Name] = true
+			timer.Reset(w.debounce)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watcher error: %v", err)
+
+		case <-timer.C:
+			batch := slices.Sorted(maps.Keys(pending))
+			clear(pending)
+			select {
+			case w.changes <- batch:
+			case <-w.done:
+				return
+			}
+
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// fileChangedMsg reports the watched files changed by a burst of events
+type fileChangedMsg struct {
+	files []string
+	at    time.Time
+}
+
+// waitForChange delivers the next change of the monitor's watcher
+func waitForChange(w *fileWatcher) tea.Cmd {
+	return func() tea.Msg {
+		files, ok := <-w.Changes()
+		if !ok {
+			return nil
+		}
+		return fileChangedMsg{files: files, at: time.Now()}
+	}
+}
+
+// displayPath shortens a watched path relative to the working directory
+func displayPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			return rel
+		}
+	}
+	return path
+}
+
+// describeChange names the changed files, e.g. ".htaccess and 2 more"
+func describeChange(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	first := displayPath(files[0])
+	if len(files) == 1 {
+		return first
+	}
+	return fmt.Sprintf("%s and %d more", first, len(files)-1)
+}
+
+// defaultHtaccessPath is the .htaccess evaluated without -htaccess: the
+// repository's one when run from apps/htaccess-monitor, else the one in the
+// working directory
+func defaultHtaccessPath() string {
+	if _, err := os.Stat(htaccessPath); err != nil {
+		if _, err := os.Stat(htaccessName); err == nil {
+			return htaccessName
+		}
+	}
+	return htaccessPath
+}
+
+// monitorWatchPaths returns what the monitor watches: the .htaccess, any
+// -watch-path and, in link test mode, the test file
+func monitorWatchPaths(testFile string) []string {
+	paths := []string{htaccessPath}
+	if testFile != "" {
+		paths = append(paths, testFile)
+	}
+	return append(paths, extraWatchPaths...)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDebounce keeps the watcher tests fast
+const testDebounce = 50 * time.Millisecond
+
+// newTestWatcher watches paths and closes the watcher after the test
+func newTestWatcher(t *testing.T, paths ...string) *fileWatcher {
+	t.Helper()
+	w, err := newFileWatcher(paths, testDebounce)
+	if err != nil {
+		t.Fatalf("newFileWatcher() error = %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+// nextChange waits for the next batch of changed files
+func nextChange(t *testing.T, w *fileWatcher) []string {
+	t.Helper()
+	select {
+	case files := <-w.Changes():
+		return files
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+		return nil
+	}
+}
+
+// noChange checks that nothing is reported for a while
+func noChange(t *testing.T, w *fileWatcher) {
+	t.Helper()
+	select {
+	case files := <-w.Changes():
+		t.Errorf("unexpected change %v", files)
+	case <-time.After(4 * testDebounce):
+	}
+}
+
+// mustWrite writes a file for a watcher test
+func mustWrite(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFileWatcherDebounce tests that a burst of writes is one change and
+// that other files of the directory are ignored
+func TestFileWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".htaccess")
+	mustWrite(t, path, "RewriteEngine On\n")
+	w := newTestWatcher(t, path)
+
+	for i := range 5 {
+		mustWrite(t, path, strings.Repeat("#\n", i))
+	}
+	if got := nextChange(t, w); !slices.Equal(got, []string{path}) {
+		t.Errorf("change = %v, want [%s]", got, path)
+	}
+	noChange(t, w)
+
+	mustWrite(t, filepath.Join(dir, "notes.txt"), "x")
+	noChange(t, w)
+}
+
+// TestFileWatcherAtomicSave tests the rename patterns editors save with:
+// a temporary file renamed over the original, and a backup rename followed
+// by a new file
+func TestFileWatcherAtomicSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".htaccess")
+	mustWrite(t, path, "RewriteEngine On\n")
+	w := newTestWatcher(t, path)
+
+	tmp := filepath.Join(dir, ".htaccess.tmp~")
+	mustWrite(t, tmp, "RewriteEngine Off\n")
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	if got := nextChange(t, w); !slices.Equal(got, []string{path}) {
+		t.Errorf("change = %v, want only %s", got, path)
+	}
+
+	// The watch survives the replaced inode
+	if err := os.Rename(path, path+"~"); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, path, "RewriteEngine On\n")
+	if got := nextChange(t, w); !slices.Equal(got, []string{path}) {
+		t.Errorf("change = %v, want only %s", got, path)
+	}
+}
+
+// TestFileWatcherTreeAndGlob tests nested .htaccess files of a directory,
+// including ones in new subdirectories, and glob patterns, including ones
+// that match nothing until a directory is created
+func TestFileWatcherTreeAndGlob(t *testing.T) {
+	root := t.TempDir()
+	sites := filepath.Join(root, "sites")
+	for _, dir := range []string{"shop/de", "blog", ".git"} {
+		if err := os.MkdirAll(filepath.Join(sites, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vhosts := filepath.Join(root, "vhosts")
+	if err := os.Mkdir(vhosts, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, filepath.Join(root, "links.testing"), "")
+	w := newTestWatcher(t, sites, filepath.Join(root, "*.testing"), filepath.Join(vhosts, "*", ".htaccess"))
+
+	nested := filepath.Join(sites, "shop", "de", ".htaccess")
+	mustWrite(t, nested, "RewriteEngine On\n")
+	if got := nextChange(t, w); !slices.Equal(got, []string{nested}) {
+		t.Errorf("change = %v, want [%s]", got, nested)
+	}
+
+	mustWrite(t, filepath.Join(sites, "blog", "index.php"), "")
+	mustWrite(t, filepath.Join(sites, ".git", ".htaccess"), "")
+	noChange(t, w)
+
+	added := filepath.Join(sites, "news")
+	if err := os.Mkdir(added, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(testDebounce)
+	mustWrite(t, filepath.Join(added, ".htaccess"), "RewriteEngine On\n")
+	mustWrite(t, filepath.Join(root, "links.testing"), "Agent,Country,URL,Expected status,Expected result\n")
+	want := []string{filepath.Join(root, "links.testing"), filepath.Join(added, ".htaccess")}
+	if got := nextChange(t, w); !slices.Equal(got, want) {
+		t.Errorf("change = %v, want %v", got, want)
+	}
+
+	// Directories matching the glob that appear after the watcher started,
+	// empty or moved in with a .htaccess
+	shop := filepath.Join(vhosts, "shop")
+	if err := os.Mkdir(shop, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(testDebounce)
+	mustWrite(t, filepath.Join(shop, ".htaccess"), "RewriteEngine On\n")
+	if got := nextChange(t, w); !slices.Equal(got, []string{filepath.Join(shop, ".htaccess")}) {
+		t.Errorf("change = %v, want [%s]", got, filepath.Join(shop, ".htaccess"))
+	}
+	staged := filepath.Join(root, "staged")
+	if err := os.Mkdir(staged, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, filepath.Join(staged, ".htaccess"), "RewriteEngine On\n")
+	if err := os.Rename(staged, filepath.Join(vhosts, "blog")); err != nil {
+		t.Fatal(err)
+	}
+	if got := nextChange(t, w); !slices.Equal(got, []string{filepath.Join(vhosts, "blog", ".htaccess")}) {
+		t.Errorf("change = %v, want [%s]", got, filepath.Join(vhosts, "blog", ".htaccess"))
+	}
+}
+
+// TestFileWatcherErrors tests paths that cannot be watched
+func TestFileWatcherErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{
+		filepath.Join(dir, "missing", ".htaccess"),
+		filepath.Join(dir, "missing", "*", ".htaccess"),
+		filepath.Join(dir, "[", ".htaccess"),
+	} {
+		if w, err := newFileWatcher([]string{path}, testDebounce); err == nil {
+			w.Close()
+			t.Errorf("newFileWatcher(%s) expected error", path)
+		}
+	}
+
+	// A file that does not exist yet is watched through its directory
+	w := newTestWatcher(t, filepath.Join(dir, ".htaccess"))
+	mustWrite(t, filepath.Join(dir, ".htaccess"), "RewriteEngine On\n")
+	nextChange(t, w)
+	if err := w.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if _, ok := <-w.Changes(); ok {
+		t.Error("Changes() not closed after Close()")
+	}
+}
+
+// TestModelFileChanged tests the change indicator of the monitor and that a
+// change starts a new run
+func TestModelFileChanged(t *testing.T) {
+	m := initialModel()
+	at := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	wd, _ := os.Getwd()
+	updated, cmd := m.Update(fileChangedMsg{files: []string{filepath.Join(wd, ".htaccess"), filepath.Join(wd, "sites", ".htaccess")}, at: at})
+	m = updated.(model)
+	if cmd == nil || m.run != 1 || !m.testing {
+		t.Errorf("change did not start a run: run %d, testing %v", m.run, m.testing)
+	}
+	m.cancel = nil
+	m.ready, m.testing = true, false
+	if view := m.View(); !strings.Contains(view, "📝 .htaccess and 1 more changed at 15:04:05") {
+		t.Errorf("view has no change indicator:\n%s", view)
+	}
+}
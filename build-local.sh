@@ -15,7 +15,7 @@ echo "📦 Version: $VERSION"
 echo "🕒 Build Time: $BUILD_TIME"
 
 # Build with version injection
-go build -ldflags "-s -w -X main.Version=${VERSION} -X main.BuildTime=${BUILD_TIME}" -o ../../tools/htaccess-monitor main.go
+go build -ldflags "-s -w -X main.Version=${VERSION} -X main.BuildTime=${BUILD_TIME}" -o ../../tools/htaccess-monitor .
 
 echo "✅ Built successfully!"
 echo "📍 Binary location: tools/htaccess-monitor"
@@ -73,7 +73,7 @@ build_platform() {
     local ldflags="-s -w -X main.Version=${VERSION} -X main.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
     
     # Build the binary (change to source directory first)
-    if (cd "$SOURCE_DIR" && go build -ldflags "$ldflags" -o "../../${BUILD_DIR}/${APP_NAME}-${output_name}" main.go) 2>/dev/null; then
+    if (cd "$SOURCE_DIR" && go build -ldflags "$ldflags" -o "../../${BUILD_DIR}/${APP_NAME}-${output_name}" .) 2>/dev/null; then
         local file_size=$(du -h "${BUILD_DIR}/${APP_NAME}-${output_name}" | cut -f1)
         echo -e "   ${GREEN}✅ Success - Size: ${file_size}${NC}"
         return 0